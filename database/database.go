@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -17,6 +18,15 @@ func ConnectDatabase(uri string, databaseName string) *mongo.Database {
 	if err != nil {
 		panic(err)
 	}
-	database := client.Database(databaseName)
-	return database
+	// the indexes are synced by main after the migrations have run
+	return client.Database(databaseName)
+}
+
+// a timeout of 0 means the call only ends when ctx does, the models and the
+// throttle store wrap every call in this
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
 }
@@ -0,0 +1,366 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Index is a index a collection should have, the name is what the index
+// is matched on so changing the keys of a index needs a new name or the
+// old index is dropped and made again
+type Index struct {
+	Name   string
+	Keys   bson.D
+	Unique bool
+	// ttl indexes delete documents ExpireAfter after the date in the key,
+	// they only work on single key indexes
+	TTL         bool
+	ExpireAfter time.Duration
+}
+
+func (i Index) expireSeconds() int32 {
+	return int32(i.ExpireAfter / time.Second)
+}
+
+// IndexMode is what SetupIndexes does with the registered indexes
+type IndexMode int
+
+const (
+	// makes missing indexes and remakes ones that changed
+	IndexesApply IndexMode = iota
+	// only prints what would be changed
+	IndexesDryRun
+	IndexesOff
+)
+
+// main sets this from the env before calling SetupIndexes
+var IndexSetting = IndexesApply
+
+var (
+	registryLock sync.Mutex
+	registry     = map[string][]Index{}
+)
+
+// declares the indexes of a collection, the models call this from init
+func RegisterIndexes(collection string, indexes ...Index) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry[collection] = append(registry[collection], indexes...)
+}
+
+// the kinds of difference between the declared and actual indexes
+const (
+	IndexMissing = "missing"
+	IndexChanged = "changed"
+	// extra indexes are only reported, they might have been made by hand
+	IndexExtra = "not declared"
+	// a unique index cant be made until the duplicate documents are fixed
+	IndexDuplicates = "has duplicate keys"
+)
+
+// IndexDrift is one difference between the declared and actual indexes
+type IndexDrift struct {
+	Collection string
+	Index      string
+	Problem    string
+}
+
+func (d IndexDrift) String() string {
+	return d.Collection + "." + d.Index + ": " + d.Problem
+}
+
+// a index as listIndexes returns it
+type existingIndex struct {
+	Name               string `bson:"name"`
+	Key                bson.D `bson:"key"`
+	Unique             bool   `bson:"unique"`
+	ExpireAfterSeconds *int32 `bson:"expireAfterSeconds"`
+}
+
+// reports every difference between the registered indexes and the ones in
+// the database without changing anything
+func CheckIndexes(ctx context.Context, db *mongo.Database) ([]IndexDrift, error) {
+	var drift []IndexDrift
+	for _, collection := range registeredCollections() {
+		actual, err := listIndexes(ctx, db.Collection(collection))
+		if err != nil {
+			return nil, err
+		}
+		drift = append(drift, diffIndexes(collection, registered(collection), actual)...)
+	}
+	return drift, nil
+}
+
+// makes the database match the registered indexes, running it again does
+// nothing. returns the drift that was fixed (extra indexes are left alone)
+// and the unique indexes that cant be made because of duplicate documents
+func SyncIndexes(ctx context.Context, db *mongo.Database) ([]IndexDrift, error) {
+	drift, err := CheckIndexes(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	var fixed []IndexDrift
+	for _, d := range drift {
+		if d.Problem == IndexExtra {
+			continue
+		}
+		c := db.Collection(d.Collection)
+		index := findIndex(d.Collection, d.Index)
+		if d.Problem == IndexChanged {
+			// the old index stays until the new one is known to work, mongo
+			// wont build a second index on the same keys so it cant be made
+			// next to it first
+			dropped, err := replaceIndex(ctx, c, index)
+			if err != nil {
+				return fixed, fmt.Errorf("replacing index %s: %w", d, err)
+			}
+			if !dropped {
+				fixed = append(fixed, IndexDrift{Collection: d.Collection, Index: d.Index, Problem: IndexDuplicates})
+				continue
+			}
+			fixed = append(fixed, d)
+			continue
+		}
+		if _, err := c.Indexes().CreateOne(ctx, indexModel(index)); err != nil {
+			// the data has to be fixed by hand, the rest of the indexes can still be made
+			if mongo.IsDuplicateKeyError(err) {
+				fixed = append(fixed, IndexDrift{Collection: d.Collection, Index: d.Index, Problem: IndexDuplicates})
+				continue
+			}
+			return fixed, fmt.Errorf("creating index %s: %w", d, err)
+		}
+		fixed = append(fixed, d)
+	}
+	return fixed, nil
+}
+
+// drops the index with the name of the declared one and makes it again with
+// the declared keys and options. returns false without dropping anything if
+// the new index would be unique and there are duplicate documents, if the new
+// index still cant be made the old one is put back
+func replaceIndex(ctx context.Context, c *mongo.Collection, index Index) (bool, error) {
+	if index.Unique {
+		duplicates, err := hasDuplicates(ctx, c, index.Keys)
+		if err != nil {
+			return false, err
+		}
+		if duplicates {
+			return false, nil
+		}
+	}
+	old, err := getIndex(ctx, c, index.Name)
+	if err != nil {
+		return false, err
+	}
+	if _, err := c.Indexes().DropOne(ctx, index.Name); err != nil {
+		return false, err
+	}
+	if _, err := c.Indexes().CreateOne(ctx, indexModel(index)); err != nil {
+		if old != nil {
+			if _, restoreErr := c.Indexes().CreateOne(ctx, old.model()); restoreErr != nil {
+				return false, fmt.Errorf("%w (putting the old index back failed: %v)", err, restoreErr)
+			}
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// true if more than one document has the same values for the keys, a unique
+// index on them would fail to build
+func hasDuplicates(ctx context.Context, c *mongo.Collection, keys bson.D) (bool, error) {
+	// the group fields are numbered because the keys can have dots in them
+	group := bson.D{}
+	for i, key := range keys {
+		group = append(group, primitive.E{Key: fmt.Sprint("k", i), Value: "$" + key.Key})
+	}
+	pipeline := mongo.Pipeline{
+		bson.D{primitive.E{Key: "$group", Value: bson.D{
+			primitive.E{Key: "_id", Value: group},
+			primitive.E{Key: "count", Value: bson.D{primitive.E{Key: "$sum", Value: 1}}},
+		}}},
+		bson.D{primitive.E{Key: "$match", Value: bson.D{primitive.E{Key: "count", Value: bson.D{primitive.E{Key: "$gt", Value: 1}}}}}},
+		bson.D{primitive.E{Key: "$limit", Value: 1}},
+	}
+	cur, err := c.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return false, err
+	}
+	defer cur.Close(ctx)
+	return cur.Next(ctx), cur.Err()
+}
+
+// the index with the name as it is in the database, nil if there is none
+func getIndex(ctx context.Context, c *mongo.Collection, name string) (*existingIndex, error) {
+	indexes, err := listIndexes(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range indexes {
+		if index.Name == name {
+			return &index, nil
+		}
+	}
+	return nil, nil
+}
+
+// runs the IndexSetting against the database, main calls it once at boot
+// after the migrations so they can fix the data the indexes depend on
+func SetupIndexes(db *mongo.Database) error {
+	if IndexSetting == IndexesOff {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if IndexSetting == IndexesDryRun {
+		drift, err := CheckIndexes(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, d := range drift {
+			fmt.Println("index drift " + d.String())
+		}
+		fmt.Printf("index dry run found %d differences\n", len(drift))
+		return nil
+	}
+	fixed, err := SyncIndexes(ctx, db)
+	for _, d := range fixed {
+		if d.Problem == IndexDuplicates {
+			fmt.Println("index drift " + d.String())
+			continue
+		}
+		fmt.Println("index fixed " + d.String())
+	}
+	return err
+}
+
+func diffIndexes(collection string, declared []Index, actual []existingIndex) []IndexDrift {
+	var drift []IndexDrift
+	found := map[string]existingIndex{}
+	for _, index := range actual {
+		found[index.Name] = index
+	}
+	names := map[string]bool{}
+	for _, index := range declared {
+		names[index.Name] = true
+		existing, ok := found[index.Name]
+		switch {
+		case !ok:
+			drift = append(drift, IndexDrift{Collection: collection, Index: index.Name, Problem: IndexMissing})
+		case !sameIndex(index, existing):
+			drift = append(drift, IndexDrift{Collection: collection, Index: index.Name, Problem: IndexChanged})
+		}
+	}
+	for _, index := range actual {
+		// every collection has the _id index
+		if !names[index.Name] && index.Name != "_id_" {
+			drift = append(drift, IndexDrift{Collection: collection, Index: index.Name, Problem: IndexExtra})
+		}
+	}
+	return drift
+}
+
+func sameIndex(declared Index, existing existingIndex) bool {
+	if declared.Unique != existing.Unique || len(declared.Keys) != len(existing.Key) {
+		return false
+	}
+	if declared.TTL {
+		if existing.ExpireAfterSeconds == nil || *existing.ExpireAfterSeconds != declared.expireSeconds() {
+			return false
+		}
+	} else if existing.ExpireAfterSeconds != nil {
+		return false
+	}
+	for i, key := range declared.Keys {
+		// the server can send the direction back as a int32, int64 or double
+		if key.Key != existing.Key[i].Key || fmt.Sprint(key.Value) != fmt.Sprint(existing.Key[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func listIndexes(ctx context.Context, c *mongo.Collection) ([]existingIndex, error) {
+	cur, err := c.Indexes().List(ctx)
+	if err != nil {
+		// the collection hasnt been made yet so it has no indexes
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && cmdErr.Name == "NamespaceNotFound" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var indexes []existingIndex
+	if err := cur.All(ctx, &indexes); err != nil {
+		return nil, err
+	}
+	return indexes, nil
+}
+
+// the model to make the index again the way it was
+func (e existingIndex) model() mongo.IndexModel {
+	opts := options.Index().SetName(e.Name)
+	if e.Unique {
+		opts.SetUnique(true)
+	}
+	if e.ExpireAfterSeconds != nil {
+		opts.SetExpireAfterSeconds(*e.ExpireAfterSeconds)
+	}
+	return mongo.IndexModel{Keys: e.Key, Options: opts}
+}
+
+func indexModel(index Index) mongo.IndexModel {
+	opts := options.Index().SetName(index.Name)
+	if index.Unique {
+		opts.SetUnique(true)
+	}
+	if index.TTL {
+		opts.SetExpireAfterSeconds(index.expireSeconds())
+	}
+	return mongo.IndexModel{Keys: index.Keys, Options: opts}
+}
+
+func registeredCollections() []string {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	collections := make([]string, 0, len(registry))
+	for collection := range registry {
+		collections = append(collections, collection)
+	}
+	// same order every time so the reports are easy to compare
+	sort.Strings(collections)
+	return collections
+}
+
+// the indexes declared for the collection, used by the in memory models to
+// act like the unique indexes
+func RegisteredIndexes(collection string) []Index {
+	return registered(collection)
+}
+
+func registered(collection string) []Index {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	return registry[collection]
+}
+
+func findIndex(collection string, name string) Index {
+	for _, index := range registered(collection) {
+		if index.Name == name {
+			return index
+		}
+	}
+	return Index{}
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDiffIndexes(t *testing.T) {
+	zero := int32(0)
+	hour := int32(3600)
+	username := Index{Name: "username_1", Keys: bson.D{primitive.E{Key: "username", Value: 1}}, Unique: true}
+	expires := Index{Name: "expires_at_1", Keys: bson.D{primitive.E{Key: "expires_at", Value: 1}}, TTL: true}
+	testtable := []struct {
+		declared []Index
+		actual   []existingIndex
+		expected []IndexDrift
+	}{
+		{declared: []Index{username}, actual: []existingIndex{{Name: "_id_", Key: bson.D{primitive.E{Key: "_id", Value: int32(1)}}}}, expected: []IndexDrift{{Collection: "c", Index: "username_1", Problem: IndexMissing}}},
+		// the server sends the direction back as a int32
+		{declared: []Index{username}, actual: []existingIndex{{Name: "username_1", Key: bson.D{primitive.E{Key: "username", Value: int32(1)}}, Unique: true}}, expected: nil},
+		{declared: []Index{username}, actual: []existingIndex{{Name: "username_1", Key: bson.D{primitive.E{Key: "username", Value: int32(1)}}}}, expected: []IndexDrift{{Collection: "c", Index: "username_1", Problem: IndexChanged}}},
+		{declared: []Index{username}, actual: []existingIndex{{Name: "username_1", Key: bson.D{primitive.E{Key: "username", Value: int32(-1)}}, Unique: true}}, expected: []IndexDrift{{Collection: "c", Index: "username_1", Problem: IndexChanged}}},
+		{declared: []Index{expires}, actual: []existingIndex{{Name: "expires_at_1", Key: bson.D{primitive.E{Key: "expires_at", Value: 1.0}}, ExpireAfterSeconds: &zero}}, expected: nil},
+		{declared: []Index{{Name: "expires_at_1", Keys: expires.Keys, TTL: true, ExpireAfter: time.Hour}}, actual: []existingIndex{{Name: "expires_at_1", Key: bson.D{primitive.E{Key: "expires_at", Value: int32(1)}}, ExpireAfterSeconds: &zero}}, expected: []IndexDrift{{Collection: "c", Index: "expires_at_1", Problem: IndexChanged}}},
+		{declared: nil, actual: []existingIndex{{Name: "old_1", Key: bson.D{primitive.E{Key: "old", Value: int32(1)}}, ExpireAfterSeconds: &hour}}, expected: []IndexDrift{{Collection: "c", Index: "old_1", Problem: IndexExtra}}},
+	}
+	for i, tt := range testtable {
+		got := diffIndexes("c", tt.declared, tt.actual)
+		if len(got) != len(tt.expected) {
+			t.Fatalf("case %d wrong number of differences, got=%v, want=%v", i, got, tt.expected)
+		}
+		for j := range got {
+			if got[j] != tt.expected[j] {
+				t.Errorf("case %d wrong difference, got=%v, want=%v", i, got[j], tt.expected[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// if the server can do transactions, saved per client so the server is
+// only asked once
+var transactionSupport sync.Map
+
+// runs fn as one transaction, fn has to pass the ctx it is given to every
+// database call or the call wont be part of the transaction. fn can be run
+// more than once if the transaction has to be retried so it shouldnt do
+// anything other than database calls.
+//
+// a standalone server cant do transactions, fn is just run on its own there
+// so a failure part way through can leave the earlier writes in place
+func Transaction(ctx context.Context, db *mongo.Database, fn func(ctx context.Context) error) error {
+	client := db.Client()
+	supported, err := SupportsTransactions(ctx, client)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return fn(ctx)
+	}
+	return client.UseSession(ctx, func(sc mongo.SessionContext) error {
+		_, err := sc.WithTransaction(sc, func(sc mongo.SessionContext) (interface{}, error) {
+			return nil, fn(sc)
+		})
+		return err
+	})
+}
+
+// transactions only work on replica sets and sharded clusters (mongos)
+func SupportsTransactions(ctx context.Context, client *mongo.Client) (bool, error) {
+	if supported, ok := transactionSupport.Load(client); ok {
+		return supported.(bool), nil
+	}
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	command := bson.D{primitive.E{Key: "hello", Value: 1}}
+	if err := client.Database("admin").RunCommand(ctx, command).Decode(&hello); err != nil {
+		return false, err
+	}
+	supported := hello.SetName != "" || hello.Msg == "isdbgrid"
+	transactionSupport.Store(client, supported)
+	return supported, nil
+}
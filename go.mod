@@ -6,6 +6,10 @@ require github.com/joho/godotenv v1.5.1 // direct
 
 require golang.org/x/crypto v0.12.0 // direct
 
+require github.com/golang-jwt/jwt/v5 v5.0.0 // direct
+
+require go.mongodb.org/mongo-driver v1.12.1 // direct
+
 require (
 	github.com/golang/snappy v0.0.1 // indirect
 	github.com/klauspost/compress v1.13.6 // indirect
@@ -14,7 +18,6 @@ require (
 	github.com/xdg-go/scram v1.1.2 // indirect
 	github.com/xdg-go/stringprep v1.0.4 // indirect
 	github.com/youmark/pkcs8 v0.0.0-20181117223130-1be2e3e5546d // indirect
-	go.mongodb.org/mongo-driver v1.12.1 // indirect
 	golang.org/x/sync v0.0.0-20220722155255-886fb9371eb4 // indirect
 	golang.org/x/text v0.12.0 // indirect
 )
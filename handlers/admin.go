@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"social-api/helpers"
+	"social-api/logger"
+	"social-api/model"
+	"social-api/router"
+	"social-api/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// handles the admin only endpoints, every route here should be behind
+// RequireAuth and the Authorizer
+type AdminHandler struct {
+	db    model.Modeler[*types.Users, bson.D]
+	authz *Authorizer
+	log   logger.Logger
+}
+
+func NewAdminHandler(db model.Modeler[*types.Users, bson.D], authz *Authorizer, logFilePath string) *AdminHandler {
+	l := logger.NewLogger()
+	file, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		panic("error when making the log file for admin routes" + err.Error())
+	}
+	InfoLogger := log.New(file, "INFO: ", log.Ldate|log.Ltime)
+	WarningLogger := log.New(file, "WARNING: ", log.Ldate|log.Ltime)
+	ErrorLogger := log.New(file, "ERROR: ", log.Ldate|log.Ltime)
+	FatalLogger := log.New(file, "FATAL: ", log.Ldate|log.Ltime)
+	l.AddLogger(logger.INFO, InfoLogger)
+	l.AddLogger(logger.WARNING, WarningLogger)
+	l.AddLogger(logger.ERROR, ErrorLogger)
+	l.AddLogger(logger.FATAL, FatalLogger)
+	return &AdminHandler{
+		db:    db,
+		authz: authz,
+		log:   l,
+	}
+}
+
+// gives the user with the id the role from the request body
+func (adh *AdminHandler) GrantRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	request, parseError := helpers.ParseBody(r, types.RoleRequest{})
+	if parseError != nil {
+		helpers.HandleParserError(parseError, w, r, adh.log)
+		return
+	}
+	if !helpers.ValidRequest(w, r, request) {
+		return
+	}
+	adh.changeRole(w, r, id, request.Role, "$addToSet")
+}
+
+// takes the role away from the user with the id
+func (adh *AdminHandler) RevokeRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	role := router.Param(r, "role")
+	caller, ok := requestingUser(w, r)
+	if !ok {
+		return
+	}
+	// stop admins from locking themselfs out
+	if caller.UserID == id && types.Role(role) == types.RoleAdmin {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeForbidden, "cannot revoke your own admin role"))
+		return
+	}
+	adh.changeRole(w, r, id, types.Role(role), "$pull")
+}
+
+// op is the mongo update operator used on the roles array ($addToSet or $pull)
+func (adh *AdminHandler) changeRole(w http.ResponseWriter, r *http.Request, userId primitive.ObjectID, role types.Role, op string) {
+	if !types.ValidRole(role) {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeBadRequest, "unknown role given"))
+		return
+	}
+	key := bson.D{primitive.E{Key: "_id", Value: userId}}
+	if _, dbErr := adh.db.GetEntry(r.Context(), key); dbErr != nil {
+		helpers.HandleDbError(dbErr, w, r, adh.log, "error when getting user with id "+userId.Hex())
+		return
+	}
+	val := bson.D{
+		primitive.E{Key: op, Value: bson.D{primitive.E{Key: "roles", Value: role}}},
+		primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "updated_at", Value: time.Now()}}},
+	}
+	if err := adh.db.ModifyEntry(r.Context(), key, val); err != nil {
+		helpers.HandleDbError(err, w, r, adh.log, "error when changing the users roles")
+		return
+	}
+	adh.log.WriteToLogger(logger.INFO, op+" role "+string(role)+" for user "+userId.Hex())
+	helpers.WriteMessage(w, http.StatusOK, "roles have been updated")
+}
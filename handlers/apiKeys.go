@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"social-api/helpers"
+	"social-api/logger"
+	"social-api/types"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func buildAPIKeyDataBaseType(key *types.APIKeys) bson.D {
+	return bson.D{
+		primitive.E{Key: "_id", Value: key.KeyID},
+		primitive.E{Key: "userId", Value: key.UserID},
+		primitive.E{Key: "name", Value: key.Name},
+		primitive.E{Key: "hint", Value: key.Hint},
+		primitive.E{Key: "keyHash", Value: key.KeyHash},
+		primitive.E{Key: "scopes", Value: key.Scopes},
+		primitive.E{Key: "created_at", Value: key.CreatedAt},
+		primitive.E{Key: "last_used", Value: key.LastUsed},
+	}
+}
+
+// what gets sent to the client when listing the keys (no hash)
+type apiKeyResponse struct {
+	KeyID     string        `json:"keyId"`
+	Name      string        `json:"name"`
+	Hint      string        `json:"hint"`
+	Scopes    []types.Scope `json:"scopes"`
+	CreatedAt time.Time     `json:"created_at"`
+	LastUsed  *time.Time    `json:"last_used"`
+	// only set when the key is made, it cant be seen again after that
+	Key string `json:"key,omitempty"`
+}
+
+func newAPIKeyResponse(key *types.APIKeys) apiKeyResponse {
+	return apiKeyResponse{
+		KeyID:     key.KeyID.Hex(),
+		Name:      key.Name,
+		Hint:      key.Hint,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+		LastUsed:  key.LastUsed,
+	}
+}
+
+// key has already been checked to start with the api key prefix
+func (ah *AuthHandler) authenticateAPIKey(w http.ResponseWriter, r *http.Request, rawKey string, scope types.Scope, next http.HandlerFunc) {
+	if scope == "" {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeMissingScope, "api keys cannot be used on this endpoint"))
+		return
+	}
+	apiKey, keyErr := ah.apiKeys.GetEntry(r.Context(), bson.D{primitive.E{Key: "keyHash", Value: helpers.HashToken(rawKey)}})
+	if keyErr != nil {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidToken, "invalid api key"))
+		return
+	}
+	if !types.HasScope(apiKey, scope) {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeMissingScope, "api key is missing the "+string(scope)+" scope"))
+		return
+	}
+	user, dbErr := ah.db.GetEntry(r.Context(), bson.D{primitive.E{Key: "_id", Value: apiKey.UserID}})
+	if dbErr != nil {
+		ah.log.WriteToLogger(logger.WARNING, "api key for unknown user", dbErr)
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidToken, "user for api key no longer exists"))
+		return
+	}
+	val := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "last_used", Value: time.Now()}}}}
+	if err := ah.apiKeys.ModifyEntry(r.Context(), bson.D{primitive.E{Key: "_id", Value: apiKey.KeyID}}, val); err != nil {
+		ah.log.WriteToLogger(logger.ERROR, "error when updating api key last used", err)
+	}
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	next(w, r.WithContext(ctx))
+}
+
+// the api key routes are under the users id, only the owner can use them
+func ownsPath(w http.ResponseWriter, r *http.Request, user *types.Users, id primitive.ObjectID) bool {
+	if user.UserID != id {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeForbidden, "you can only manage your own api keys"))
+		return false
+	}
+	return true
+}
+
+// makes a new api key, the key is only sent back this one time
+func (ah *AuthHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	user, ok := requestingUser(w, r)
+	if !ok || !ownsPath(w, r, user, id) {
+		return
+	}
+	request, parseError := helpers.ParseBody(r, types.APIKeyRequest{})
+	if parseError != nil {
+		helpers.HandleParserError(parseError, w, r, ah.log)
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if !helpers.ValidRequest(w, r, request) {
+		return
+	}
+	for _, scope := range request.Scopes {
+		if !types.ValidScope(scope) {
+			helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeBadRequest, "unknown scope given: "+string(scope)))
+			return
+		}
+	}
+	random, tokenErr := helpers.NewRandomToken()
+	if tokenErr != nil {
+		ah.log.WriteToLogger(logger.ERROR, "error when making api key", tokenErr)
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInternal, "internal server error"))
+		return
+	}
+	rawKey := types.APIKeyPrefix + random
+	apiKey := types.NewAPIKey(user.UserID, request.Name, request.Scopes)
+	apiKey.KeyHash = helpers.HashToken(rawKey)
+	apiKey.Hint = rawKey[len(rawKey)-4:]
+	if err := ah.apiKeys.AddEntry(r.Context(), buildAPIKeyDataBaseType(apiKey)); err != nil {
+		helpers.HandleDbError(err, w, r, ah.log, "error when adding the api key")
+		return
+	}
+	ah.log.WriteToLogger(logger.INFO, "api key "+apiKey.KeyID.Hex()+" made for user "+user.UserID.Hex())
+	response := newAPIKeyResponse(apiKey)
+	response.Key = rawKey
+	helpers.WriteJSON(w, http.StatusCreated, response)
+}
+
+// lists the api keys of the user
+func (ah *AuthHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	user, ok := requestingUser(w, r)
+	if !ok || !ownsPath(w, r, user, id) {
+		return
+	}
+	p, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+	filter := p.filter(bson.D{primitive.E{Key: "userId", Value: user.UserID}})
+	keys, dbErr := ah.apiKeys.GetEntryAdvanced(r.Context(), filter, p.sort(), p.options()...)
+	if dbErr != nil {
+		helpers.HandleDbError(dbErr, w, r, ah.log, "error when getting the api keys")
+		return
+	}
+	keys, next := pageOf(p, keys, func(k *types.APIKeys) primitive.ObjectID { return k.KeyID })
+	response := make([]apiKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, newAPIKeyResponse(key))
+	}
+	helpers.WritePage(w, http.StatusOK, response, next)
+}
+
+// changes the name of a api key (the scopes cant be changed, make a new key instead)
+func (ah *AuthHandler) RenameAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	keyId, ok := pathID(w, r, "keyId")
+	if !ok {
+		return
+	}
+	user, ok := requestingUser(w, r)
+	if !ok || !ownsPath(w, r, user, id) {
+		return
+	}
+	request, parseError := helpers.ParseBody(r, types.RenameAPIKeyRequest{})
+	if parseError != nil {
+		helpers.HandleParserError(parseError, w, r, ah.log)
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if !helpers.ValidRequest(w, r, request) {
+		return
+	}
+	key, found := ah.userAPIKey(w, r, user, keyId)
+	if !found {
+		return
+	}
+	val := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "name", Value: request.Name}}}}
+	if err := ah.apiKeys.ModifyEntry(r.Context(), key, val); err != nil {
+		helpers.HandleDbError(err, w, r, ah.log, "error when renaming the api key")
+		return
+	}
+	helpers.WriteMessage(w, http.StatusOK, "api key has been renamed")
+}
+
+// deletes the api key so it cant be used anymore
+func (ah *AuthHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	keyId, ok := pathID(w, r, "keyId")
+	if !ok {
+		return
+	}
+	user, ok := requestingUser(w, r)
+	if !ok || !ownsPath(w, r, user, id) {
+		return
+	}
+	key, found := ah.userAPIKey(w, r, user, keyId)
+	if !found {
+		return
+	}
+	if err := ah.apiKeys.RemoveEntry(r.Context(), key); err != nil {
+		helpers.HandleDbError(err, w, r, ah.log, "error when removing the api key")
+		return
+	}
+	ah.log.WriteToLogger(logger.INFO, "api key "+keyId.Hex()+" revoked for user "+user.UserID.Hex())
+	helpers.WriteMessage(w, http.StatusOK, "api key has been revoked")
+}
+
+// returns the filter for the key if it exists and belongs to the user,
+// writes the error response and returns false otherwise
+func (ah *AuthHandler) userAPIKey(w http.ResponseWriter, r *http.Request, user *types.Users, keyId primitive.ObjectID) (bson.D, bool) {
+	key := bson.D{
+		primitive.E{Key: "_id", Value: keyId},
+		primitive.E{Key: "userId", Value: user.UserID},
+	}
+	if _, dbErr := ah.apiKeys.GetEntry(r.Context(), key); dbErr != nil {
+		helpers.HandleDbError(dbErr, w, r, ah.log, "error when getting the api key")
+		return nil, false
+	}
+	return key, true
+}
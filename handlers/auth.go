@@ -1,15 +1,16 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
-	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"social-api/helpers"
 	"social-api/logger"
+	"social-api/mailer"
 	"social-api/model"
+	"social-api/throttle"
 	"social-api/types"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -26,9 +27,14 @@ func buildDataBaseType(user *types.Users) bson.D {
 		primitive.E{Key: "password", Value: user.Password},
 		primitive.E{Key: "profilePicture", Value: user.ProfilePic},
 		primitive.E{Key: "coverPicture", Value: user.CoverPic},
-		primitive.E{Key: "follwers", Value: user.Follwers},
-		primitive.E{Key: "follwings", Value: user.Follwings},
-		primitive.E{Key: "isAdmin", Value: user.IsAdmin},
+		primitive.E{Key: "followers", Value: user.Followers},
+		primitive.E{Key: "followings", Value: user.Followings},
+		primitive.E{Key: "roles", Value: user.Roles},
+		primitive.E{Key: "totpSecret", Value: user.TOTPSecret},
+		primitive.E{Key: "totpEnabled", Value: user.TOTPEnabled},
+		primitive.E{Key: "recoveryCodes", Value: user.RecoveryCodes},
+		primitive.E{Key: "totpLastStep", Value: user.TOTPLastStep},
+		primitive.E{Key: "emailVerified", Value: user.EmailVerified},
 		primitive.E{Key: "desc", Value: user.Desc},
 		primitive.E{Key: "city", Value: user.City},
 		primitive.E{Key: "from", Value: user.From},
@@ -38,22 +44,38 @@ func buildDataBaseType(user *types.Users) bson.D {
 	}
 }
 
-// checks if the given secret matches the admin password
-func vailidAdminPassword(secret string) bool {
-	pass := os.Getenv("AdminSecretPassword")
-	return pass == secret
-
+// base url of the web client, used to build the links sent in emails
+func appURL() string {
+	url := os.Getenv("APP_URL")
+	if url == "" {
+		return "http://" + os.Getenv("HOST") + ":" + os.Getenv("PORT")
+	}
+	return url
 }
 
 // this needs to be the type to handle all of the
 // authorization for the users, will use the modeler interface
 // to interact with the database
 type AuthHandler struct {
-	db  model.Modeler[*types.Users, bson.D]
-	log logger.Logger
+	db       model.UserStore
+	sessions model.SessionStore
+	tokens   model.UserTokenStore
+	apiKeys  model.Modeler[*types.APIKeys, bson.D]
+	// writes that change more than one of the collections above go through this
+	tx   model.Transactor
+	mail mailer.Mailer
+	// rules for new passwords and the bcrypt cost to hash them with
+	passwords *helpers.PasswordPolicy
+	// compared against when the user doesnt exist so the login takes as long
+	// as a wrong password and cant be used to find accounts
+	dummyHash string
+	// failed logins are counted per account and per ip
+	accountThrottle *throttle.Throttler
+	ipThrottle      *throttle.Throttler
+	log             logger.Logger
 }
 
-func NewAuthHandler(db model.Modeler[*types.Users, bson.D], logFilePath string) *AuthHandler {
+func NewAuthHandler(db model.UserStore, sessions model.SessionStore, tokens model.UserTokenStore, apiKeys model.Modeler[*types.APIKeys, bson.D], tx model.Transactor, mail mailer.Mailer, passwords *helpers.PasswordPolicy, accountThrottle *throttle.Throttler, ipThrottle *throttle.Throttler, logFilePath string) *AuthHandler {
 	l := logger.NewLogger()
 	file, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
 	if err != nil {
@@ -67,32 +89,32 @@ func NewAuthHandler(db model.Modeler[*types.Users, bson.D], logFilePath string)
 	l.AddLogger(logger.WARNING, WarningLogger)
 	l.AddLogger(logger.ERROR, ErrorLogger)
 	l.AddLogger(logger.FATAL, FatalLogger)
-	return &AuthHandler{
-		db:  db,
-		log: l,
+	dummyHash, hashErr := passwords.Hash("not the password of any account")
+	if hashErr != nil {
+		panic("error when making the dummy password hash" + hashErr.Error())
 	}
-}
-
-func (ah *AuthHandler) Test(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte("hello this is the auth handler test"))
-	id, _ := primitive.ObjectIDFromHex("633356b45715fd08fc68798e")
-	filter := bson.D{primitive.E{Key: "_id", Value: id}}
-	user, err := ah.db.GetEntry(filter)
-	ah.log.WriteToLogger(logger.INFO, "test endpoint was hit")
-	if err != nil {
-		fmt.Println(err)
+	return &AuthHandler{
+		db:              db,
+		sessions:        sessions,
+		tokens:          tokens,
+		apiKeys:         apiKeys,
+		tx:              tx,
+		mail:            mail,
+		passwords:       passwords,
+		dummyHash:       dummyHash,
+		accountThrottle: accountThrottle,
+		ipThrottle:      ipThrottle,
+		log:             l,
 	}
-	fmt.Printf("%+v", user)
 }
 
 // handle to login of the user and send the user data to the client
 // login only needs email or username and password
 func (ah *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
-	requestUser, parseError := helpers.ParseBody(r.Body, types.AuthUserRequest{})
+	requestUser, parseError := helpers.ParseBody(r, types.AuthUserRequest{})
 	if parseError != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("error when parsing the request"))
-		fmt.Println(parseError)
+		helpers.HandleParserError(parseError, w, r, ah.log)
+		return
 	}
 	var searchKey string
 	var searchParam string
@@ -103,82 +125,108 @@ func (ah *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		searchKey = "username"
 		searchParam = requestUser.UserName
 	} else {
-		w.WriteHeader(http.StatusForbidden)
-		w.Write([]byte("either username or email is required with password"))
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeBadRequest, "either username or email is required with password"))
+		return
+	}
+	if !ah.reserveThrottle(w, r, ah.ipThrottle, clientIP(r)) {
+		return
 	}
-	fmt.Println(searchParam)
 	key := bson.D{primitive.E{Key: searchKey, Value: searchParam}}
-	dbUser, dbErr := ah.db.GetEntry(key)
+	dbUser, dbErr := ah.db.GetEntry(r.Context(), key)
 	if dbErr != nil {
 		if errors.Is(dbErr, mongo.ErrNoDocuments) {
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("user not found in database"))
+			// same throttle, work and error as a wrong password so the login
+			// cant be used to check which accounts exist
+			if !ah.reserveThrottle(w, r, ah.accountThrottle, unknownAccountKey(searchParam)) {
+				return
+			}
+			bcrypt.CompareHashAndPassword([]byte(ah.dummyHash), []byte(requestUser.Password))
+			helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidCredentials, "incorrect username or password"))
 		} else {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("unknow server error"))
-			fmt.Println("unknown error when getting user from db", dbErr)
+			helpers.HandleDbError(dbErr, w, r, ah.log, "unknown error when getting user from db")
 		}
 		return
 	}
-	fmt.Println(dbUser)
+	// keyed on the id so the username and the email share one count
+	accountKey := dbUser.UserID.Hex()
+	if !ah.reserveThrottle(w, r, ah.accountThrottle, accountKey) {
+		return
+	}
 	// returns nil if the passwords are the same
 	correctUser := bcrypt.CompareHashAndPassword([]byte(dbUser.Password), []byte(requestUser.Password))
 	if correctUser != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("incorrect password given"))
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidCredentials, "incorrect username or password"))
+		return
+	}
+	ah.succeedThrottle(r, accountKey)
+	// hashes made before the cost was raised get upgraded while we have the plain password
+	if ah.passwords.NeedsRehash(dbUser.Password) {
+		ah.upgradePasswordHash(r.Context(), dbUser, requestUser.Password)
+	}
+	// the password was right but the user still needs to give their 2fa code
+	if dbUser.TOTPEnabled {
+		ah.sendTwoFactorChallenge(w, r, dbUser)
 		return
-	} else {
-		// dont send the password hash to the client
-		dbUser.Password = "************"
-		w.WriteHeader(http.StatusOK)
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(dbUser)
 	}
+	ah.startSession(w, r, dbUser)
 }
 
 // will handle the creation of the user in the database, will send user data back after creation
 func (ah *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
-	requestUser, parseError := helpers.ParseBody(r.Body, types.AuthUserRequest{})
+	requestUser, parseError := helpers.ParseBody(r, types.AuthUserRequest{})
 	if parseError != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("error when parsing the request"))
-		fmt.Println(parseError)
+		helpers.HandleParserError(parseError, w, r, ah.log)
+		return
 	}
-	if !types.ValidAuthUser(requestUser) {
-		w.WriteHeader(http.StatusForbidden)
-		w.Write([]byte("invalid user given"))
+	if !helpers.ValidRequest(w, r, requestUser) {
 		return
 	}
-	hashedPass, hashErr := bcrypt.GenerateFromPassword([]byte(requestUser.Password), bcrypt.DefaultCost)
+	if err := ah.passwords.Check(requestUser.Password, requestUser.UserName, requestUser.Email); err != nil {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeWeakPassword, err.Error()))
+		return
+	}
+	hashedPass, hashErr := ah.passwords.Hash(requestUser.Password)
 	if hashErr != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("internal server error"))
-		fmt.Printf("error when hashing the user password: %v", hashErr)
+		ah.log.WriteToLogger(logger.ERROR, "error when hashing the user password", hashErr)
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInternal, "internal server error"))
+		return
 	}
 	user := types.NewUser()
 	// this only covers the basic fields to make the user
 	// need to add all the other feild that get the default values from NewUser
 	user.Email = requestUser.Email
 	user.Username = requestUser.UserName
-	user.Password = string(hashedPass)
-	if requestUser.AdminSecret != "" {
-		if !vailidAdminPassword(requestUser.AdminSecret) {
-			w.WriteHeader(http.StatusForbidden)
-			w.Write([]byte("invalid atempt to make a admin account"))
-			// dont create any user if failed admin attempt
-			return
-		}
-		user.IsAdmin = true
-	}
+	user.Password = hashedPass
 	dbUser := buildDataBaseType(user)
-	ah.db.AddEntry(dbUser)
-	fmt.Printf("%+v", dbUser)
-	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte("user successfully registed"))
+	if err := ah.db.AddEntry(r.Context(), dbUser); err != nil {
+		helpers.HandleDbError(err, w, r, ah.log, "error when adding the user")
+		return
+	}
+	// the account can still be used if the email fails, the user can ask for another one
+	if err := ah.sendVerification(r.Context(), user); err != nil {
+		ah.log.WriteToLogger(logger.ERROR, "error when sending verification email", err)
+	}
+	helpers.WriteMessage(w, http.StatusCreated, "user successfully registed, check your email to verify the account")
+}
+
+// rehashes the password with the current cost, the login still works if this fails
+func (ah *AuthHandler) upgradePasswordHash(ctx context.Context, user *types.Users, password string) {
+	hashedPass, hashErr := ah.passwords.Hash(password)
+	if hashErr != nil {
+		ah.log.WriteToLogger(logger.ERROR, "error when rehashing the user password", hashErr)
+		return
+	}
+	key := bson.D{primitive.E{Key: "_id", Value: user.UserID}}
+	val := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "password", Value: hashedPass}}}}
+	if err := ah.db.ModifyEntry(ctx, key, val); err != nil {
+		ah.log.WriteToLogger(logger.ERROR, "error when saving the rehashed password", err)
+		return
+	}
+	user.Password = hashedPass
+	ah.log.WriteToLogger(logger.INFO, "password hash upgraded for user "+user.UserID.Hex())
 }
 
 func (ah *AuthHandler) HandleNotFound(w http.ResponseWriter, r *http.Request, msg string) {
 	ah.log.WriteToLogger(logger.WARNING, "invalid url was given to post handlers"+r.URL.Path)
-	w.WriteHeader(http.StatusNotFound)
-	w.Write([]byte(msg))
+	helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeNotFound, msg))
 }
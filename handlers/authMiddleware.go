@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"social-api/helpers"
+	"social-api/logger"
+	"social-api/types"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type contextKey string
+
+// keys used to store the logged in user and their session on the request context
+const (
+	userContextKey    contextKey = "user"
+	sessionContextKey contextKey = "session"
+)
+
+// will get the user that was put on the request by RequireAuth
+// the bool is false if the request was never authenticated
+func UserFromContext(r *http.Request) (*types.Users, bool) {
+	user, ok := r.Context().Value(userContextKey).(*types.Users)
+	return user, ok && user != nil
+}
+
+// will get the id of the session the access token was made for
+func sessionFromContext(r *http.Request) (primitive.ObjectID, bool) {
+	id, ok := r.Context().Value(sessionContextKey).(primitive.ObjectID)
+	return id, ok
+}
+
+// middleware that checks the bearer token on the request and puts the
+// user it belongs to on the request context before calling next
+// (only accepts access tokens from a login, not api keys)
+func (ah *AuthHandler) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return ah.authenticate("", next)
+}
+
+// same as RequireAuth but also lets in api keys that have the scope
+func (ah *AuthHandler) RequireScope(scope types.Scope) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return ah.authenticate(scope, next)
+	}
+}
+
+// scope is the api key scope the route accepts, empty if the route
+// can only be used with a access token
+func (ah *AuthHandler) authenticate(scope types.Scope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || token == "" {
+			helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeUnauthorized, "missing access token"))
+			return
+		}
+		if strings.HasPrefix(token, types.APIKeyPrefix) {
+			ah.authenticateAPIKey(w, r, token, scope, next)
+			return
+		}
+		userId, sessionId, tokenErr := helpers.ParseAccessToken(token)
+		if tokenErr != nil {
+			ah.log.WriteToLogger(logger.WARNING, "request with bad access token to "+r.URL.Path, tokenErr)
+			helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidToken, "invalid or expired access token"))
+			return
+		}
+		id, hexErr := primitive.ObjectIDFromHex(userId)
+		sid, sidErr := primitive.ObjectIDFromHex(sessionId)
+		if hexErr != nil || sidErr != nil {
+			helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidToken, "invalid or expired access token"))
+			return
+		}
+		// the token is only good as long as its session hasnt been revoked
+		sessionKey := bson.D{
+			primitive.E{Key: "_id", Value: sid},
+			primitive.E{Key: "userId", Value: id},
+		}
+		if _, sessionErr := ah.sessions.GetEntry(r.Context(), sessionKey); sessionErr != nil {
+			helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidToken, "session has been revoked"))
+			return
+		}
+		user, dbErr := ah.db.GetEntry(r.Context(), bson.D{primitive.E{Key: "_id", Value: id}})
+		if dbErr != nil {
+			// the user could have been deleted after the token was made
+			ah.log.WriteToLogger(logger.WARNING, "access token for unknown user", dbErr)
+			helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidToken, "user for access token no longer exists"))
+			return
+		}
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		ctx = context.WithValue(ctx, sessionContextKey, sid)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// gets the logged in user for handlers behind RequireAuth, will write a 401
+// and return false if the route was not wrapped with the middleware
+func requestingUser(w http.ResponseWriter, r *http.Request) (*types.Users, bool) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeUnauthorized, "need to be logged in to use this endpoint"))
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"social-api/helpers"
+	"social-api/logger"
+	"social-api/model"
+	"social-api/types"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func buildAuditDataBaseType(entry *types.AuditEntries) bson.D {
+	return bson.D{
+		primitive.E{Key: "_id", Value: entry.AuditID},
+		primitive.E{Key: "actorId", Value: entry.ActorID},
+		primitive.E{Key: "permission", Value: entry.Permission},
+		primitive.E{Key: "target", Value: entry.Target},
+		primitive.E{Key: "allowed", Value: entry.Allowed},
+		primitive.E{Key: "method", Value: entry.Method},
+		primitive.E{Key: "path", Value: entry.Path},
+		primitive.E{Key: "created_at", Value: entry.CreatedAt},
+	}
+}
+
+// shared by all of the handlers that have actions gated by a permission,
+// every check goes into the audit log
+type Authorizer struct {
+	audit model.Modeler[*types.AuditEntries, bson.D]
+	log   logger.Logger
+}
+
+func NewAuthorizer(audit model.Modeler[*types.AuditEntries, bson.D], log logger.Logger) *Authorizer {
+	return &Authorizer{
+		audit: audit,
+		log:   log,
+	}
+}
+
+// checks if the user has the permission and records the attempt,
+// target is the id of whatever the action is being done to
+func (az *Authorizer) Can(r *http.Request, user *types.Users, perm types.Permission, target string) bool {
+	allowed := types.HasPermission(user.Roles, perm)
+	entry := types.NewAuditEntry(user.UserID, perm, target, allowed)
+	entry.Method = r.Method
+	entry.Path = r.URL.Path
+	if err := az.audit.AddEntry(r.Context(), buildAuditDataBaseType(entry)); err != nil {
+		// dont block the action because the audit log is down, but make some noise
+		az.log.WriteToLogger(logger.ERROR, "error when recording audit entry for "+string(perm), err)
+	}
+	if !allowed {
+		az.log.WriteToLogger(logger.WARNING, "user "+user.UserID.Hex()+" denied "+string(perm)+" on "+target)
+	}
+	return allowed
+}
+
+// middleware for routes that always need the permission, needs to run after RequireAuth
+func (az *Authorizer) Require(perm types.Permission) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			user, ok := requestingUser(w, r)
+			if !ok {
+				return
+			}
+			if !az.Can(r, user, perm, r.URL.Path) {
+				helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeForbidden, "you do not have permission to do this"))
+				return
+			}
+			next(w, r)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"social-api/helpers"
+	"social-api/logger"
+	"social-api/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// how many replies deep a comment can be, 0 turns replies off
+// (main sets this from the env at boot)
+var CommentDepth = 3
+
+func buildCommentDataBaseType(comment *types.Comments) bson.D {
+	val := bson.D{
+		primitive.E{Key: "_id", Value: comment.CommentID},
+		primitive.E{Key: "postId", Value: comment.PostID},
+		primitive.E{Key: "userId", Value: comment.UserID},
+		primitive.E{Key: "ancestors", Value: comment.Ancestors},
+		primitive.E{Key: "text", Value: comment.Text},
+		primitive.E{Key: "created_at", Value: comment.CreatedAt},
+		primitive.E{Key: "updated_at", Value: comment.UpdatedAt},
+	}
+	// comments on the post itself have no parentId so they can be listed with $exists
+	if comment.ParentID != nil {
+		val = append(val, primitive.E{Key: "parentId", Value: *comment.ParentID})
+	}
+	return val
+}
+
+// gets the post in the url, writes the error and returns false if it doesnt exist
+func (ph *PostHandler) pathPost(w http.ResponseWriter, r *http.Request) (*types.Posts, bool) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return nil, false
+	}
+	post, dbError := ph.db.GetEntry(r.Context(), bson.D{primitive.E{Key: "_id", Value: id}})
+	if dbError != nil {
+		helpers.HandleDbError(dbError, w, r, ph.log, fmt.Sprintf("error when getting post with id of %s", id.Hex()))
+		return nil, false
+	}
+	return post, true
+}
+
+// gets the comment in the url, it has to be on the post
+func (ph *PostHandler) pathComment(w http.ResponseWriter, r *http.Request, post *types.Posts) (*types.Comments, bool) {
+	id, ok := pathID(w, r, "commentId")
+	if !ok {
+		return nil, false
+	}
+	key := bson.D{
+		primitive.E{Key: "_id", Value: id},
+		primitive.E{Key: "postId", Value: post.PostID},
+	}
+	comment, dbError := ph.comments.GetEntry(r.Context(), key)
+	if dbError != nil {
+		helpers.HandleDbError(dbError, w, r, ph.log, fmt.Sprintf("error when getting comment with id of %s", id.Hex()))
+		return nil, false
+	}
+	return comment, true
+}
+
+// comments on the post, or replies to one of its comments when parentId is given
+func (ph *PostHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
+	caller, ok := requestingUser(w, r)
+	if !ok {
+		return
+	}
+	post, ok := ph.pathPost(w, r)
+	if !ok {
+		return
+	}
+	request, parseError := helpers.ParseBody(r, types.CommentRequest{})
+	if parseError != nil {
+		helpers.HandleParserError(parseError, w, r, ph.log)
+		return
+	}
+	if !helpers.ValidRequest(w, r, request) {
+		return
+	}
+	comment := types.NewComment(post.PostID, caller.UserID)
+	comment.Text = request.Text
+	if request.ParentID != "" {
+		parentId, err := primitive.ObjectIDFromHex(request.ParentID)
+		if err != nil {
+			helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidID, "parentId is not a valid id").WithDetails(map[string]string{"field": "parentId"}))
+			return
+		}
+		key := bson.D{
+			primitive.E{Key: "_id", Value: parentId},
+			primitive.E{Key: "postId", Value: post.PostID},
+		}
+		parent, dbError := ph.comments.GetEntry(r.Context(), key)
+		if dbError != nil {
+			helpers.HandleDbError(dbError, w, r, ph.log, "error when getting the comment being replied to")
+			return
+		}
+		if parent.Depth() >= CommentDepth {
+			helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeBadRequest, fmt.Sprintf("replies can only be %d deep", CommentDepth)))
+			return
+		}
+		comment.ReplyTo(parent)
+	}
+	if err := ph.comments.AddEntry(r.Context(), buildCommentDataBaseType(comment)); err != nil {
+		helpers.HandleDbError(err, w, r, ph.log, "failed to add comment to database")
+		return
+	}
+	ph.log.WriteToLogger(logger.INFO, "comment "+comment.CommentID.Hex()+" added to post "+post.PostID.Hex())
+	helpers.WriteJSONMessage(w, http.StatusCreated, types.NewCommentResponse(comment), "comment created")
+}
+
+// lists the comments on the post, or the replies to a comment with ?parent=
+func (ph *PostHandler) GetComments(w http.ResponseWriter, r *http.Request) {
+	post, ok := ph.pathPost(w, r)
+	if !ok {
+		return
+	}
+	p, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+	parentFilter := primitive.E{Key: "parentId", Value: bson.D{primitive.E{Key: "$exists", Value: false}}}
+	if rawParent := r.URL.Query().Get("parent"); rawParent != "" {
+		parentId, err := primitive.ObjectIDFromHex(rawParent)
+		if err != nil {
+			helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidID, "parent is not a valid id").WithDetails(map[string]string{"param": "parent"}))
+			return
+		}
+		parentFilter = primitive.E{Key: "parentId", Value: parentId}
+	}
+	filter := p.filter(bson.D{primitive.E{Key: "postId", Value: post.PostID}, parentFilter})
+	comments, err := ph.comments.GetEntryAdvanced(r.Context(), filter, p.sort(), p.options()...)
+	if err != nil {
+		helpers.HandleDbError(err, w, r, ph.log, "error when getting the comments of post "+post.PostID.Hex())
+		return
+	}
+	comments, next := pageOf(p, comments, func(comment *types.Comments) primitive.ObjectID { return comment.CommentID })
+	response := make([]types.CommentResponse, 0, len(comments))
+	for _, comment := range comments {
+		response = append(response, types.NewCommentResponse(comment))
+	}
+	helpers.WritePage(w, http.StatusOK, response, next)
+}
+
+// changes the text of a comment, only the person who wrote it can
+func (ph *PostHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	caller, ok := requestingUser(w, r)
+	if !ok {
+		return
+	}
+	post, ok := ph.pathPost(w, r)
+	if !ok {
+		return
+	}
+	comment, ok := ph.pathComment(w, r, post)
+	if !ok {
+		return
+	}
+	request, parseError := helpers.ParseBody(r, types.EditCommentRequest{})
+	if parseError != nil {
+		helpers.HandleParserError(parseError, w, r, ph.log)
+		return
+	}
+	if !helpers.ValidRequest(w, r, request) {
+		return
+	}
+	if comment.UserID != caller.UserID {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeForbidden, "not allowed to edit other peoples comments"))
+		return
+	}
+	key := bson.D{primitive.E{Key: "_id", Value: comment.CommentID}}
+	val := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "text", Value: request.Text},
+		primitive.E{Key: "updated_at", Value: time.Now()},
+	}}}
+	if err := ph.comments.ModifyEntry(r.Context(), key, val); err != nil {
+		helpers.HandleDbError(err, w, r, ph.log, "error when updating comment "+comment.CommentID.Hex())
+		return
+	}
+	helpers.WriteMessage(w, http.StatusOK, "comment was successfully updated")
+}
+
+// deletes the comment with all of its replies, the person who wrote it and
+// the owner of the post can always delete it
+func (ph *PostHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	caller, ok := requestingUser(w, r)
+	if !ok {
+		return
+	}
+	post, ok := ph.pathPost(w, r)
+	if !ok {
+		return
+	}
+	comment, ok := ph.pathComment(w, r, post)
+	if !ok {
+		return
+	}
+	if comment.UserID != caller.UserID && post.UserID != caller.UserID && !ph.authz.Can(r, caller, types.PermDeleteAnyComment, comment.CommentID.Hex()) {
+		ph.log.WriteToLogger(logger.WARNING, "attempt to delete someone elses comment")
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeForbidden, "not allowed to delete other peoples comments"))
+		return
+	}
+	if err := ph.comments.RemoveThread(r.Context(), comment.CommentID); err != nil {
+		helpers.HandleDbError(err, w, r, ph.log, "error when removing comment "+comment.CommentID.Hex())
+		return
+	}
+	helpers.WriteMessage(w, http.StatusOK, "comment has been deleted")
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"social-api/helpers"
+	"social-api/logger"
+	"social-api/mailer"
+	"social-api/types"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// how long the link in the verification email works for
+const verifyTokenDuration time.Duration = 24 * time.Hour
+
+// the token was sent to a different email than the one the user has now,
+// returned from the transaction so the token isnt used up
+var errTokenEmailChanged = errors.New("token was sent to a email the user no longer has")
+
+// makes a new verification token for the user and mails it to them
+func (ah *AuthHandler) sendVerification(ctx context.Context, user *types.Users) error {
+	rawToken, err := ah.issueUserToken(ctx, user, types.VerifyEmailToken, verifyTokenDuration)
+	if err != nil {
+		return err
+	}
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "verify your email",
+		Body: "welcome " + user.Username + ",\n" +
+			"use this link to verify your email (it expires in 24 hours):\n" +
+			appURL() + "/verify-email?token=" + rawToken + "\n\n" +
+			"or enter this code: " + rawToken,
+	}
+	return ah.mail.Send(msg)
+}
+
+// checks if the email is the one set to become the first admin
+func bootstrapAdmin(email string) bool {
+	adminEmail := os.Getenv("BOOTSTRAP_ADMIN_EMAIL")
+	return adminEmail != "" && strings.EqualFold(adminEmail, email)
+}
+
+// marks the email of the user the token belongs to as verified
+func (ah *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	request, parseError := helpers.ParseBody(r, types.VerifyEmailRequest{})
+	if parseError != nil {
+		helpers.HandleParserError(parseError, w, r, ah.log)
+		return
+	}
+	if !helpers.ValidRequest(w, r, request) {
+		return
+	}
+	// the token is only used up if the user is really marked as verified
+	var token *types.UserTokens
+	var adminEmail string
+	txErr := ah.tx.Transaction(r.Context(), func(ctx context.Context) error {
+		var err error
+		if token, err = ah.useUserToken(ctx, request.Token, types.VerifyEmailToken); err != nil || token == nil {
+			return err
+		}
+		key := bson.D{primitive.E{Key: "_id", Value: token.UserID}}
+		val := bson.D{primitive.E{Key: "$set", Value: bson.D{
+			primitive.E{Key: "emailVerified", Value: true},
+			primitive.E{Key: "updated_at", Value: time.Now()},
+		}}}
+		user, err := ah.db.GetEntry(ctx, key)
+		if err != nil {
+			return err
+		}
+		// only the email the token was sent to is verified by it
+		if user.Email != token.Email {
+			return errTokenEmailChanged
+		}
+		// the first admin cant be given the role by another admin, so the owner of
+		// the email in the env gets it once they prove they own the email
+		adminEmail = ""
+		if bootstrapAdmin(user.Email) {
+			val = append(val, primitive.E{Key: "$addToSet", Value: bson.D{primitive.E{Key: "roles", Value: types.RoleAdmin}}})
+			adminEmail = user.Email
+		}
+		return ah.db.ModifyEntry(ctx, key, val)
+	})
+	if errors.Is(txErr, errTokenEmailChanged) {
+		token, txErr = nil, nil
+	}
+	if txErr != nil {
+		helpers.HandleDbError(txErr, w, r, ah.log, "error when verifying the email")
+		return
+	}
+	if token == nil {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidToken, "invalid or expired verification token"))
+		return
+	}
+	if adminEmail != "" {
+		ah.log.WriteToLogger(logger.INFO, "bootstrap admin role given to "+adminEmail)
+	}
+	ah.log.WriteToLogger(logger.INFO, "email verified for user "+token.UserID.Hex())
+	helpers.WriteMessage(w, http.StatusOK, "email has been verified")
+}
+
+// sends a new verification email if the account is not verified yet, always
+// responds the same way so it cant be used to check if a email has a account
+func (ah *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	request, parseError := helpers.ParseBody(r, types.ResendVerificationRequest{})
+	if parseError != nil {
+		helpers.HandleParserError(parseError, w, r, ah.log)
+		return
+	}
+	if !helpers.ValidRequest(w, r, request) {
+		return
+	}
+	user, dbErr := ah.db.GetEntry(r.Context(), bson.D{primitive.E{Key: "email", Value: request.Email}})
+	if dbErr == nil && !user.EmailVerified {
+		if err := ah.sendVerification(r.Context(), user); err != nil {
+			ah.log.WriteToLogger(logger.ERROR, "error when resending verification email", err)
+		}
+	}
+	helpers.WriteMessage(w, http.StatusOK, "if the account is not verified a new email has been sent")
+}
+
+// middleware for routes that need a verified email, needs to run after RequireAuth
+func (ah *AuthHandler) RequireVerified(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requestingUser(w, r)
+		if !ok {
+			return
+		}
+		if !user.EmailVerified {
+			helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeEmailNotVerified, "need to verify your email before using this endpoint"))
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+	"social-api/helpers"
+	"social-api/router"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// gets the {name} path param as a ObjectID, ids are parsed here at the
+// edge so the models only ever get typed ids, writes a 400 and returns
+// false if the param is not a valid id
+func pathID(w http.ResponseWriter, r *http.Request, name string) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(router.Param(r, name))
+	if err != nil {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidID, name+" in the url is not a valid id").WithDetails(map[string]string{"param": name}))
+		return primitive.NilObjectID, false
+	}
+	return id, true
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"social-api/helpers"
+	"social-api/logger"
+	"social-api/throttle"
+	"strings"
+	"time"
+)
+
+// ip of the client without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// key for the account throttle when the login names a user that doesnt
+// exist, it gets throttled the same as a real account so the 429s cant be
+// used to find accounts either
+func unknownAccountKey(searchParam string) string {
+	return "unknown:" + strings.ToLower(searchParam)
+}
+
+// counts the login attempt for the key before the password is checked, writes
+// a 429 with Retry-After and returns false if the key has to wait. every
+// attempt is counted up front so parallel guesses cant all get past the check
+func (ah *AuthHandler) reserveThrottle(w http.ResponseWriter, r *http.Request, throttler *throttle.Throttler, key string) bool {
+	wait, err := throttler.Reserve(r.Context(), key, time.Now())
+	if err != nil {
+		// let the login through if the store is down instead of locking everyone out
+		ah.log.WriteToLogger(logger.ERROR, "error when checking the login throttle", err)
+		return true
+	}
+	if wait == 0 {
+		return true
+	}
+	ah.log.WriteToLogger(logger.WARNING, "login throttled for "+key+" from "+clientIP(r))
+	w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+	helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeTooManyRequests, "too many failed login attempts, try again later"))
+	return false
+}
+
+// clears the failures for the account after a successful login and gives
+// back the attempt counted for the ip (the rest of the ip count is left
+// alone so one good account cant be used to reset it)
+func (ah *AuthHandler) succeedThrottle(r *http.Request, account string) {
+	if err := ah.accountThrottle.Succeed(r.Context(), account); err != nil {
+		ah.log.WriteToLogger(logger.ERROR, "error when resetting login throttle", err)
+	}
+	if err := ah.ipThrottle.Release(r.Context(), clientIP(r)); err != nil {
+		ah.log.WriteToLogger(logger.ERROR, "error when resetting login throttle", err)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"social-api/helpers"
+	"social-api/model"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultPageSize int64 = 20
+	maxPageSize     int64 = 100
+)
+
+// page is the ?limit=&cursor=&order= of a list request. lists are sorted
+// by _id newest first (or oldest first with order=oldest) and the cursor is
+// the id of the last entry on the page before, so entries added while
+// paging dont shift the pages
+type page struct {
+	limit  int64
+	after  primitive.ObjectID
+	oldest bool
+}
+
+// reads the page from the query, writes a 400 and returns false if the
+// limit or cursor are not valid
+func pageParams(w http.ResponseWriter, r *http.Request) (page, bool) {
+	p := page{limit: defaultPageSize}
+	query := r.URL.Query()
+	if rawLimit := query.Get("limit"); rawLimit != "" {
+		limit, err := strconv.ParseInt(rawLimit, 10, 64)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeBadRequest, "limit has to be a number from 1 to "+strconv.FormatInt(maxPageSize, 10)).WithDetails(map[string]string{"param": "limit"}))
+			return p, false
+		}
+		p.limit = limit
+	}
+	if rawCursor := query.Get("cursor"); rawCursor != "" {
+		after, err := decodeCursor(rawCursor)
+		if err != nil {
+			helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeBadRequest, "cursor is not valid").WithDetails(map[string]string{"param": "cursor"}))
+			return p, false
+		}
+		p.after = after
+	}
+	switch query.Get("order") {
+	case "", "newest":
+	case "oldest":
+		p.oldest = true
+	default:
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeBadRequest, "order has to be newest or oldest").WithDetails(map[string]string{"param": "order"}))
+		return p, false
+	}
+	return p, true
+}
+
+// adds the cursor to the filter of the list
+func (p page) filter(filter bson.D) bson.D {
+	if p.after.IsZero() {
+		return filter
+	}
+	op := "$lt"
+	if p.oldest {
+		op = "$gt"
+	}
+	return append(filter, primitive.E{Key: "_id", Value: bson.D{primitive.E{Key: op, Value: p.after}}})
+}
+
+func (p page) sort() bson.D {
+	if p.oldest {
+		return bson.D{primitive.E{Key: "_id", Value: 1}}
+	}
+	return bson.D{primitive.E{Key: "_id", Value: -1}}
+}
+
+// one extra entry is asked for to know if there is a next page
+func (p page) options(opts ...model.QueryOption) []model.QueryOption {
+	return append(opts, model.Limit(p.limit+1))
+}
+
+// cuts the extra entry off and returns the cursor for the next page,
+// the cursor is empty when this was the last page
+func pageOf[T any](p page, entries []T, id func(T) primitive.ObjectID) ([]T, string) {
+	if int64(len(entries)) <= p.limit {
+		return entries, ""
+	}
+	entries = entries[:p.limit]
+	return entries, encodeCursor(id(entries[len(entries)-1]))
+}
+
+// cursors are opaque to the client so how they work can change later
+func encodeCursor(id primitive.ObjectID) string {
+	return base64.RawURLEncoding.EncodeToString(id[:])
+}
+
+func decodeCursor(cursor string) (primitive.ObjectID, error) {
+	var id primitive.ObjectID
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return id, err
+	}
+	if len(raw) != len(id) {
+		return id, base64.CorruptInputError(len(raw))
+	}
+	copy(id[:], raw)
+	return id, nil
+}
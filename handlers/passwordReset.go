@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"social-api/helpers"
+	"social-api/logger"
+	"social-api/mailer"
+	"social-api/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// how long the link in the reset email works for
+const resetTokenDuration time.Duration = time.Hour
+
+func buildUserTokenDataBaseType(token *types.UserTokens) bson.D {
+	return bson.D{
+		primitive.E{Key: "_id", Value: token.TokenID},
+		primitive.E{Key: "userId", Value: token.UserID},
+		primitive.E{Key: "email", Value: token.Email},
+		primitive.E{Key: "purpose", Value: token.Purpose},
+		primitive.E{Key: "tokenHash", Value: token.TokenHash},
+		primitive.E{Key: "created_at", Value: token.CreatedAt},
+		primitive.E{Key: "expires_at", Value: token.ExpiresAt},
+		primitive.E{Key: "used_at", Value: token.UsedAt},
+	}
+}
+
+// makes a new single use token for the user and returns the raw token
+// (only the hash of it gets saved), the token only works while the user
+// still has the email it was sent to
+func (ah *AuthHandler) issueUserToken(ctx context.Context, user *types.Users, purpose string, duration time.Duration) (string, error) {
+	rawToken, err := helpers.NewRandomToken()
+	if err != nil {
+		return "", err
+	}
+	token := types.NewUserToken(user.UserID, user.Email, purpose, duration)
+	token.TokenHash = helpers.HashToken(rawToken)
+	if err := ah.tokens.AddEntry(ctx, buildUserTokenDataBaseType(token)); err != nil {
+		return "", err
+	}
+	return rawToken, nil
+}
+
+// finds the token for the given raw value without using it,
+// returns nil if the token doesnt exist, is expired or was already used
+func (ah *AuthHandler) findUserToken(ctx context.Context, rawToken string, purpose string) *types.UserTokens {
+	key := bson.D{
+		primitive.E{Key: "tokenHash", Value: helpers.HashToken(rawToken)},
+		primitive.E{Key: "purpose", Value: purpose},
+	}
+	token, err := ah.tokens.GetEntry(ctx, key)
+	if err != nil || !types.ValidUserToken(token) {
+		return nil
+	}
+	return token
+}
+
+// finds the token for the given raw value and marks it as used,
+// returns nil if the token doesnt exist, is expired or was already used
+func (ah *AuthHandler) useUserToken(ctx context.Context, rawToken string, purpose string) (*types.UserTokens, error) {
+	token := ah.findUserToken(ctx, rawToken, purpose)
+	if token == nil {
+		return nil, nil
+	}
+	now := time.Now()
+	// only counts as used if no one else used it first
+	used, err := ah.tokens.UseToken(ctx, token.TokenID, now)
+	if err != nil || !used {
+		return nil, err
+	}
+	token.UsedAt = &now
+	return token, nil
+}
+
+// sends the user a email with a link to reset their password, always
+// responds the same way so it cant be used to check if a email has a account
+func (ah *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	request, parseError := helpers.ParseBody(r, types.ForgotPasswordRequest{})
+	if parseError != nil {
+		helpers.HandleParserError(parseError, w, r, ah.log)
+		return
+	}
+	if !helpers.ValidRequest(w, r, request) {
+		return
+	}
+	user, dbErr := ah.db.GetEntry(r.Context(), bson.D{primitive.E{Key: "email", Value: request.Email}})
+	if dbErr == nil {
+		rawToken, tokenErr := ah.issueUserToken(r.Context(), user, types.ResetPasswordToken, resetTokenDuration)
+		if tokenErr != nil {
+			helpers.HandleDbError(tokenErr, w, r, ah.log, "error when making the reset token")
+			return
+		}
+		msg := mailer.Message{
+			To:      user.Email,
+			Subject: "reset your password",
+			Body: "someone asked to reset the password for " + user.Username + ".\n" +
+				"use this link to pick a new password (it expires in one hour):\n" +
+				appURL() + "/reset-password?token=" + rawToken + "\n\n" +
+				"if this wasnt you, you can ignore this email.",
+		}
+		if err := ah.mail.Send(msg); err != nil {
+			ah.log.WriteToLogger(logger.ERROR, "error when sending reset email", err)
+		}
+	}
+	helpers.WriteMessage(w, http.StatusOK, "if the email has a account a reset link has been sent")
+}
+
+// sets the new password for the user the reset token belongs to, this also
+// logs the user out everywhere in case the account was taken over
+func (ah *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	request, parseError := helpers.ParseBody(r, types.ResetPasswordRequest{})
+	if parseError != nil {
+		helpers.HandleParserError(parseError, w, r, ah.log)
+		return
+	}
+	if !helpers.ValidRequest(w, r, request) {
+		return
+	}
+	// check the new password before using up the token so the user can try again
+	found := ah.findUserToken(r.Context(), request.Token, types.ResetPasswordToken)
+	if found == nil {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidToken, "invalid or expired reset token"))
+		return
+	}
+	user, dbErr := ah.db.GetEntry(r.Context(), bson.D{primitive.E{Key: "_id", Value: found.UserID}})
+	if dbErr != nil {
+		helpers.HandleDbError(dbErr, w, r, ah.log, "error when getting the user for the reset token")
+		return
+	}
+	// the link was mailed to a email the account no longer has
+	if found.Email != user.Email {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidToken, "invalid or expired reset token"))
+		return
+	}
+	if err := ah.passwords.Check(request.Password, user.Username, user.Email); err != nil {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeWeakPassword, err.Error()))
+		return
+	}
+	hashedPass, hashErr := ah.passwords.Hash(request.Password)
+	if hashErr != nil {
+		ah.log.WriteToLogger(logger.ERROR, "error when hashing the user password", hashErr)
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInternal, "internal server error"))
+		return
+	}
+	// using the token, changing the password and logging out happen together
+	// so a failure cant leave the old sessions working with the new password
+	var token *types.UserTokens
+	txErr := ah.tx.Transaction(r.Context(), func(ctx context.Context) error {
+		var err error
+		if token, err = ah.useUserToken(ctx, request.Token, types.ResetPasswordToken); err != nil || token == nil {
+			return err
+		}
+		key := bson.D{primitive.E{Key: "_id", Value: token.UserID}}
+		val := bson.D{primitive.E{Key: "$set", Value: bson.D{
+			primitive.E{Key: "password", Value: hashedPass},
+			primitive.E{Key: "updated_at", Value: time.Now()},
+		}}}
+		if err := ah.db.ModifyEntry(ctx, key, val); err != nil {
+			return err
+		}
+		return ah.removeUserSessions(ctx, token.UserID)
+	})
+	if txErr != nil {
+		helpers.HandleDbError(txErr, w, r, ah.log, "error when resetting the password")
+		return
+	}
+	if token == nil {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidToken, "invalid or expired reset token"))
+		return
+	}
+	ah.log.WriteToLogger(logger.INFO, "password was reset for user "+token.UserID.Hex())
+	helpers.WriteMessage(w, http.StatusOK, "password has been reset")
+}
@@ -1,8 +1,7 @@
 package handlers
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -17,12 +16,28 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+func buildPostDataBaseType(post *types.Posts) bson.D {
+	return bson.D{
+		primitive.E{Key: "_id", Value: post.PostID},
+		primitive.E{Key: "userId", Value: post.UserID},
+		primitive.E{Key: "img", Value: post.Image},
+		primitive.E{Key: "desc", Value: post.Desc},
+		primitive.E{Key: "likes", Value: post.Likes},
+		primitive.E{Key: "created_at", Value: post.CreatedAt},
+		primitive.E{Key: "updated_at", Value: post.UpdatedAt},
+	}
+}
+
 type PostHandler struct {
-	db  model.Modeler[*types.Posts, bson.D]
-	log logger.Logger
+	db       model.PostStore
+	comments model.CommentStore
+	// a post and its comments are deleted together
+	tx    model.Transactor
+	authz *Authorizer
+	log   logger.Logger
 }
 
-func NewPostHandler(db model.Modeler[*types.Posts, bson.D], logFilePath string) *PostHandler {
+func NewPostHandler(db model.PostStore, comments model.CommentStore, tx model.Transactor, authz *Authorizer, logFilePath string) *PostHandler {
 	l := logger.NewLogger()
 	file, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
 	if err != nil {
@@ -37,17 +52,19 @@ func NewPostHandler(db model.Modeler[*types.Posts, bson.D], logFilePath string)
 	l.AddLogger(logger.ERROR, ErrorLogger)
 	l.AddLogger(logger.FATAL, FatalLogger)
 	return &PostHandler{
-		db:  db,
-		log: l,
+		db:       db,
+		comments: comments,
+		tx:       tx,
+		authz:    authz,
+		log:      l,
 	}
 }
 
 func (ph *PostHandler) Test(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("hello this is the post handler test"))
+	helpers.WriteMessage(w, http.StatusOK, "hello this is the post handler test")
 	//	filter := bson.D{primitive.E{Key: "img", Value: "image1.png"}}
 	//	sort := bson.D{primitive.E{Key: "_id", Value: -1}}
-	//	posts, _ := ph.db.GetEntryAdvanced(filter, sort)
+	//	posts, _ := ph.db.GetEntryAdvanced(r.Context(), filter, sort)
 	ph.log.WriteToLogger(logger.INFO, "test endpoint was hit")
 	//fmt.Println(len(posts))
 	//for _, post := range posts {
@@ -56,51 +73,61 @@ func (ph *PostHandler) Test(w http.ResponseWriter, r *http.Request) {
 }
 
 func (ph *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
-	requestPost, parseError := helpers.ParseBody(r.Body, types.RequestPost{})
-	if parseError != nil {
-		helpers.HandleParserError(parseError, w, ph.log)
+	caller, ok := requestingUser(w, r)
+	if !ok {
 		return
 	}
-	if !types.ValidReqestPost(requestPost) {
-		ph.log.WriteToLogger(logger.WARNING, "incomplete data given to create post handler")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("not enough data given to create new post"))
+	requestPost, parseError := helpers.ParseBody(r, types.RequestPost{})
+	if parseError != nil {
+		helpers.HandleParserError(parseError, w, r, ph.log)
 		return
 	}
-	key := bson.D{
-		primitive.E{Key: "userId", Value: requestPost.UserId},
-		primitive.E{Key: "desc", Value: requestPost.Desc},
-		primitive.E{Key: "img", Value: requestPost.Image},
+	if !helpers.ValidRequest(w, r, requestPost) {
+		ph.log.WriteToLogger(logger.WARNING, "invalid data given to create post handler")
+		return
 	}
-	dberr := ph.db.AddEntry(key)
+	post := types.NewPost()
+	post.UserID = caller.UserID
+	post.Desc = requestPost.Desc
+	post.Image = requestPost.Image
+	post.UpdatedAt = post.CreatedAt
+	dberr := ph.db.AddEntry(r.Context(), buildPostDataBaseType(post))
 	if dberr != nil {
-		helpers.HandleDbError(dberr, w, ph.log, "failed to add post to database")
+		helpers.HandleDbError(dberr, w, r, ph.log, "failed to add post to database")
 		return
 	} else {
 		ph.log.WriteToLogger(logger.INFO, "post created in db")
-		w.WriteHeader(http.StatusCreated)
-		w.Write([]byte("post created"))
+		helpers.WriteJSONMessage(w, http.StatusCreated, types.NewPostResponse(post), "post created")
 	}
 
 }
 
-func (ph *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request, id string) {
-	requestPost, parseError := helpers.ParseBody(r.Body, types.RequestPost{})
+func (ph *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	caller, ok := requestingUser(w, r)
+	if !ok {
+		return
+	}
+	requestPost, parseError := helpers.ParseBody(r, types.EditPostRequest{})
 	if parseError != nil {
-		helpers.HandleParserError(parseError, w, ph.log, "error when parsing post for UpdatePost")
+		helpers.HandleParserError(parseError, w, r, ph.log)
+		return
+	}
+	if !helpers.ValidRequest(w, r, requestPost) {
 		return
 	}
-	// the id needs to be a stirng when quering the database(i think)
 	key := bson.D{primitive.E{Key: "_id", Value: id}}
-	dbPost, dbError := ph.db.GetEntry(key)
+	dbPost, dbError := ph.db.GetEntry(r.Context(), key)
 	if dbError != nil {
-		helpers.HandleDbError(dbError, w, ph.log)
+		helpers.HandleDbError(dbError, w, r, ph.log, fmt.Sprintf("error when getting post with id of %s", id.Hex()))
 		return
 	}
-	if dbPost.UserID != requestPost.UserId {
+	if dbPost.UserID != caller.UserID && !ph.authz.Can(r, caller, types.PermUpdateAnyPost, id.Hex()) {
 		ph.log.WriteToLogger(logger.WARNING, "user attempted to modify someone elses post")
-		w.WriteHeader(http.StatusForbidden)
-		w.Write([]byte("not allowed to update other peoples post"))
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeForbidden, "not allowed to update other peoples post"))
 		return
 	}
 	var newImg string
@@ -117,140 +144,143 @@ func (ph *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request, id str
 	} else {
 		newDesc = requestPost.Desc
 	}
-	val := bson.D{
+	val := bson.D{primitive.E{Key: "$set", Value: bson.D{
 		primitive.E{Key: "img", Value: newImg},
 		primitive.E{Key: "desc", Value: newDesc},
 		primitive.E{Key: "updated_at", Value: time.Now()},
-	}
-	if updateError := ph.db.ModifyEntry(key, val); updateError != nil {
-		helpers.HandleDbError(updateError, w, ph.log, fmt.Sprintf("error when updatin post with id of : %s", id))
+	}}}
+	if updateError := ph.db.ModifyEntry(r.Context(), key, val); updateError != nil {
+		helpers.HandleDbError(updateError, w, r, ph.log, fmt.Sprintf("error when updatin post with id of : %s", id.Hex()))
 		return
 	}
 	ph.log.WriteToLogger(logger.INFO, "post in database was updated")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("post was successfully updated"))
+	helpers.WriteMessage(w, http.StatusOK, "post was successfully updated")
 
 }
 
-func (ph *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request, id string) {
-	requestPost, parseError := helpers.ParseBody(r.Body, types.RequestPost{})
-	if parseError != nil {
-		helpers.HandleParserError(parseError, w, ph.log, "error when parsing in DeletePost")
+func (ph *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	caller, ok := requestingUser(w, r)
+	if !ok {
 		return
 	}
-	// the id needs to be a stirng when quering the database(i think)
 	key := bson.D{primitive.E{Key: "_id", Value: id}}
-	dbPost, dbError := ph.db.GetEntry(key)
+	dbPost, dbError := ph.db.GetEntry(r.Context(), key)
 	if dbError != nil {
-		helpers.HandleDbError(dbError, w, ph.log, fmt.Sprintf("error when getting post with id of %s", id))
+		helpers.HandleDbError(dbError, w, r, ph.log, fmt.Sprintf("error when getting post with id of %s", id.Hex()))
 		return
 	}
-	if dbPost.UserID != requestPost.UserId {
+	if dbPost.UserID != caller.UserID && !ph.authz.Can(r, caller, types.PermDeleteAnyPost, id.Hex()) {
 		ph.log.WriteToLogger(logger.WARNING, "attempt to delete someones else post")
-		w.WriteHeader(http.StatusForbidden)
-		w.Write([]byte("not allowed to update other peoples post"))
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeForbidden, "not allowed to update other peoples post"))
 		return
 	}
-	if removeErr := ph.db.RemoveEntry(key); removeErr != nil {
-		helpers.HandleDbError(removeErr, w, ph.log, "error when removing the post from database")
+	removeErr := ph.tx.Transaction(r.Context(), func(ctx context.Context) error {
+		if err := ph.db.RemoveEntry(ctx, key); err != nil {
+			return err
+		}
+		return ph.comments.RemovePostComments(ctx, id)
+	})
+	if removeErr != nil {
+		helpers.HandleDbError(removeErr, w, r, ph.log, "error when removing the post from database")
 		return
 	} else {
 		ph.log.WriteToLogger(logger.INFO, "post has been deleted form the database")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("post has been deleted"))
+		helpers.WriteMessage(w, http.StatusOK, "post has been deleted")
 		return
 	}
 }
 
-func (ph *PostHandler) GetPost(w http.ResponseWriter, r *http.Request, id string) {
+func (ph *PostHandler) GetPost(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
 	key := bson.D{primitive.E{Key: "_id", Value: id}}
-	dbPost, dbError := ph.db.GetEntry(key)
+	dbPost, dbError := ph.db.GetEntry(r.Context(), key)
 	if dbError != nil {
-		helpers.HandleDbError(dbError, w, ph.log, fmt.Sprintf("error when getting post with id of %s", id))
+		helpers.HandleDbError(dbError, w, r, ph.log, fmt.Sprintf("error when getting post with id of %s", id.Hex()))
+		return
+	}
+	commentCount, countErr := ph.comments.Count(r.Context(), bson.D{primitive.E{Key: "postId", Value: id}})
+	if countErr != nil {
+		helpers.HandleDbError(countErr, w, r, ph.log, fmt.Sprintf("error when counting the comments of post with id of %s", id.Hex()))
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(dbPost)
+	helpers.WriteJSON(w, http.StatusOK, types.PostDetailResponse{
+		PostResponse: types.NewPostResponse(dbPost),
+		CommentCount: commentCount,
+	})
 
 }
 
-func (ph *PostHandler) HandleLikeDislike(w http.ResponseWriter, r *http.Request, postId string) {
-	requestUser, err := helpers.ParseBody(r.Body, types.AuthUserRequest{})
-	if err != nil {
-		helpers.HandleParserError(err, w, ph.log)
+// tells the client if a like or follow changed anything, both actions can
+// be sent again safely so changed is false when it was already done
+type stateChangeResponse struct {
+	Changed bool `json:"changed"`
+}
+
+// adds the caller to the likes of the post
+func (ph *PostHandler) LikePost(w http.ResponseWriter, r *http.Request) {
+	ph.changeLike(w, r, ph.db.Like, "post has been liked", "post was already liked")
+}
+
+// takes the caller out of the likes of the post
+func (ph *PostHandler) UnlikePost(w http.ResponseWriter, r *http.Request) {
+	ph.changeLike(w, r, ph.db.Unlike, "post has been unliked", "post was not liked")
+}
+
+func (ph *PostHandler) changeLike(w http.ResponseWriter, r *http.Request, op func(context.Context, primitive.ObjectID, primitive.ObjectID) (bool, error), changedMsg string, unchangedMsg string) {
+	postId, ok := pathID(w, r, "id")
+	if !ok {
 		return
 	}
-	if requestUser.UserId == "" {
-		ph.log.WriteToLogger(logger.WARNING, "incomplete data sent to like/dislike handler")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("need to provide userId of user liking the post"))
+	caller, ok := requestingUser(w, r)
+	if !ok {
 		return
 	}
-	postKey := bson.D{primitive.E{Key: "_id", Value: postId}}
-	dbPost, dbError := ph.db.GetEntry(postKey)
-	if dbError != nil {
-		helpers.HandleDbError(dbError, w, ph.log, fmt.Sprintf("error when getting post with id of %s", postId))
+	changed, err := op(r.Context(), postId, caller.UserID)
+	if err != nil {
+		helpers.HandleDbError(err, w, r, ph.log, fmt.Sprintf("error when changing the likes of the post with id of: %s", postId.Hex()))
 		return
 	}
-	// attempt to remove the userId from the Likes array(dislike post),
-	// if error is return then append the userId to the likes array(like post)
-	newLikesArray, likeError := helpers.RemoveElement(dbPost.Likes, requestUser.UserId)
-	if likeError != nil && errors.Is(likeError, errors.New("element not in array")) {
-		dbPost.Likes = append(dbPost.Likes, requestUser.UserId)
-		val := bson.D{
-			primitive.E{Key: "likes", Value: dbPost.Likes},
-			primitive.E{Key: "updated_at", Value: time.Now()},
-		}
-		if err := ph.db.ModifyEntry(postKey, val); err != nil {
-			helpers.HandleDbError(err, w, ph.log, fmt.Sprintf("error when liking the post with id of: %s", postId))
-			return
-		} else {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("post has been liked"))
-			return
-		}
-	} else {
-		val := bson.D{
-			primitive.E{Key: "likes", Value: newLikesArray},
-			primitive.E{Key: "updated_at", Value: time.Now()},
-		}
-		if err := ph.db.ModifyEntry(postKey, val); err != nil {
-			helpers.HandleDbError(err, w, ph.log, fmt.Sprintf("error when liking the post with id of: %s", postId))
-			return
-		} else {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("post has been unliked"))
-			return
-		}
+	msg := unchangedMsg
+	if changed {
+		msg = changedMsg
 	}
+	helpers.WriteJSONMessage(w, http.StatusOK, stateChangeResponse{Changed: changed}, msg)
 }
 
-// will return the timeline for the user whos id was provided into the request
-func (ph *PostHandler) GetTimeLine(w http.ResponseWriter, r *http.Request, requestUser *types.Users) {
-	var friendPosts []*types.Posts
-	if len(requestUser.Follwings) == 0 {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("you are not following anyone yet"))
+// will return the timeline for the user who is logged in, the posts of the
+// user and everyone they follow newest first
+func (ph *PostHandler) GetTimeLine(w http.ResponseWriter, r *http.Request) {
+	requestUser, ok := requestingUser(w, r)
+	if !ok {
 		return
 	}
-	// can ignore this error because its ok if the current user doesnt have any posts
-	mypost, _ := ph.db.GetEntry(bson.D{primitive.E{Key: "userId", Value: requestUser.UserID.Hex()}})
-	for _, user := range requestUser.Follwings {
-		post, err := ph.db.GetEntry(bson.D{primitive.E{Key: "userId", Value: user}})
-		if err != nil {
-			helpers.HandleDbError(err, w, ph.log, "error when getting friends posts")
-			return
-		}
-		friendPosts = append(friendPosts, post)
+	p, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+	authors := append([]primitive.ObjectID{requestUser.UserID}, requestUser.Followings...)
+	filter := p.filter(bson.D{primitive.E{Key: "userId", Value: bson.D{primitive.E{Key: "$in", Value: authors}}}})
+	posts, err := ph.db.GetEntryAdvanced(r.Context(), filter, p.sort(), p.options()...)
+	if err != nil {
+		helpers.HandleDbError(err, w, r, ph.log, "error when getting the timeline posts")
+		return
+	}
+	posts, next := pageOf(p, posts, func(post *types.Posts) primitive.ObjectID { return post.PostID })
+	timeline := make([]types.PostResponse, 0, len(posts))
+	for _, post := range posts {
+		timeline = append(timeline, types.NewPostResponse(post))
 	}
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(append(friendPosts, mypost))
+	helpers.WritePage(w, http.StatusOK, timeline, next)
 }
 
 func (ph *PostHandler) HandleNotFound(w http.ResponseWriter, r *http.Request, msg string) {
 	ph.log.WriteToLogger(logger.WARNING, "invalid url was given to post handlers"+r.URL.Path)
-	w.WriteHeader(http.StatusNotFound)
-	w.Write([]byte(msg))
+	helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeNotFound, msg))
 }
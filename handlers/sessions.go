@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"social-api/helpers"
+	"social-api/logger"
+	"social-api/model"
+	"social-api/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func buildSessionDataBaseType(session *types.Sessions) bson.D {
+	return bson.D{
+		primitive.E{Key: "_id", Value: session.SessionID},
+		primitive.E{Key: "userId", Value: session.UserID},
+		primitive.E{Key: "tokenHash", Value: session.TokenHash},
+		primitive.E{Key: "userAgent", Value: session.UserAgent},
+		primitive.E{Key: "ip", Value: session.IP},
+		primitive.E{Key: "created_at", Value: session.CreatedAt},
+		primitive.E{Key: "last_seen", Value: session.LastSeen},
+		primitive.E{Key: "expires_at", Value: session.ExpiresAt},
+	}
+}
+
+// what gets sent to the client when listing the sessions (no token hash)
+type sessionResponse struct {
+	SessionID string    `json:"sessionId"`
+	UserAgent string    `json:"userAgent"`
+	IP        string    `json:"ip"`
+	Current   bool      `json:"current"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// writes a new access token and refresh token pair for the given session
+func writeTokens(w http.ResponseWriter, status int, session *types.Sessions, refreshToken string, user *types.Users) error {
+	accessToken, err := helpers.NewAccessToken(session.UserID.Hex(), session.SessionID.Hex())
+	if err != nil {
+		return err
+	}
+	var userResponse *types.PrivateUserResponse
+	if user != nil {
+		response := types.NewPrivateUserResponse(user)
+		userResponse = &response
+	}
+	helpers.WriteJSON(w, status, struct {
+		Token        string                     `json:"token"`
+		ExpiresIn    int                        `json:"expiresIn"`
+		RefreshToken string                     `json:"refreshToken"`
+		User         *types.PrivateUserResponse `json:"user,omitempty"`
+	}{
+		Token:        accessToken,
+		ExpiresIn:    int(helpers.AccessTokenDuration.Seconds()),
+		RefreshToken: refreshToken,
+		User:         userResponse,
+	})
+	return nil
+}
+
+// makes a new session for the user after they logged in and sends the tokens
+func (ah *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, user *types.Users) {
+	refreshToken, tokenErr := helpers.NewRandomToken()
+	if tokenErr != nil {
+		ah.log.WriteToLogger(logger.ERROR, "error when making refresh token", tokenErr)
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInternal, "internal server error"))
+		return
+	}
+	session := types.NewSession(user.UserID)
+	session.TokenHash = helpers.HashToken(refreshToken)
+	session.UserAgent = r.UserAgent()
+	session.IP = clientIP(r)
+	if err := ah.sessions.AddEntry(r.Context(), buildSessionDataBaseType(session)); err != nil {
+		helpers.HandleDbError(err, w, r, ah.log, "error when creating the session")
+		return
+	}
+	if err := writeTokens(w, http.StatusOK, session, refreshToken, user); err != nil {
+		ah.log.WriteToLogger(logger.ERROR, "error when making the access token", err)
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInternal, "internal server error"))
+	}
+}
+
+// trades a refresh token for a new access token, the refresh token is
+// rotated every time so a stolen one can only be used once
+func (ah *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	request, parseError := helpers.ParseBody(r, types.RefreshRequest{})
+	if parseError != nil {
+		helpers.HandleParserError(parseError, w, r, ah.log)
+		return
+	}
+	if !helpers.ValidRequest(w, r, request) {
+		return
+	}
+	newRefreshToken, tokenErr := helpers.NewRandomToken()
+	if tokenErr != nil {
+		ah.log.WriteToLogger(logger.ERROR, "error when making refresh token", tokenErr)
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInternal, "internal server error"))
+		return
+	}
+	now := time.Now()
+	set := bson.D{
+		primitive.E{Key: "tokenHash", Value: helpers.HashToken(newRefreshToken)},
+		primitive.E{Key: "last_seen", Value: now},
+		primitive.E{Key: "userAgent", Value: r.UserAgent()},
+		primitive.E{Key: "ip", Value: clientIP(r)},
+	}
+	// finding and swapping the token is one step so two requests with the
+	// same refresh token cant both get new tokens
+	session, dbErr := ah.sessions.RotateToken(r.Context(), helpers.HashToken(request.RefreshToken), now, set)
+	if errors.Is(dbErr, mongo.ErrNoDocuments) {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidToken, "invalid or expired refresh token"))
+		return
+	}
+	if dbErr != nil {
+		helpers.HandleDbError(dbErr, w, r, ah.log, "error when refreshing the session")
+		return
+	}
+	if err := writeTokens(w, http.StatusOK, session, newRefreshToken, nil); err != nil {
+		ah.log.WriteToLogger(logger.ERROR, "error when making the access token", err)
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInternal, "internal server error"))
+	}
+}
+
+// ends the session the access token belongs to
+func (ah *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	sessionId, ok := sessionFromContext(r)
+	if !ok {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeUnauthorized, "need to be logged in to use this endpoint"))
+		return
+	}
+	if err := ah.sessions.RemoveEntry(r.Context(), bson.D{primitive.E{Key: "_id", Value: sessionId}}); err != nil {
+		helpers.HandleDbError(err, w, r, ah.log, "error when removing the session")
+		return
+	}
+	helpers.WriteMessage(w, http.StatusOK, "logged out")
+}
+
+// ends every session of the logged in user (including the current one)
+func (ah *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestingUser(w, r)
+	if !ok {
+		return
+	}
+	if err := ah.removeUserSessions(r.Context(), user.UserID); err != nil {
+		helpers.HandleDbError(err, w, r, ah.log, "error when removing the users sessions")
+		return
+	}
+	ah.log.WriteToLogger(logger.INFO, "all sessions removed for user "+user.UserID.Hex())
+	helpers.WriteMessage(w, http.StatusOK, "logged out of all sessions")
+}
+
+// removes all of the sessions that belong to the given user
+func (ah *AuthHandler) removeUserSessions(ctx context.Context, userId primitive.ObjectID) error {
+	filter := bson.D{primitive.E{Key: "userId", Value: userId}}
+	sort := bson.D{primitive.E{Key: "_id", Value: 1}}
+	// only the ids are needed to remove them
+	sessions, err := ah.sessions.GetEntryAdvanced(ctx, filter, sort, model.Project(bson.D{primitive.E{Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if err := ah.sessions.RemoveEntry(ctx, bson.D{primitive.E{Key: "_id", Value: session.SessionID}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lists all of the active sessions of the logged in user
+func (ah *AuthHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestingUser(w, r)
+	if !ok {
+		return
+	}
+	p, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+	currentId, _ := sessionFromContext(r)
+	// expired sessions are left out in the query so every page is full
+	filter := p.filter(bson.D{
+		primitive.E{Key: "userId", Value: user.UserID},
+		primitive.E{Key: "expires_at", Value: bson.D{primitive.E{Key: "$gt", Value: time.Now()}}},
+	})
+	sessions, dbErr := ah.sessions.GetEntryAdvanced(r.Context(), filter, p.sort(), p.options()...)
+	if dbErr != nil {
+		helpers.HandleDbError(dbErr, w, r, ah.log, "error when getting the users sessions")
+		return
+	}
+	sessions, next := pageOf(p, sessions, func(s *types.Sessions) primitive.ObjectID { return s.SessionID })
+	response := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, sessionResponse{
+			SessionID: session.SessionID.Hex(),
+			UserAgent: session.UserAgent,
+			IP:        session.IP,
+			Current:   session.SessionID == currentId,
+			CreatedAt: session.CreatedAt,
+			LastSeen:  session.LastSeen,
+			ExpiresAt: session.ExpiresAt,
+		})
+	}
+	helpers.WritePage(w, http.StatusOK, response, next)
+}
+
+// revokes a single session of the logged in user (used to kill a stolen session)
+func (ah *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	sessionId, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	user, ok := requestingUser(w, r)
+	if !ok {
+		return
+	}
+	// filter on the user as well so people cant revoke other users sessions
+	key := bson.D{
+		primitive.E{Key: "_id", Value: sessionId},
+		primitive.E{Key: "userId", Value: user.UserID},
+	}
+	if _, dbErr := ah.sessions.GetEntry(r.Context(), key); dbErr != nil {
+		helpers.HandleDbError(dbErr, w, r, ah.log, "error when getting the session")
+		return
+	}
+	if err := ah.sessions.RemoveEntry(r.Context(), key); err != nil {
+		helpers.HandleDbError(err, w, r, ah.log, "error when removing the session")
+		return
+	}
+	helpers.WriteMessage(w, http.StatusOK, "session has been revoked")
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"social-api/helpers"
+	"social-api/logger"
+	"social-api/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// how many backup codes the user gets when turning on 2fa
+const recoveryCodeCount int = 10
+
+// name shown in the authenticator app
+func totpIssuer() string {
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		return "social-api"
+	}
+	return issuer
+}
+
+// makes the recovery codes, returns the plain codes for the user
+// and the hashes to store in the database (hashed like the passwords)
+func (ah *AuthHandler) newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(b)
+		code = code[:5] + "-" + code[5:]
+		hash, err := ah.passwords.Hash(code)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hash)
+	}
+	return codes, hashes, nil
+}
+
+// checks the totp code or the recovery code from the request, a used
+// recovery code is removed and the step of a used totp code is saved so
+// neither can be used again
+func (ah *AuthHandler) checkSecondFactor(ctx context.Context, user *types.Users, request *types.TwoFactorRequest) (bool, error) {
+	if request.Code != "" {
+		step, valid := helpers.TOTPStep(user.TOTPSecret, request.Code, time.Now())
+		if !valid {
+			return false, nil
+		}
+		return ah.db.UseTOTPStep(ctx, user.UserID, step)
+	}
+	if request.RecoveryCode == "" {
+		return false, nil
+	}
+	for _, hash := range user.RecoveryCodes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(request.RecoveryCode)) == nil {
+			// false if another request used the code first
+			used, err := ah.db.UseRecoveryCode(ctx, user.UserID, hash)
+			if err != nil || !used {
+				return false, err
+			}
+			ah.log.WriteToLogger(logger.INFO, "recovery code used for user "+user.UserID.Hex())
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// starts 2fa enrollment by making a new secret, 2fa is not on until the
+// user confirms it with a code from their app
+func (ah *AuthHandler) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestingUser(w, r)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeConflict, "2fa is already enabled"))
+		return
+	}
+	secret, secretErr := helpers.NewTOTPSecret()
+	if secretErr != nil {
+		ah.log.WriteToLogger(logger.ERROR, "error when making totp secret", secretErr)
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInternal, "internal server error"))
+		return
+	}
+	key := bson.D{primitive.E{Key: "_id", Value: user.UserID}}
+	val := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "totpSecret", Value: secret},
+		primitive.E{Key: "updated_at", Value: time.Now()},
+	}}}
+	if err := ah.db.ModifyEntry(r.Context(), key, val); err != nil {
+		helpers.HandleDbError(err, w, r, ah.log, "error when saving the totp secret")
+		return
+	}
+	helpers.WriteJSON(w, http.StatusOK, struct {
+		Secret string `json:"secret"`
+		URI    string `json:"uri"`
+	}{
+		Secret: secret,
+		URI:    helpers.TOTPURI(totpIssuer(), user.Email, secret),
+	})
+}
+
+// turns 2fa on after the user shows their app makes the right codes,
+// sends back the recovery codes (this is the only time they can be seen)
+func (ah *AuthHandler) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestingUser(w, r)
+	if !ok {
+		return
+	}
+	request, parseError := helpers.ParseBody(r, types.TwoFactorRequest{})
+	if parseError != nil {
+		helpers.HandleParserError(parseError, w, r, ah.log)
+		return
+	}
+	if user.TOTPEnabled {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeConflict, "2fa is already enabled"))
+		return
+	}
+	if user.TOTPSecret == "" {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeBadRequest, "need to start 2fa enrollment first"))
+		return
+	}
+	step, valid := helpers.TOTPStep(user.TOTPSecret, request.Code, time.Now())
+	if !valid {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidCode, "incorrect 2fa code given"))
+		return
+	}
+	codes, hashes, codeErr := ah.newRecoveryCodes()
+	if codeErr != nil {
+		ah.log.WriteToLogger(logger.ERROR, "error when making recovery codes", codeErr)
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInternal, "internal server error"))
+		return
+	}
+	key := bson.D{primitive.E{Key: "_id", Value: user.UserID}}
+	val := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "totpEnabled", Value: true},
+		primitive.E{Key: "recoveryCodes", Value: hashes},
+		// the code used to turn 2fa on cant be used to log in
+		primitive.E{Key: "totpLastStep", Value: step},
+		primitive.E{Key: "updated_at", Value: time.Now()},
+	}}}
+	if err := ah.db.ModifyEntry(r.Context(), key, val); err != nil {
+		helpers.HandleDbError(err, w, r, ah.log, "error when enabling 2fa")
+		return
+	}
+	ah.log.WriteToLogger(logger.INFO, "2fa enabled for user "+user.UserID.Hex())
+	helpers.WriteJSON(w, http.StatusOK, struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}{
+		RecoveryCodes: codes,
+	})
+}
+
+// turns 2fa off, needs the password and a code (or recovery code)
+func (ah *AuthHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestingUser(w, r)
+	if !ok {
+		return
+	}
+	request, parseError := helpers.ParseBody(r, types.TwoFactorRequest{})
+	if parseError != nil {
+		helpers.HandleParserError(parseError, w, r, ah.log)
+		return
+	}
+	if !user.TOTPEnabled {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeBadRequest, "2fa is not enabled"))
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password)) != nil {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidCredentials, "incorrect password given"))
+		return
+	}
+	valid, checkErr := ah.checkSecondFactor(r.Context(), user, request)
+	if checkErr != nil {
+		helpers.HandleDbError(checkErr, w, r, ah.log, "error when checking the 2fa code")
+		return
+	}
+	if !valid {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidCode, "incorrect 2fa code given"))
+		return
+	}
+	key := bson.D{primitive.E{Key: "_id", Value: user.UserID}}
+	val := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "totpEnabled", Value: false},
+		primitive.E{Key: "totpSecret", Value: ""},
+		primitive.E{Key: "recoveryCodes", Value: []string{}},
+		primitive.E{Key: "updated_at", Value: time.Now()},
+	}}}
+	if err := ah.db.ModifyEntry(r.Context(), key, val); err != nil {
+		helpers.HandleDbError(err, w, r, ah.log, "error when disabling 2fa")
+		return
+	}
+	ah.log.WriteToLogger(logger.INFO, "2fa disabled for user "+user.UserID.Hex())
+	helpers.WriteMessage(w, http.StatusOK, "2fa has been disabled")
+}
+
+// sent instead of the tokens when the password was right but 2fa is on
+func (ah *AuthHandler) sendTwoFactorChallenge(w http.ResponseWriter, r *http.Request, user *types.Users) {
+	challenge, err := helpers.NewChallengeToken(user.UserID.Hex())
+	if err != nil {
+		ah.log.WriteToLogger(logger.ERROR, "error when making the challenge token", err)
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInternal, "internal server error"))
+		return
+	}
+	helpers.WriteJSON(w, http.StatusOK, struct {
+		TwoFactorRequired bool   `json:"twoFactorRequired"`
+		ChallengeToken    string `json:"challengeToken"`
+		ExpiresIn         int    `json:"expiresIn"`
+	}{
+		TwoFactorRequired: true,
+		ChallengeToken:    challenge,
+		ExpiresIn:         int(helpers.ChallengeTokenDuration.Seconds()),
+	})
+}
+
+// second step of the login for users with 2fa, trades the challenge
+// token and a code for the normal session tokens
+func (ah *AuthHandler) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	request, parseError := helpers.ParseBody(r, types.TwoFactorRequest{})
+	if parseError != nil {
+		helpers.HandleParserError(parseError, w, r, ah.log)
+		return
+	}
+	userId, tokenErr := helpers.ParseChallengeToken(request.ChallengeToken)
+	if tokenErr != nil {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidToken, "invalid or expired challenge token"))
+		return
+	}
+	id, hexErr := primitive.ObjectIDFromHex(userId)
+	if hexErr != nil {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidToken, "invalid or expired challenge token"))
+		return
+	}
+	user, dbErr := ah.db.GetEntry(r.Context(), bson.D{primitive.E{Key: "_id", Value: id}})
+	if dbErr != nil {
+		helpers.HandleDbError(dbErr, w, r, ah.log, "error when getting the user for 2fa")
+		return
+	}
+	if !user.TOTPEnabled {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeBadRequest, "2fa is not enabled"))
+		return
+	}
+	// 6 digit codes are easy to guess so they get throttled like passwords
+	throttleKey := "2fa:" + user.UserID.Hex()
+	if !ah.reserveThrottle(w, r, ah.ipThrottle, clientIP(r)) || !ah.reserveThrottle(w, r, ah.accountThrottle, throttleKey) {
+		return
+	}
+	valid, checkErr := ah.checkSecondFactor(r.Context(), user, request)
+	if checkErr != nil {
+		helpers.HandleDbError(checkErr, w, r, ah.log, "error when checking the 2fa code")
+		return
+	}
+	if !valid {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidCode, "incorrect 2fa code given"))
+		return
+	}
+	ah.succeedThrottle(r, throttleKey)
+	ah.startSession(w, r, user)
+}
@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,7 +10,6 @@ import (
 	"social-api/logger"
 	"social-api/model"
 	"social-api/types"
-	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -18,8 +17,9 @@ import (
 )
 
 // will make the new user with the given data from the client
-// and the existing user from the database
-func updateUserData(dbUser *types.Users, rUser *types.RequestUser) *types.Users {
+// and the existing user from the database (newHash is empty if the
+// password is not being changed)
+func updateUserData(dbUser *types.Users, rUser *types.RequestUser, newHash string) *types.Users {
 	// do this so the client doesnt need to resend data in the database
 	finalUser := types.NewUser()
 	if rUser.City != "" {
@@ -47,9 +47,8 @@ func updateUserData(dbUser *types.Users, rUser *types.RequestUser) *types.Users
 	} else {
 		finalUser.From = dbUser.From
 	}
-	if rUser.Password != "" {
-		hashedPass, _ := bcrypt.GenerateFromPassword([]byte(rUser.Password), bcrypt.DefaultCost)
-		finalUser.Password = string(hashedPass)
+	if newHash != "" {
+		finalUser.Password = newHash
 	} else {
 		finalUser.Password = dbUser.Password
 	}
@@ -77,11 +76,14 @@ func updateUserData(dbUser *types.Users, rUser *types.RequestUser) *types.Users
 }
 
 type UserHandler struct {
-	db  model.Modeler[*types.Users, bson.D]
-	log logger.Logger
+	db        model.UserStore
+	authz     *Authorizer
+	passwords *helpers.PasswordPolicy
+	auth      *AuthHandler // sends the verification email when the email changes
+	log       logger.Logger
 }
 
-func NewUserHandler(db model.Modeler[*types.Users, bson.D], logFilePath string) *UserHandler {
+func NewUserHandler(db model.UserStore, authz *Authorizer, passwords *helpers.PasswordPolicy, auth *AuthHandler, logFilePath string) *UserHandler {
 	l := logger.NewLogger()
 	file, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
 	if err != nil {
@@ -96,194 +98,205 @@ func NewUserHandler(db model.Modeler[*types.Users, bson.D], logFilePath string)
 	l.AddLogger(logger.ERROR, ErrorLogger)
 	l.AddLogger(logger.FATAL, FatalLogger)
 	return &UserHandler{
-		db:  db,
-		log: l,
+		db:        db,
+		authz:     authz,
+		passwords: passwords,
+		auth:      auth,
+		log:       l,
 	}
 }
 
-func (uh *UserHandler) GetUser(w http.ResponseWriter, r *http.Request, id string) {
+func (uh *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
 	key := bson.D{primitive.E{Key: "_id", Value: id}}
-	user, dbError := uh.db.GetEntry(key)
+	user, dbError := uh.db.GetEntry(r.Context(), key)
 	if dbError != nil {
-		helpers.HandleDbError(dbError, w, uh.log, fmt.Sprintf("error when getting user with id %s", id))
+		helpers.HandleDbError(dbError, w, r, uh.log, fmt.Sprintf("error when getting user with id %s", id.Hex()))
 		return
 	}
-	// censer the password before sending data to client
-	user.Password = "********"
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(user)
+	helpers.WriteJSON(w, http.StatusOK, types.NewUserResponse(user))
+}
+
+// checks the username and password sent match the account, writes the error if not
+func checkOwnCredentials(w http.ResponseWriter, r *http.Request, dbuser *types.Users, rUser *types.RequestUser) bool {
+	if rUser.Username != dbuser.Username {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeBadRequest, "username does not match the account"))
+		return false
+	}
+	correctUser := bcrypt.CompareHashAndPassword([]byte(dbuser.Password), []byte(rUser.Password))
+	if correctUser != nil {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInvalidCredentials, "incorrect password given"))
+		return false
+	}
+	return true
 }
 
-func (uh *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request, id string) {
+func (uh *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	caller, ok := requestingUser(w, r)
+	if !ok {
+		return
+	}
 	//	fmt.Println("have not make the update user handler yet", id)
 	//	w.WriteHeader(http.StatusNotImplemented)
 	//	w.Write([]byte("have not make the update user handler yet"))
 	key := bson.D{primitive.E{Key: "_id", Value: id}}
-	dbuser, dbError := uh.db.GetEntry(key)
+	dbuser, dbError := uh.db.GetEntry(r.Context(), key)
 	if dbError != nil {
-		helpers.HandleDbError(dbError, w, uh.log, fmt.Sprintf("error when getting user with id %s", id))
+		helpers.HandleDbError(dbError, w, r, uh.log, fmt.Sprintf("error when getting user with id %s", id.Hex()))
 		return
 	}
-	rUser, parseError := helpers.ParseBody(r.Body, types.RequestUser{})
+	rUser, parseError := helpers.ParseBody(r, types.RequestUser{})
 	if parseError != nil {
-		helpers.HandleParserError(parseError, w, uh.log)
+		helpers.HandleParserError(parseError, w, r, uh.log)
 		return
 	}
-	if rUser.Username == "" || rUser.Username != dbuser.Username {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("invalid username given, username is required to update account"))
+	if !helpers.ValidRequest(w, r, rUser) {
 		return
 	}
-	correctUser := bcrypt.CompareHashAndPassword([]byte(dbuser.Password), []byte(rUser.Password))
-	if correctUser != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("incorrect password given"))
+	// users confirm changes to their own account with their password, admins
+	// dont need to know the password of the account they are changing
+	if caller.UserID == dbuser.UserID {
+		if !checkOwnCredentials(w, r, dbuser, rUser) {
+			return
+		}
+	} else if !uh.authz.Can(r, caller, types.PermUpdateAnyUser, id.Hex()) {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeForbidden, "you are not authorized to modify this users account"))
 		return
 	}
-	if rUser.UserID == dbuser.UserID.Hex() || rUser.IsAdmin {
-		newUser := updateUserData(dbuser, rUser)
-		val := bson.D{
-			primitive.E{Key: "username", Value: newUser.Username},
-			primitive.E{Key: "email", Value: newUser.Email},
-			primitive.E{Key: "password", Value: newUser.Password},
-			primitive.E{Key: "profilePicture", Value: newUser.ProfilePic},
-			primitive.E{Key: "coverPicture", Value: newUser.CoverPic},
-			primitive.E{Key: "desc", Value: newUser.Desc},
-			primitive.E{Key: "city", Value: newUser.City},
-			primitive.E{Key: "from", Value: newUser.From},
-			primitive.E{Key: "relationship", Value: newUser.Relationship},
-			primitive.E{Key: "created_at", Value: newUser.CreatedAt},
-			primitive.E{Key: "updated_at", Value: newUser.UpdatedAt},
+	var newHash string
+	if rUser.NewPassword != "" {
+		username := rUser.Username
+		if username == "" {
+			username = dbuser.Username
 		}
-		if err := uh.db.ModifyEntry(key, val); err != nil {
-			helpers.HandleDbError(err, w, uh.log, "error when updating the user")
+		if err := uh.passwords.Check(rUser.NewPassword, username, dbuser.Email); err != nil {
+			helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeWeakPassword, err.Error()))
 			return
 		}
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("user has been updated"))
-		return
-	} else {
-		w.WriteHeader(http.StatusForbidden)
-		w.Write([]byte("you are not authorized to modify this users account"))
+		hashedPass, hashErr := uh.passwords.Hash(rUser.NewPassword)
+		if hashErr != nil {
+			uh.log.WriteToLogger(logger.ERROR, "error when hashing the new password", hashErr)
+			helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeInternal, "internal server error"))
+			return
+		}
+		newHash = hashedPass
+	}
+	newUser := updateUserData(dbuser, rUser, newHash)
+	// a new email has to be verified again before it counts
+	emailChanged := newUser.Email != dbuser.Email
+	newUser.EmailVerified = dbuser.EmailVerified && !emailChanged
+	val := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "username", Value: newUser.Username},
+		primitive.E{Key: "emailVerified", Value: newUser.EmailVerified},
+		primitive.E{Key: "email", Value: newUser.Email},
+		primitive.E{Key: "password", Value: newUser.Password},
+		primitive.E{Key: "profilePicture", Value: newUser.ProfilePic},
+		primitive.E{Key: "coverPicture", Value: newUser.CoverPic},
+		primitive.E{Key: "desc", Value: newUser.Desc},
+		primitive.E{Key: "city", Value: newUser.City},
+		primitive.E{Key: "from", Value: newUser.From},
+		primitive.E{Key: "relationship", Value: newUser.Relationship},
+		primitive.E{Key: "created_at", Value: newUser.CreatedAt},
+		primitive.E{Key: "updated_at", Value: newUser.UpdatedAt},
+	}}}
+	if err := uh.db.ModifyEntry(r.Context(), key, val); err != nil {
+		helpers.HandleDbError(err, w, r, uh.log, "error when updating the user")
 		return
 	}
-
+	// the update still counts if the email fails, the user can ask for another one
+	if emailChanged {
+		if err := uh.auth.sendVerification(r.Context(), newUser); err != nil {
+			uh.log.WriteToLogger(logger.ERROR, "error when sending verification email", err)
+		}
+	}
+	helpers.WriteMessage(w, http.StatusOK, "user has been updated")
 }
 
-func (uh *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request, id string) {
+func (uh *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	caller, ok := requestingUser(w, r)
+	if !ok {
+		return
+	}
 	//	fmt.Println("have not make the delete user handler yet", id)
 	//	w.WriteHeader(http.StatusNotImplemented)
 	//	w.Write([]byte("have not make the update user handler yet"))
 	key := bson.D{primitive.E{Key: "_id", Value: id}}
-	dbuser, dbError := uh.db.GetEntry(key)
+	dbuser, dbError := uh.db.GetEntry(r.Context(), key)
 	if dbError != nil {
-		helpers.HandleDbError(dbError, w, uh.log, fmt.Sprintf("error when getting user with id %s", id))
+		helpers.HandleDbError(dbError, w, r, uh.log, fmt.Sprintf("error when getting user with id %s", id.Hex()))
 		return
 	}
-	rUser, parseError := helpers.ParseBody(r.Body, types.RequestUser{})
-	if parseError != nil {
-		helpers.HandleParserError(parseError, w, uh.log)
-		return
-	}
-	if rUser.Username == "" || rUser.Username != dbuser.Username {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("invalid username given, username is required to update account"))
-		return
-	}
-	correctUser := bcrypt.CompareHashAndPassword([]byte(dbuser.Password), []byte(rUser.Password))
-	if correctUser != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("incorrect password given"))
-		return
-	}
-	if rUser.UserID == dbuser.UserID.Hex() || rUser.IsAdmin {
-		if err := uh.db.RemoveEntry(key); err != nil {
-			helpers.HandleDbError(err, w, uh.log, "error when deleteing user: "+rUser.UserID)
+	// only users deleting their own account have to send their password
+	if caller.UserID == dbuser.UserID {
+		rUser, parseError := helpers.ParseBody(r, types.RequestUser{})
+		if parseError != nil {
+			helpers.HandleParserError(parseError, w, r, uh.log)
+			return
+		}
+		if !helpers.ValidRequest(w, r, rUser) {
 			return
 		}
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("user has been deleted"))
+		if !checkOwnCredentials(w, r, dbuser, rUser) {
+			return
+		}
+	} else if !uh.authz.Can(r, caller, types.PermDeleteAnyUser, id.Hex()) {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeForbidden, "you are not authorized to delete this users account"))
 		return
-	} else {
-		w.WriteHeader(http.StatusForbidden)
-		w.Write([]byte("you are not authorized to delete this users account"))
+	}
+	if err := uh.db.RemoveAccount(r.Context(), dbuser.UserID); err != nil {
+		helpers.HandleDbError(err, w, r, uh.log, "error when deleteing user: "+dbuser.UserID.Hex())
 		return
 	}
+	helpers.WriteMessage(w, http.StatusOK, "user has been deleted")
 }
 
-func (uh *UserHandler) FollowUnfollow(w http.ResponseWriter, r *http.Request, followId string) {
-	requestUser, err := helpers.ParseBody(r.Body, types.AuthUserRequest{})
-	if err != nil {
-		helpers.HandleParserError(err, w, uh.log)
-		return
-	}
-	if requestUser.UserId == followId {
-		w.WriteHeader(http.StatusForbidden)
-		w.Write([]byte("cannot follow yourself"))
-		return
-	}
-	currUserKey := bson.D{primitive.E{Key: "_id", Value: requestUser.UserId}}
-	currentUser, cErr := uh.db.GetEntry(currUserKey)
-	if cErr != nil {
-		helpers.HandleDbError(cErr, w, uh.log)
-	}
-	followUserKey := bson.D{primitive.E{Key: "_id", Value: followId}}
-	user, uErr := uh.db.GetEntry(followUserKey)
-	if uErr != nil {
-		helpers.HandleDbError(uErr, w, uh.log)
-	}
-	var updatedFollowerArray []string
-	var updatedFollowingArray []string
-	var option bool
-	currentTime := time.Now()
+// the caller starts following the user with the id
+func (uh *UserHandler) Follow(w http.ResponseWriter, r *http.Request) {
+	uh.changeFollow(w, r, uh.db.Follow, "user has been followed", "user was already followed")
+}
 
-	if !helpers.Includes(user.Follwers, currentUser.UserID.Hex()) {
-		updatedFollowerArray = append(user.Follwers, currentUser.UserID.Hex())
-		updatedFollowingArray = append(currentUser.Follwings, user.UserID.Hex())
-		option = true
-	} else {
-		newUserArray, userErr := helpers.RemoveElement(user.Follwers, currentUser.UserID.Hex())
-		newCurrentUserArray, currentUserErr := helpers.RemoveElement(currentUser.Follwings, user.UserID.Hex())
-		if userErr != nil || currentUserErr != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("unknow error when unfollowing user"))
-			return
-		}
-		updatedFollowerArray = newUserArray
-		updatedFollowingArray = newCurrentUserArray
-		option = false
-	}
+// the caller stops following the user with the id
+func (uh *UserHandler) Unfollow(w http.ResponseWriter, r *http.Request) {
+	uh.changeFollow(w, r, uh.db.Unfollow, "user has been unfollowed", "user was not followed")
+}
 
-	currUserVal := bson.D{
-		primitive.E{Key: "follwings", Value: updatedFollowingArray},
-		primitive.E{Key: "updated_at", Value: currentTime},
-	}
-	if err := uh.db.ModifyEntry(currUserKey, currUserVal); err != nil {
-		helpers.HandleDbError(err, w, uh.log, "error when updating current ussers follings")
+func (uh *UserHandler) changeFollow(w http.ResponseWriter, r *http.Request, op func(context.Context, primitive.ObjectID, primitive.ObjectID) (bool, error), changedMsg string, unchangedMsg string) {
+	followId, ok := pathID(w, r, "id")
+	if !ok {
 		return
 	}
-	followUserVal := bson.D{
-		primitive.E{Key: "follwers", Value: updatedFollowerArray},
-		primitive.E{Key: "updated_at", Value: currentTime},
-	}
-	if err := uh.db.ModifyEntry(followUserKey, followUserVal); err != nil {
-		helpers.HandleDbError(err, w, uh.log, "error when updating users followers")
+	currentUser, ok := requestingUser(w, r)
+	if !ok {
 		return
 	}
-	if option {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("user has been followed"))
+	if currentUser.UserID == followId {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeForbidden, "cannot follow yourself"))
 		return
-	} else {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("user has been unfollowed"))
+	}
+	changed, err := op(r.Context(), currentUser.UserID, followId)
+	if err != nil {
+		helpers.HandleDbError(err, w, r, uh.log, "error when changing the followers of user "+followId.Hex())
 		return
 	}
+	msg := unchangedMsg
+	if changed {
+		msg = changedMsg
+	}
+	helpers.WriteJSONMessage(w, http.StatusOK, stateChangeResponse{Changed: changed}, msg)
 }
 
 func (uh *UserHandler) HandleNotFound(w http.ResponseWriter, r *http.Request, msg string) {
 	uh.log.WriteToLogger(logger.WARNING, "invalid url was given to post handlers"+r.URL.Path)
-	w.WriteHeader(http.StatusNotFound)
-	w.Write([]byte(msg))
+	helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeNotFound, msg))
 }
@@ -0,0 +1,179 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"social-api/logger"
+	"social-api/middleware"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrorCode is the machine readable part of a error response, clients
+// should switch on these and never on the message
+type ErrorCode string
+
+// catalog of every error code the api can send, new codes need to be added
+// here with their status so they stay stable for clients
+const (
+	CodeBadRequest         ErrorCode = "bad_request"
+	CodeInvalidBody        ErrorCode = "invalid_body"
+	CodeBodyTooLarge       ErrorCode = "body_too_large"
+	CodeUnsupportedMedia   ErrorCode = "unsupported_media_type"
+	CodeValidation         ErrorCode = "validation_failed"
+	CodeInvalidID          ErrorCode = "invalid_id"
+	CodeWeakPassword       ErrorCode = "weak_password"
+	CodeUnauthorized       ErrorCode = "unauthorized"
+	CodeInvalidToken       ErrorCode = "invalid_token"
+	CodeInvalidCredentials ErrorCode = "invalid_credentials"
+	CodeInvalidCode        ErrorCode = "invalid_code"
+	CodeForbidden          ErrorCode = "forbidden"
+	CodeEmailNotVerified   ErrorCode = "email_not_verified"
+	CodeMissingScope       ErrorCode = "missing_scope"
+	CodeNotFound           ErrorCode = "not_found"
+	CodeMethodNotAllowed   ErrorCode = "method_not_allowed"
+	CodeConflict           ErrorCode = "conflict"
+	CodeTooManyRequests    ErrorCode = "too_many_requests"
+	CodeInternal           ErrorCode = "internal_error"
+	CodeUnavailable        ErrorCode = "service_unavailable"
+	CodeTimeout            ErrorCode = "timeout"
+	CodeClientClosed       ErrorCode = "client_closed_request"
+)
+
+// nginx style status for a request the client gave up on, nothing in
+// net/http has it and the client never sees it, it is for the access log
+const StatusClientClosedRequest int = 499
+
+var codeStatus = map[ErrorCode]int{
+	CodeBadRequest:         http.StatusBadRequest,
+	CodeInvalidBody:        http.StatusBadRequest,
+	CodeBodyTooLarge:       http.StatusRequestEntityTooLarge,
+	CodeUnsupportedMedia:   http.StatusUnsupportedMediaType,
+	CodeValidation:         http.StatusBadRequest,
+	CodeInvalidID:          http.StatusBadRequest,
+	CodeWeakPassword:       http.StatusBadRequest,
+	CodeUnauthorized:       http.StatusUnauthorized,
+	CodeInvalidToken:       http.StatusUnauthorized,
+	CodeInvalidCredentials: http.StatusUnauthorized,
+	CodeInvalidCode:        http.StatusUnauthorized,
+	CodeForbidden:          http.StatusForbidden,
+	CodeEmailNotVerified:   http.StatusForbidden,
+	CodeMissingScope:       http.StatusForbidden,
+	CodeNotFound:           http.StatusNotFound,
+	CodeMethodNotAllowed:   http.StatusMethodNotAllowed,
+	CodeConflict:           http.StatusConflict,
+	CodeTooManyRequests:    http.StatusTooManyRequests,
+	CodeInternal:           http.StatusInternalServerError,
+	CodeUnavailable:        http.StatusServiceUnavailable,
+	CodeTimeout:            http.StatusGatewayTimeout,
+	CodeClientClosed:       StatusClientClosedRequest,
+}
+
+// Status gives the http status sent with the code
+func (c ErrorCode) Status() int {
+	if status, ok := codeStatus[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// APIError is the body of every error response
+type APIError struct {
+	Code      ErrorCode `json:"code"`
+	Message   string    `json:"message"`
+	Details   any       `json:"details,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+}
+
+func NewAPIError(code ErrorCode, message string) *APIError {
+	return &APIError{Code: code, Message: message}
+}
+
+// adds extra info for the client like which fields failed
+func (e *APIError) WithDetails(details any) *APIError {
+	e.Details = details
+	return e
+}
+
+func (e *APIError) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+// sends the error as json with the status from the catalog and the id of the request
+func WriteError(w http.ResponseWriter, r *http.Request, apiErr *APIError) {
+	apiErr.RequestID = middleware.GetRequestID(r)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(apiErr.Code.Status())
+	json.NewEncoder(w).Encode(apiErr)
+}
+
+// turns a error from the Modeler interface (or the driver under it) into
+// the error the client should see
+func DbError(dbError error) *APIError {
+	switch {
+	case errors.Is(dbError, mongo.ErrNoDocuments):
+		return NewAPIError(CodeNotFound, "item not found")
+	case mongo.IsDuplicateKeyError(dbError):
+		return NewAPIError(CodeConflict, "item already exists")
+	case errors.Is(dbError, context.Canceled):
+		return NewAPIError(CodeClientClosed, "request was cancelled")
+	case mongo.IsTimeout(dbError), errors.Is(dbError, context.DeadlineExceeded):
+		return NewAPIError(CodeTimeout, "database did not respond in time")
+	case mongo.IsNetworkError(dbError), errors.Is(dbError, mongo.ErrClientDisconnected):
+		return NewAPIError(CodeUnavailable, "database is unavailable")
+	default:
+		return NewAPIError(CodeInternal, "internal server error")
+	}
+}
+
+// will handle the error returned from the Modeler interface, server errors
+// are logged with msg and the client only gets the generic message
+func HandleDbError(dbError error, w http.ResponseWriter, r *http.Request, log logger.Logger, msg string) {
+	apiErr := DbError(dbError)
+	if apiErr.Code.Status() >= http.StatusInternalServerError {
+		log.WriteToLogger(logger.ERROR, msg, dbError)
+	}
+	WriteError(w, r, apiErr)
+}
+
+// where in the body the problem was, sent as the details of the error
+type bodyErrorDetails struct {
+	Reason BodyErrorKind `json:"reason"`
+	Offset int64         `json:"offset,omitempty"`
+	Field  string        `json:"field,omitempty"`
+}
+
+// will handle the error returned from ParseBody
+func HandleParserError(parseError error, w http.ResponseWriter, r *http.Request, log logger.Logger) {
+	log.WriteToLogger(logger.WARNING, "bad request body for "+r.URL.Path, parseError)
+	var bodyErr *BodyError
+	if !errors.As(parseError, &bodyErr) {
+		WriteError(w, r, NewAPIError(CodeInvalidBody, "request body is not valid json"))
+		return
+	}
+	switch bodyErr.Kind {
+	case ErrBodyTooLarge:
+		WriteError(w, r, NewAPIError(CodeBodyTooLarge, fmt.Sprintf("request body can be at most %d bytes", BodyLimits.MaxBytes)))
+		return
+	case ErrBodyMediaType:
+		WriteError(w, r, NewAPIError(CodeUnsupportedMedia, "request body has to be application/json"))
+		return
+	}
+	messages := map[BodyErrorKind]string{
+		ErrBodyEmpty:        "request body is empty",
+		ErrBodySyntax:       "request body is not valid json",
+		ErrBodyFieldType:    "request body has a field with the wrong type",
+		ErrBodyUnknownField: "request body has a unknown field",
+		ErrBodyTrailingData: "request body has data after the json value",
+		ErrBodyRead:         "request body could not be read",
+	}
+	WriteError(w, r, NewAPIError(CodeInvalidBody, messages[bodyErr.Kind]).WithDetails(bodyErrorDetails{
+		Reason: bodyErr.Kind,
+		Offset: bodyErr.Offset,
+		Field:  bodyErr.Field,
+	}))
+}
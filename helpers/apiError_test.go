@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"social-api/middleware"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestDbError(t *testing.T) {
+	testtable := []struct {
+		err          error
+		expectedCode ErrorCode
+	}{
+		{err: mongo.ErrNoDocuments, expectedCode: CodeNotFound},
+		{err: fmt.Errorf("wrapped: %w", mongo.ErrNoDocuments), expectedCode: CodeNotFound},
+		{err: mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000, Message: "E11000 duplicate key"}}}, expectedCode: CodeConflict},
+		{err: context.DeadlineExceeded, expectedCode: CodeTimeout},
+		{err: fmt.Errorf("find: %w", context.Canceled), expectedCode: CodeClientClosed},
+		{err: mongo.ErrClientDisconnected, expectedCode: CodeUnavailable},
+		{err: errors.New("something else"), expectedCode: CodeInternal},
+	}
+	for _, tt := range testtable {
+		if got := DbError(tt.err).Code; got != tt.expectedCode {
+			t.Errorf("wrong code for %v, got=%s, want=%s", tt.err, got, tt.expectedCode)
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	handler := middleware.RequestID(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, NewAPIError(CodeWeakPassword, "password is too short").WithDetails(map[string]int{"minLength": 8}))
+	})
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("POST", "/auth/register", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("wrong status, got=%d, want=%d", rec.Code, http.StatusBadRequest)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("wrong content type, got=%s", ct)
+	}
+	var body struct {
+		Code      ErrorCode      `json:"code"`
+		Message   string         `json:"message"`
+		Details   map[string]int `json:"details"`
+		RequestID string         `json:"requestId"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("error body is not json, :%v", err)
+	}
+	if body.Code != CodeWeakPassword || body.Message != "password is too short" || body.Details["minLength"] != 8 {
+		t.Errorf("wrong error body, got=%+v", body)
+	}
+	if body.RequestID == "" || body.RequestID != rec.Header().Get(middleware.RequestIDHeader) {
+		t.Errorf("error body is missing the request id, got=%s", body.RequestID)
+	}
+}
@@ -1,23 +1,121 @@
 package helpers
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
-	"social-api/types"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
 )
 
-// takes in io.ReaderCloser (request body) and unmarshals the request
-// into the val (type bounded by Requesttypes in types package)
-// returns a pointer to this newly filled reqeust Type (val should be a empty struct of any RequestType)
-func ParseBody[T types.AuthUserRequest | types.RequestPost | types.RequestUser](body io.ReadCloser, val T) (*T, error) {
-	b, err := io.ReadAll(body)
+// BodyOptions are the limits ParseBody puts on a request body
+type BodyOptions struct {
+	// biggest body that will be read, anything bigger is a ErrBodyTooLarge
+	MaxBytes int64
+	// fail on fields the request type doesnt have instead of ignoring them
+	DisallowUnknownFields bool
+}
+
+// limits used by ParseBody, main sets these from the env at boot
+var BodyLimits = BodyOptions{
+	MaxBytes:              1 << 20,
+	DisallowUnknownFields: false,
+}
+
+// what was wrong with a request body
+type BodyErrorKind string
+
+const (
+	ErrBodyTooLarge     BodyErrorKind = "too_large"
+	ErrBodyMediaType    BodyErrorKind = "unsupported_media_type"
+	ErrBodyEmpty        BodyErrorKind = "empty"
+	ErrBodySyntax       BodyErrorKind = "syntax"
+	ErrBodyFieldType    BodyErrorKind = "field_type"
+	ErrBodyUnknownField BodyErrorKind = "unknown_field"
+	ErrBodyTrailingData BodyErrorKind = "trailing_data"
+	ErrBodyRead         BodyErrorKind = "read"
+)
+
+// BodyError is returned by ParseBody, offset is the byte in the body the
+// problem was found at and field the json field it was in (when known)
+type BodyError struct {
+	Kind   BodyErrorKind
+	Offset int64
+	Field  string
+	Err    error
+}
+
+func (e *BodyError) Error() string {
+	msg := "bad request body (" + string(e.Kind) + ")"
+	if e.Field != "" {
+		msg += " in field " + e.Field
+	}
+	if e.Offset > 0 {
+		msg += fmt.Sprintf(" at byte %d", e.Offset)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *BodyError) Unwrap() error {
+	return e.Err
+}
+
+// reads the json body of the request into val (should be a empty request
+// struct) and returns a pointer to it, the body has to be application/json,
+// a single json value and no bigger than BodyLimits.MaxBytes
+func ParseBody[T any](r *http.Request, val T) (*T, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return nil, &BodyError{Kind: ErrBodyMediaType, Err: errors.New("content type has to be application/json")}
+	}
+	// read one byte past the limit so a body of exactly the limit is still fine
+	b, err := io.ReadAll(io.LimitReader(r.Body, BodyLimits.MaxBytes+1))
 	if err != nil {
-		return nil, errors.New("failed to readAll of byte stream")
+		return nil, &BodyError{Kind: ErrBodyRead, Err: err}
+	}
+	if int64(len(b)) > BodyLimits.MaxBytes {
+		return nil, &BodyError{Kind: ErrBodyTooLarge, Err: fmt.Errorf("body is bigger than %d bytes", BodyLimits.MaxBytes)}
 	}
-	if err := json.Unmarshal(b, &val); err != nil {
-		return nil, errors.New("error when unmarshaling the data into generic")
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil, &BodyError{Kind: ErrBodyEmpty}
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if BodyLimits.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(&val); err != nil {
+		return nil, decodeError(err, dec.InputOffset())
+	}
+	// the body should be one json value and nothing after it
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, &BodyError{Kind: ErrBodyTrailingData, Offset: dec.InputOffset()}
 	}
 	return &val, nil
+}
 
+// turns the errors from encoding/json into a BodyError
+func decodeError(err error, offset int64) *BodyError {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return &BodyError{Kind: ErrBodySyntax, Offset: syntaxErr.Offset, Err: err}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &BodyError{Kind: ErrBodySyntax, Offset: offset, Err: err}
+	case errors.As(err, &typeErr):
+		return &BodyError{Kind: ErrBodyFieldType, Offset: typeErr.Offset, Field: typeErr.Field, Err: fmt.Errorf("expected %s", typeErr.Type)}
+	}
+	// encoding/json has no type for this one, the message is `json: unknown field "name"`
+	if quoted, found := strings.CutPrefix(err.Error(), "json: unknown field "); found {
+		field, _ := strconv.Unquote(quoted)
+		return &BodyError{Kind: ErrBodyUnknownField, Offset: offset, Field: field}
+	}
+	return &BodyError{Kind: ErrBodySyntax, Offset: offset, Err: err}
 }
@@ -0,0 +1,55 @@
+package helpers
+
+import (
+	"errors"
+	"net/http/httptest"
+	"social-api/types"
+	"strings"
+	"testing"
+)
+
+func TestParseBody(t *testing.T) {
+	defer func(limits BodyOptions) { BodyLimits = limits }(BodyLimits)
+	testtable := []struct {
+		body          string
+		contentType   string
+		strict        bool
+		expectedKind  BodyErrorKind
+		expectedField string
+	}{
+		{body: `{"desc":"hi","img":"https://a.com/a.png"}`, contentType: "application/json"},
+		{body: `{"desc":"hi","img":"x"}`, contentType: "application/json; charset=utf-8"},
+		{body: `{"desc":"hi"}`, contentType: "text/plain", expectedKind: ErrBodyMediaType},
+		{body: `{"desc":"hi"}`, contentType: "", expectedKind: ErrBodyMediaType},
+		{body: ``, contentType: "application/json", expectedKind: ErrBodyEmpty},
+		{body: `{"desc":`, contentType: "application/json", expectedKind: ErrBodySyntax},
+		{body: `{"desc":"hi",}`, contentType: "application/json", expectedKind: ErrBodySyntax},
+		{body: `{"desc":5}`, contentType: "application/json", expectedKind: ErrBodyFieldType, expectedField: "desc"},
+		{body: `{"desc":"hi"} {"desc":"again"}`, contentType: "application/json", expectedKind: ErrBodyTrailingData},
+		{body: `{"desc":"hi","userId":"someone"}`, contentType: "application/json"},
+		{body: `{"desc":"hi","userId":"someone"}`, contentType: "application/json", strict: true, expectedKind: ErrBodyUnknownField, expectedField: "userId"},
+		{body: `{"desc":"` + strings.Repeat("a", 100) + `"}`, contentType: "application/json", expectedKind: ErrBodyTooLarge},
+	}
+	for _, tt := range testtable {
+		BodyLimits = BodyOptions{MaxBytes: 64, DisallowUnknownFields: tt.strict}
+		req := httptest.NewRequest("POST", "/v1/posts", strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		post, err := ParseBody(req, types.RequestPost{})
+		if tt.expectedKind == "" {
+			if err != nil || post.Desc != "hi" {
+				t.Errorf("body %s should parse, got=%v", tt.body, err)
+			}
+			continue
+		}
+		var bodyErr *BodyError
+		if !errors.As(err, &bodyErr) {
+			t.Errorf("body %s should fail with a BodyError, got=%v", tt.body, err)
+			continue
+		}
+		if bodyErr.Kind != tt.expectedKind || bodyErr.Field != tt.expectedField {
+			t.Errorf("wrong error for body %s, got=%s/%s, want=%s/%s", tt.body, bodyErr.Kind, bodyErr.Field, tt.expectedKind, tt.expectedField)
+		}
+	}
+}
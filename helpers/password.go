@@ -0,0 +1,95 @@
+package helpers
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt ignores everything after 72 bytes so longer passwords are not allowed
+const maxPasswordBytes int = 72
+
+// rules every new password has to follow, also knows the bcrypt cost
+// so old hashes can be upgraded when the user logs in
+type PasswordPolicy struct {
+	MinLength int
+	Cost      int
+	common    map[string]bool
+}
+
+// commonListPath is a file with one common/breached password per line,
+// the policy still works without the list if the path is empty
+func NewPasswordPolicy(minLength int, cost int, commonListPath string) (*PasswordPolicy, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	pp := &PasswordPolicy{
+		MinLength: minLength,
+		Cost:      cost,
+		common:    make(map[string]bool),
+	}
+	if commonListPath == "" {
+		return pp, nil
+	}
+	file, err := os.Open(commonListPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pp.common[strings.ToLower(line)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return pp, nil
+}
+
+// returns a error saying what is wrong with the password, the message is
+// safe to send to the client
+func (pp *PasswordPolicy) Check(password string, username string, email string) error {
+	if len([]rune(password)) < pp.MinLength {
+		return fmt.Errorf("password must be at least %d characters", pp.MinLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("password must be at most %d bytes", maxPasswordBytes)
+	}
+	lower := strings.ToLower(password)
+	if pp.common[lower] {
+		return errors.New("password is too common")
+	}
+	if username != "" && strings.Contains(lower, strings.ToLower(username)) {
+		return errors.New("password cannot contain the username")
+	}
+	if local, _, found := strings.Cut(email, "@"); found && len(local) >= 3 && strings.Contains(lower, strings.ToLower(local)) {
+		return errors.New("password cannot contain the email")
+	}
+	return nil
+}
+
+// hashes the password with the cost from the policy
+func (pp *PasswordPolicy) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), pp.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// true if the hash was made with a lower cost than the policy has now
+func (pp *PasswordPolicy) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false
+	}
+	return cost < pp.Cost
+}
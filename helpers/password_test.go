@@ -0,0 +1,55 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "common.txt")
+	if err := os.WriteFile(path, []byte("# comment\npassword123\nQwerty\n"), 0666); err != nil {
+		t.Fatalf("error when writing common password file, :%v", err)
+	}
+	policy, err := NewPasswordPolicy(8, bcrypt.MinCost, path)
+	if err != nil {
+		t.Fatalf("error when making the policy, :%v", err)
+	}
+	testtable := []struct {
+		password string
+		username string
+		email    string
+		valid    bool
+	}{
+		{password: "short", username: "bob", email: "bob@gmail.com", valid: false},
+		{password: "password123", username: "bob", email: "bob@gmail.com", valid: false},
+		{password: "QWERTY", username: "bob", email: "bob@gmail.com", valid: false},
+		{password: "mynameisgabe!", username: "gabe", email: "g@gmail.com", valid: false},
+		{password: "tommy-rocks-99", username: "tom", email: "tommy@gmail.com", valid: false},
+		{password: "correct horse battery", username: "bob", email: "bob@gmail.com", valid: true},
+		{password: string(make([]byte, 73)), username: "bob", email: "bob@gmail.com", valid: false},
+	}
+	for _, tt := range testtable {
+		err := policy.Check(tt.password, tt.username, tt.email)
+		if (err == nil) != tt.valid {
+			t.Errorf("wrong result for password %q, got=%v, want valid=%t", tt.password, err, tt.valid)
+		}
+	}
+}
+
+func TestPasswordPolicyNeedsRehash(t *testing.T) {
+	policy, err := NewPasswordPolicy(8, bcrypt.MinCost+1, "")
+	if err != nil {
+		t.Fatalf("error when making the policy, :%v", err)
+	}
+	oldHash, _ := bcrypt.GenerateFromPassword([]byte("some password"), bcrypt.MinCost)
+	newHash, _ := policy.Hash("some password")
+	if !policy.NeedsRehash(string(oldHash)) {
+		t.Errorf("hash with lower cost should need a rehash")
+	}
+	if policy.NeedsRehash(newHash) {
+		t.Errorf("hash with the policy cost should not need a rehash")
+	}
+}
@@ -2,8 +2,8 @@ package helpers
 
 import "errors"
 
-func RemoveElement(array []string, element string) ([]string, error) {
-	newArray := make([]string, 0)
+func RemoveElement[T comparable](array []T, element T) ([]T, error) {
+	newArray := make([]T, 0)
 	index := getIndex(array, element)
 	if index == -1 {
 		return newArray, errors.New("element not in array")
@@ -13,7 +13,7 @@ func RemoveElement(array []string, element string) ([]string, error) {
 
 }
 
-func getIndex(array []string, element string) int {
+func getIndex[T comparable](array []T, element T) int {
 	for i, v := range array {
 		if v == element {
 			return i
@@ -0,0 +1,59 @@
+package helpers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Envelope is the body of every successful response, data is always there
+// (null when there is nothing to send) so clients never have to guess
+type Envelope struct {
+	Data    any    `json:"data"`
+	Message string `json:"message,omitempty"`
+	Meta    *Meta  `json:"meta,omitempty"`
+}
+
+// extra info about a list response, next cursor is only set when there
+// is another page (send it back as ?cursor= to get that page)
+type Meta struct {
+	Count      int    `json:"count"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+func writeEnvelope(w http.ResponseWriter, status int, envelope Envelope) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(envelope)
+}
+
+// sends data wrapped in the envelope
+func WriteJSON(w http.ResponseWriter, status int, data any) {
+	writeEnvelope(w, status, Envelope{Data: data})
+}
+
+// sends data with a message for the user, like after creating something
+func WriteJSONMessage(w http.ResponseWriter, status int, data any, message string) {
+	writeEnvelope(w, status, Envelope{Data: data, Message: message})
+}
+
+// sends a list with its count in the meta
+func WriteList[T any](w http.ResponseWriter, status int, items []T) {
+	if items == nil {
+		// send [] and not null for a empty list
+		items = []T{}
+	}
+	writeEnvelope(w, status, Envelope{Data: items, Meta: &Meta{Count: len(items)}})
+}
+
+// sends one page of a list, nextCursor is empty on the last page
+func WritePage[T any](w http.ResponseWriter, status int, items []T, nextCursor string) {
+	if items == nil {
+		items = []T{}
+	}
+	writeEnvelope(w, status, Envelope{Data: items, Meta: &Meta{Count: len(items), NextCursor: nextCursor}})
+}
+
+// sends only a message for actions that have nothing to return
+func WriteMessage(w http.ResponseWriter, status int, message string) {
+	writeEnvelope(w, status, Envelope{Message: message})
+}
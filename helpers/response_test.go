@@ -0,0 +1,32 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteEnvelope(t *testing.T) {
+	testtable := []struct {
+		write    func(w http.ResponseWriter)
+		expected string
+	}{
+		{write: func(w http.ResponseWriter) { WriteJSON(w, http.StatusOK, map[string]int{"a": 1}) }, expected: `{"data":{"a":1}}`},
+		{write: func(w http.ResponseWriter) { WriteMessage(w, http.StatusOK, "logged out") }, expected: `{"data":null,"message":"logged out"}`},
+		{write: func(w http.ResponseWriter) { WriteList[string](w, http.StatusOK, nil) }, expected: `{"data":[],"meta":{"count":0}}`},
+		{write: func(w http.ResponseWriter) { WriteList(w, http.StatusOK, []int{1, 2}) }, expected: `{"data":[1,2],"meta":{"count":2}}`},
+		{write: func(w http.ResponseWriter) { WritePage(w, http.StatusOK, []int{1}, "abc") }, expected: `{"data":[1],"meta":{"count":1,"nextCursor":"abc"}}`},
+		{write: func(w http.ResponseWriter) { WritePage[int](w, http.StatusOK, nil, "") }, expected: `{"data":[],"meta":{"count":0}}`},
+	}
+	for _, tt := range testtable {
+		rec := httptest.NewRecorder()
+		tt.write(rec)
+		if got := strings.TrimSpace(rec.Body.String()); got != tt.expected {
+			t.Errorf("wrong envelope, got=%s, want=%s", got, tt.expected)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("wrong content type, got=%s", ct)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package helpers
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// how long a access token is valid for after login
+const AccessTokenDuration time.Duration = 15 * time.Minute
+
+// how long the user has to enter their 2fa code after giving the password
+const ChallengeTokenDuration time.Duration = 5 * time.Minute
+
+// audience of the challenge tokens so they can never be used as a access token
+const challengeAudience string = "2fa-challenge"
+
+// gets the secret used to sign the tokens from the env
+// (lives in the .env with the other secrets)
+func tokenSecret() ([]byte, error) {
+	secret := os.Getenv("JWTSecret")
+	if secret == "" {
+		return nil, errors.New("no token secret set in env")
+	}
+	return []byte(secret), nil
+}
+
+// will make a signed access token with the id of the given user as the subject
+// and the id of the session it was made for (so revoking the session kills the token)
+func NewAccessToken(userId string, sessionId string) (string, error) {
+	secret, err := tokenSecret()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Subject:   userId,
+		ID:        sessionId,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenDuration)),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+}
+
+// checks the signature and expiration of the given token
+// returns the user id and session id stored in the token if its valid
+func ParseAccessToken(token string) (string, string, error) {
+	secret, err := tokenSecret()
+	if err != nil {
+		return "", "", err
+	}
+	var claims jwt.RegisteredClaims
+	_, parseErr := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if parseErr != nil {
+		return "", "", errors.New("invalid access token")
+	}
+	if claims.Subject == "" || claims.ID == "" || len(claims.Audience) != 0 {
+		return "", "", errors.New("access token is missing its subject or session")
+	}
+	return claims.Subject, claims.ID, nil
+}
+
+// makes the short lived token given after a correct password when the user
+// has 2fa on, it only proves the first step of the login was done
+func NewChallengeToken(userId string) (string, error) {
+	secret, err := tokenSecret()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Subject:   userId,
+		Audience:  jwt.ClaimStrings{challengeAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ChallengeTokenDuration)),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+}
+
+// checks the challenge token and returns the id of the user it was made for
+func ParseChallengeToken(token string) (string, error) {
+	secret, err := tokenSecret()
+	if err != nil {
+		return "", err
+	}
+	var claims jwt.RegisteredClaims
+	_, parseErr := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(challengeAudience))
+	if parseErr != nil || claims.Subject == "" {
+		return "", errors.New("invalid challenge token")
+	}
+	return claims.Subject, nil
+}
@@ -0,0 +1,40 @@
+package helpers
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// test vectors from rfc 6238 appendix b (sha1, 8 digits cut down to the last 6)
+func TestTOTPCode(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	testtable := []struct {
+		input    int64
+		expected string
+	}{
+		{input: 59, expected: "287082"},
+		{input: 1111111109, expected: "081804"},
+		{input: 1111111111, expected: "050471"},
+		{input: 1234567890, expected: "005924"},
+		{input: 2000000000, expected: "279037"},
+	}
+	for _, tt := range testtable {
+		got, err := TOTPCode(secret, time.Unix(tt.input, 0))
+		if err != nil {
+			t.Fatalf("error when making the code, :%v", err)
+		}
+		if got != tt.expected {
+			t.Errorf("wrong code, got=%s, want=%s", got, tt.expected)
+		}
+		if !ValidTOTP(secret, got, time.Unix(tt.input+int64(totpPeriod), 0)) {
+			t.Errorf("code should still be valid one step later, time=%d", tt.input)
+		}
+		if step, _ := TOTPStep(secret, got, time.Unix(tt.input+int64(totpPeriod), 0)); step != tt.input/totpPeriod {
+			t.Errorf("wrong step, got=%d, want=%d", step, tt.input/totpPeriod)
+		}
+		if ValidTOTP(secret, got, time.Unix(tt.input+5*int64(totpPeriod), 0)) {
+			t.Errorf("code should not be valid five steps later, time=%d", tt.input)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package helpers
+
+import (
+	"errors"
+	"net/http"
+	"social-api/validate"
+)
+
+// checks the request against its validate tags, writes every field that
+// failed and returns false if it is invalid
+func ValidRequest(w http.ResponseWriter, r *http.Request, request any) bool {
+	err := validate.Struct(request)
+	if err == nil {
+		return true
+	}
+	apiErr := NewAPIError(CodeValidation, "request has invalid fields")
+	var fieldErrs validate.FieldErrors
+	if errors.As(err, &fieldErrs) {
+		apiErr = apiErr.WithDetails(fieldErrs)
+	}
+	WriteError(w, r, apiErr)
+	return false
+}
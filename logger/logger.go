@@ -77,3 +77,17 @@ func (cm *CustomLogger) WriteToLogger(level LoggerLevel, msg string, err ...erro
 	log.Println(msg)
 
 }
+
+// makes a logger that writes every level to the given file
+func NewFileLogger(path string) (*CustomLogger, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+	cm := NewLogger()
+	cm.AddLogger(INFO, log.New(file, "INFO: ", log.Ldate|log.Ltime))
+	cm.AddLogger(WARNING, log.New(file, "WARNING: ", log.Ldate|log.Ltime))
+	cm.AddLogger(ERROR, log.New(file, "ERROR: ", log.Ldate|log.Ltime))
+	cm.AddLogger(FATAL, log.New(file, "FATAL: ", log.Ldate|log.Ltime))
+	return cm, nil
+}
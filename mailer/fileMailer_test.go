@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailerSend(t *testing.T) {
+	testtable := []struct {
+		input    Message
+		expected []string
+	}{
+		{input: Message{To: "bob@gmail.com", Subject: "reset your password", Body: "token=abc"}, expected: []string{"To: bob@gmail.com", "Subject: reset your password", "token=abc"}},
+		{input: Message{To: "gabe@gmail.com", Subject: "second", Body: "second body"}, expected: []string{"To: gabe@gmail.com", "second body"}},
+	}
+	path := filepath.Join(t.TempDir(), "outbox.txt")
+	fileMailer := NewFileMailer(path)
+	for _, tt := range testtable {
+		if err := fileMailer.Send(tt.input); err != nil {
+			t.Fatalf("error when sending to file mailer, :%v", err)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("error when reading the outbox, :%v", err)
+		}
+		for _, want := range tt.expected {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("outbox missing value, want=%s, got=%s", want, string(b))
+			}
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package mailer
+
+// every way of sending email to the users needs to implement this so the
+// handlers dont care if the mail goes to a real server or to a file
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(msg Message) error
+}
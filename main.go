@@ -1,19 +1,30 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 	"social-api/database"
 	"social-api/handlers"
 	"social-api/helpers"
+	"social-api/logger"
+	"social-api/mailer"
+	"social-api/middleware"
+	"social-api/migrations"
 	"social-api/model"
+	"social-api/router"
+	"social-api/throttle"
 	"social-api/types"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // make sure to add some logging later
@@ -22,151 +33,291 @@ const postEndpointLogPath string = "postLogFile.txt"
 // auth and user enpoint will use this log file
 const userEndpointLogPath string = "userLogFile.txt"
 
+// list of common passwords that cant be used
+const defaultCommonPasswordsPath string = "data/commonPasswords.txt"
+
+// admin actions get their own log file
+const adminEndpointLogPath string = "adminLogFile.txt"
+
+// every request gets a line in this file
+const accessLogPath string = "accessLogFile.txt"
+
+// where mail goes when there is no smtp server set up
+const mailOutboxPath string = "mailOutbox.txt"
+
+// builds the password policy from the env, uses sane defaults for anything not set
+func newPasswordPolicy() *helpers.PasswordPolicy {
+	minLength, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH"))
+	if err != nil {
+		minLength = 8
+	}
+	cost, err := strconv.Atoi(os.Getenv("BCRYPT_COST"))
+	if err != nil {
+		cost = bcrypt.DefaultCost
+	}
+	commonPath := os.Getenv("COMMON_PASSWORDS_FILE")
+	if commonPath == "" {
+		commonPath = defaultCommonPasswordsPath
+	}
+	policy, err := helpers.NewPasswordPolicy(minLength, cost, commonPath)
+	if err != nil {
+		panic("error when loading the password policy: " + err.Error())
+	}
+	return policy
+}
+
+// limits on request bodies, MAX_BODY_BYTES is the biggest body allowed and
+// STRICT_JSON=true rejects fields the request types dont have
+func bodyLimits() helpers.BodyOptions {
+	limits := helpers.BodyLimits
+	if maxBytes, err := strconv.ParseInt(os.Getenv("MAX_BODY_BYTES"), 10, 64); err == nil && maxBytes > 0 {
+		limits.MaxBytes = maxBytes
+	}
+	limits.DisallowUnknownFields = os.Getenv("STRICT_JSON") == "true"
+	return limits
+}
+
+// how long one database call can take, DB_READ_TIMEOUT and DB_WRITE_TIMEOUT
+// are durations like 3s (0 turns the timeout off)
+func dbTimeouts() model.Timeouts {
+	timeouts := model.OperationTimeouts
+	if read, err := time.ParseDuration(os.Getenv("DB_READ_TIMEOUT")); err == nil {
+		timeouts.Read = read
+	}
+	if write, err := time.ParseDuration(os.Getenv("DB_WRITE_TIMEOUT")); err == nil {
+		timeouts.Write = write
+	}
+	return timeouts
+}
+
+// the declared indexes are made at boot, INDEXES=dry-run only prints how the
+// database differs from them and INDEXES=off skips them
+func indexSetting() database.IndexMode {
+	switch os.Getenv("INDEXES") {
+	case "dry-run":
+		return database.IndexesDryRun
+	case "off":
+		return database.IndexesOff
+	}
+	return database.IndexesApply
+}
+
+// how many replies deep a comment thread can go, COMMENT_MAX_DEPTH=0 only
+// allows comments on the post
+func commentDepth() int {
+	depth, err := strconv.Atoi(os.Getenv("COMMENT_MAX_DEPTH"))
+	if err != nil || depth < 0 {
+		return handlers.CommentDepth
+	}
+	return depth
+}
+
+// origins of the web clients allowed to call the api, comma separated in the env
+func corsOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// the collections the handlers read and write, main uses the mongo models
+// and the tests can use the memory ones
+type stores struct {
+	users    model.UserStore
+	posts    model.PostStore
+	comments model.CommentStore
+	sessions model.SessionStore
+	tokens   model.UserTokenStore
+	apiKeys  model.Modeler[*types.APIKeys, bson.D]
+	audit    model.Modeler[*types.AuditEntries, bson.D]
+	tx       model.Transactor
+}
+
+func mongoStores(db *mongo.Database) stores {
+	return stores{
+		users:    model.NewUserModel(db),
+		posts:    model.NewPostModel(db),
+		comments: model.NewCommentModel(db),
+		sessions: model.NewSessionModel(db),
+		tokens:   model.NewUserTokenModel(db),
+		apiKeys:  model.NewAPIKeyModel(db),
+		audit:    model.NewAuditModel(db),
+		tx:       model.NewTransactor(db),
+	}
+}
+
+// everything the routes need besides the stores
+type serverConfig struct {
+	mail      mailer.Mailer
+	passwords *helpers.PasswordPolicy
+	// where failed logins are counted
+	attempts    throttle.Store
+	corsOrigins []string
+	// folder the log files are made in, empty for the working directory
+	logDir string
+}
+
+// builds the router with every route of the api
+func newServer(s stores, cfg serverConfig) *router.Router {
+	logPath := func(name string) string {
+		return filepath.Join(cfg.logDir, name)
+	}
+	accountThrottle := throttle.NewThrottler(cfg.attempts, throttle.AccountPolicy, "account:")
+	ipThrottle := throttle.NewThrottler(cfg.attempts, throttle.IPPolicy, "ip:")
+
+	AuthHandlers := handlers.NewAuthHandler(s.users, s.sessions, s.tokens, s.apiKeys, s.tx, cfg.mail, cfg.passwords, accountThrottle, ipThrottle, logPath(userEndpointLogPath))
+	authz := handlers.NewAuthorizer(s.audit, logger.NewLogger())
+	UserHandlers := handlers.NewUserHandler(s.users, authz, cfg.passwords, AuthHandlers, logPath(userEndpointLogPath))
+	PostsHandlers := handlers.NewPostHandler(s.posts, s.comments, s.tx, authz, logPath(postEndpointLogPath))
+	AdminHandlers := handlers.NewAdminHandler(s.users, authz, logPath(adminEndpointLogPath))
+
+	requireAuth := AuthHandlers.RequireAuth
+	requireVerified := AuthHandlers.RequireVerified
+	mux := router.New()
+	mux.NotFound = func(w http.ResponseWriter, r *http.Request) {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeNotFound, "no endpoint for given url"))
+	}
+	mux.MethodNotAllowed = func(w http.ResponseWriter, r *http.Request) {
+		helpers.WriteError(w, r, helpers.NewAPIError(helpers.CodeMethodNotAllowed, "method not allowed for given url"))
+	}
+
+	accessLog, logErr := logger.NewFileLogger(logPath(accessLogPath))
+	if logErr != nil {
+		panic("error when making the access log file" + logErr.Error())
+	}
+	mux.GET("/tester", PostsHandlers.Test)
+
+	// every api route is versioned so the response shapes can change
+	// under a new prefix without breaking old clients. order matters, the
+	// request id has to exist before anything logs and cors has to answer
+	// preflight requests before the router sends a 405
+	v1 := mux.Group("/v1",
+		middleware.RequestID,
+		middleware.AccessLog(accessLog),
+		middleware.Recover(accessLog),
+		middleware.CORS(middleware.DefaultCORSConfig(cfg.corsOrigins)),
+	)
+
+	auth := v1.Group("/auth")
+	auth.POST("/register", AuthHandlers.Register)
+	auth.POST("/login", AuthHandlers.Login)
+	auth.POST("/forgot-password", AuthHandlers.ForgotPassword)
+	auth.POST("/reset-password", AuthHandlers.ResetPassword)
+	auth.POST("/verify-email", AuthHandlers.VerifyEmail)
+	auth.POST("/resend-verification", AuthHandlers.ResendVerification)
+	auth.POST("/refresh", AuthHandlers.Refresh)
+	auth.POST("/logout", AuthHandlers.Logout, requireAuth)
+	auth.POST("/logout-all", AuthHandlers.LogoutAll, requireAuth)
+	auth.GET("/sessions", AuthHandlers.GetSessions, requireAuth)
+	auth.DELETE("/sessions/{id}", AuthHandlers.RevokeSession, requireAuth)
+	auth.POST("/2fa/enroll", AuthHandlers.EnrollTwoFactor, requireAuth)
+	auth.POST("/2fa/confirm", AuthHandlers.ConfirmTwoFactor, requireAuth)
+	auth.POST("/2fa/disable", AuthHandlers.DisableTwoFactor, requireAuth)
+	// second step of login so there is no access token yet
+	auth.POST("/2fa/verify", AuthHandlers.VerifyTwoFactor)
+
+	users := v1.Group("/users")
+	users.GET("/{id}", UserHandlers.GetUser)
+	users.PUT("/{id}", UserHandlers.UpdateUser, requireAuth)
+	users.DELETE("/{id}", UserHandlers.DeleteUser, requireAuth)
+	// unverified accounts cant follow anyone
+	users.POST("/{id}/follow", UserHandlers.Follow, AuthHandlers.RequireScope(types.ScopeUsersFollow), requireVerified)
+	users.POST("/{id}/unfollow", UserHandlers.Unfollow, AuthHandlers.RequireScope(types.ScopeUsersFollow), requireVerified)
+	// api keys can only be managed when logged in with a password
+	apiKeys := users.Group("/{id}/api-keys", requireAuth)
+	apiKeys.GET("", AuthHandlers.GetAPIKeys)
+	apiKeys.POST("", AuthHandlers.CreateAPIKey)
+	apiKeys.PUT("/{keyId}", AuthHandlers.RenameAPIKey)
+	apiKeys.DELETE("/{keyId}", AuthHandlers.RevokeAPIKey)
+
+	posts := v1.Group("/posts")
+	// unverified accounts cant post
+	posts.POST("", PostsHandlers.CreatePost, AuthHandlers.RequireScope(types.ScopePostsWrite), requireVerified)
+	posts.GET("/{id}", PostsHandlers.GetPost)
+	posts.PUT("/{id}", PostsHandlers.UpdatePost, AuthHandlers.RequireScope(types.ScopePostsWrite))
+	posts.DELETE("/{id}", PostsHandlers.DeletePost, AuthHandlers.RequireScope(types.ScopePostsWrite))
+	posts.POST("/{id}/like", PostsHandlers.LikePost, AuthHandlers.RequireScope(types.ScopePostsWrite))
+	posts.POST("/{id}/unlike", PostsHandlers.UnlikePost, AuthHandlers.RequireScope(types.ScopePostsWrite))
+	// unverified accounts cant comment, but can still edit and delete old comments
+	comments := posts.Group("/{id}/comments")
+	comments.GET("", PostsHandlers.GetComments)
+	comments.POST("", PostsHandlers.CreateComment, AuthHandlers.RequireScope(types.ScopeCommentsWrite), requireVerified)
+	comments.PUT("/{commentId}", PostsHandlers.UpdateComment, AuthHandlers.RequireScope(types.ScopeCommentsWrite))
+	comments.DELETE("/{commentId}", PostsHandlers.DeleteComment, AuthHandlers.RequireScope(types.ScopeCommentsWrite))
+
+	// the timeline is built for the user the access token belongs to
+	v1.GET("/timeline/all", PostsHandlers.GetTimeLine, AuthHandlers.RequireScope(types.ScopeTimelineRead))
+
+	// admin endpoints, every route here needs the manage roles permission
+	admin := v1.Group("/admin", requireAuth, authz.Require(types.PermManageRoles))
+	admin.POST("/users/{id}/roles", AdminHandlers.GrantRole)
+	admin.DELETE("/users/{id}/roles/{role}", AdminHandlers.RevokeRole)
+
+	return mux
+}
+
 func main() {
 	godotenv.Load(".env")
 	host := os.Getenv("HOST")
 	port := os.Getenv("PORT")
 	uri := os.Getenv("MONGO_URL")
 	databaseName := os.Getenv("DATABASE_NAME")
+	database.IndexSetting = indexSetting()
 	dbClient := database.ConnectDatabase(uri, databaseName)
-	userModel := model.NewUserModel(dbClient)
-
-	AuthHandlers := handlers.NewAuthHandler(userModel, userEndpointLogPath)
-	UserHandlers := handlers.NewUserHandler(userModel, userEndpointLogPath)
-	PostsHandlers := handlers.NewPostHandler(model.NewPostModel(dbClient), postEndpointLogPath)
-
-	http.HandleFunc("/timeline/", func(w http.ResponseWriter, r *http.Request) {
-		paths := strings.Split(r.URL.Path, "/")
-		requestUser, err := helpers.ParseBody(r.Body, types.AuthUserRequest{})
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("error when parsing the request"))
-			return
-		}
-		dbUser, dbErr := userModel.GetEntry(bson.D{primitive.E{Key: "_id", Value: requestUser.UserId}})
-		if dbErr != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("error when getting given user"))
-			return
+	model.OperationTimeouts = dbTimeouts()
+	migrator := migrations.NewRunner(dbClient)
+	// go run . migrate status|up|down N [--dry-run]
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrator.RunCLI(context.Background(), os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		switch len(paths) - 1 {
-		case 2:
-			switch paths[2] {
-			case "all":
-				PostsHandlers.GetTimeLine(w, r, dbUser)
-				// this leaves the option to add filters to the timeline
-				//(dont know how i would do that right now though)
-			}
-		default:
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("url does not match any timeline endpoint"))
+		return
+	}
+	// MIGRATE_ON_BOOT=true applies the pending migrations before serving,
+	// otherwise the api only warns that the data is behind the code
+	if os.Getenv("MIGRATE_ON_BOOT") == "true" {
+		if err := migrator.Up(context.Background()); err != nil {
+			panic("error when running the migrations: " + err.Error())
 		}
-	})
+	} else if pending, err := migrator.Pending(context.Background()); err == nil && len(pending) > 0 {
+		fmt.Printf("warning: %d migrations are pending, run: migrate up\n", len(pending))
+	}
+	if err := database.SetupIndexes(dbClient); err != nil {
+		panic("error when setting up the indexes: " + err.Error())
+	}
+	passwords := newPasswordPolicy()
+	helpers.BodyLimits = bodyLimits()
+	handlers.CommentDepth = commentDepth()
 
-	http.HandleFunc("/tester", PostsHandlers.Test)
-	http.HandleFunc("/auth/", func(w http.ResponseWriter, r *http.Request) {
-		paths := strings.Split(r.URL.Path, "/")
-		switch len(paths) - 1 {
-		case 2:
-			// will switch on the string of to dermin which user endpoint handler
-			// to use
-			switch paths[2] {
-			case "register":
-				AuthHandlers.Register(w, r)
-			case "login":
-				AuthHandlers.Login(w, r)
-			case "test":
-				AuthHandlers.Test(w, r)
-			}
-		default:
-			AuthHandlers.HandleNotFound(w, r, "no user endpoint for given url")
-			return
-		}
-	})
-	http.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
-		paths := strings.Split(r.URL.Path, "/")
-		fmt.Println(paths)
-		switch len(paths) - 1 {
-		case 3:
-			id := paths[2]
-			if len(id) <= 1 {
-				UserHandlers.HandleNotFound(w, r, "no user id was given in path")
-				return
-			}
-			if paths[3] == "follow" || paths[3] == "unfollow" {
-				fmt.Println("follow/unfollow user hit")
-				UserHandlers.FollowUnfollow(w, r, id)
-			} else {
-				UserHandlers.HandleNotFound(w, r, "invaild option was given for user id")
-			}
-		case 2:
-			id := paths[2]
-			// no id will be smaller than 2 chars
-			if len(id) <= 1 {
-				// if id not in the path then nothing can be done wtih the users handlers
-				UserHandlers.HandleNotFound(w, r, "invalid path was given to the user route")
-				return
-			} else {
-				if r.Method == "PUT" {
-					fmt.Println("update user hit")
-					UserHandlers.UpdateUser(w, r, id)
-				} else if r.Method == "DELETE" {
-					fmt.Println("delete usser hit")
-					UserHandlers.DeleteUser(w, r, id)
-				} else if r.Method == "GET" {
-					fmt.Println("get user hit")
-					UserHandlers.GetUser(w, r, id)
-				} else {
-					fmt.Println("")
-					UserHandlers.HandleNotFound(w, r, "unsupported method  given to user route")
-				}
-			}
-		default:
-			// need to replce with logging then send a responce to the user
-			AuthHandlers.HandleNotFound(w, r, "unexpected auth endpoint")
-			return
-		}
-	})
+	var mail mailer.Mailer
+	if os.Getenv("MAILER") == "smtp" {
+		mail = mailer.NewSMTPMailer(
+			os.Getenv("SMTP_HOST"),
+			os.Getenv("SMTP_PORT"),
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+			os.Getenv("MAIL_FROM"))
+	} else {
+		mail = mailer.NewFileMailer(mailOutboxPath)
+	}
 
-	// will be the enpoint pertaining to all of the post handlers
-	http.HandleFunc("/posts/", func(w http.ResponseWriter, r *http.Request) {
-		paths := strings.Split(r.URL.Path, "/")
-		fmt.Println(paths)
-		switch len(paths) - 1 {
-		case 3:
-			fmt.Println("like/dislike post hit")
-			if paths[3] == "like" || paths[3] == "dislike" {
-				PostsHandlers.HandleLikeDislike(w, r, paths[2])
-			} else {
-				PostsHandlers.HandleNotFound(w, r, "invalid endpoint for single post")
-			}
-		case 2:
-			id := paths[2]
-			if len(id) <= 1 {
-				if len(paths[2]) > 1 {
-					// if this hits means more than white space was passed
-					PostsHandlers.HandleNotFound(w, r, "invalid url path was given")
-					return
-				}
-				fmt.Println("create post was hit")
-				PostsHandlers.CreatePost(w, r)
-			} else {
-				if r.Method == "PUT" {
-					fmt.Println("update post hit")
-					PostsHandlers.UpdatePost(w, r, id)
-				} else if r.Method == "DELETE" {
-					fmt.Println("delete post hit")
-					PostsHandlers.DeletePost(w, r, id)
-				} else if r.Method == "GET" {
-					fmt.Println("get post hit")
-					PostsHandlers.GetPost(w, r, id)
-				} else {
-					PostsHandlers.HandleNotFound(w, r, "unsupported method  given to post route")
-				}
-			}
-		default:
-			PostsHandlers.HandleNotFound(w, r, "no post endpoint for given url")
-		}
+	// the memory store only works with one instance of the api
+	var attemptStore throttle.Store
+	if os.Getenv("THROTTLE_STORE") == "mongo" {
+		attemptStore = throttle.NewMongoStore(dbClient, model.OperationTimeouts.Write)
+	} else {
+		attemptStore = throttle.NewMemoryStore()
+	}
+
+	mux := newServer(mongoStores(dbClient), serverConfig{
+		mail:        mail,
+		passwords:   passwords,
+		attempts:    attemptStore,
+		corsOrigins: corsOrigins(),
 	})
-	http.ListenAndServe(host+":"+port, nil)
+	http.ListenAndServe(host+":"+port, mux)
 }
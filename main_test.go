@@ -0,0 +1,493 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"social-api/database"
+	"social-api/handlers"
+	"social-api/helpers"
+	"social-api/mailer"
+	"social-api/model"
+	"social-api/throttle"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// the suite runs on the memory models, E2E_MONGO=true runs it against the
+// server in MONGO_URL instead (in a new database that is dropped after)
+func testStores(t *testing.T) stores {
+	if os.Getenv("E2E_MONGO") != "true" {
+		db := model.NewMemoryDatabase()
+		return stores{
+			users:    model.NewMemoryUserModel(db),
+			posts:    model.NewMemoryPostModel(db),
+			comments: model.NewMemoryCommentModel(db),
+			sessions: model.NewMemorySessionModel(db),
+			tokens:   model.NewMemoryUserTokenModel(db),
+			apiKeys:  model.NewMemoryAPIKeyModel(db),
+			audit:    model.NewMemoryAuditModel(db),
+			tx:       db,
+		}
+	}
+	name := fmt.Sprintf("%s_e2e_%s", os.Getenv("DATABASE_NAME"), primitive.NewObjectID().Hex())
+	db := database.ConnectDatabase(os.Getenv("MONGO_URL"), name)
+	t.Cleanup(func() {
+		db.Drop(context.Background())
+		db.Client().Disconnect(context.Background())
+	})
+	// the unique indexes are what turn duplicate usernames into conflicts
+	if _, err := database.SyncIndexes(context.Background(), db); err != nil {
+		t.Fatalf("error when syncing the indexes, :%v", err)
+	}
+	return mongoStores(db)
+}
+
+// keeps the mail so the tests can read the codes out of it
+type outbox struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (o *outbox) Send(msg mailer.Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages = append(o.messages, msg)
+	return nil
+}
+
+// the token in the link of the last mail sent to the address
+func (o *outbox) lastToken(to string) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := len(o.messages) - 1; i >= 0; i-- {
+		if o.messages[i].To == to {
+			_, link, _ := strings.Cut(o.messages[i].Body, "?token=")
+			token, _, _ := strings.Cut(link, "\n")
+			return token
+		}
+	}
+	return ""
+}
+
+// a response body, either the envelope or a error
+type e2eResponse struct {
+	Data    json.RawMessage   `json:"data"`
+	Message string            `json:"message"`
+	Meta    *helpers.Meta     `json:"meta"`
+	Code    helpers.ErrorCode `json:"code"`
+}
+
+// one request in the suite, the path and body can use {name} for values
+// saved by earlier steps
+type e2eStep struct {
+	name   string
+	method string
+	path   string
+	// whose saved token goes in the Authorization header
+	as   string
+	body string
+	// runs before the request, used to save values from the mail
+	before func(s *e2eSuite)
+	status int
+	// the error code that has to come back when the status is a error
+	code helpers.ErrorCode
+	// checks the response and saves values the later steps need
+	after func(s *e2eSuite, res e2eResponse)
+}
+
+type e2eSuite struct {
+	t    *testing.T
+	url  string
+	mail *outbox
+	vars map[string]string
+}
+
+func (s *e2eSuite) expand(text string) string {
+	for name, value := range s.vars {
+		text = strings.ReplaceAll(text, "{"+name+"}", value)
+	}
+	return text
+}
+
+func (s *e2eSuite) decode(data json.RawMessage, val any) {
+	if err := json.Unmarshal(data, val); err != nil {
+		s.t.Fatalf("error when decoding the data %s, :%v", data, err)
+	}
+}
+
+func (s *e2eSuite) run(step e2eStep) {
+	if step.before != nil {
+		step.before(s)
+	}
+	var body *strings.Reader
+	if step.body != "" {
+		body = strings.NewReader(s.expand(step.body))
+	} else {
+		body = strings.NewReader("")
+	}
+	req, err := http.NewRequest(step.method, s.url+s.expand(step.path), body)
+	if err != nil {
+		s.t.Fatalf("error when making the request, :%v", err)
+	}
+	if step.body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if step.as != "" {
+		req.Header.Set("Authorization", "Bearer "+s.vars[step.as+"Token"])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatalf("error when sending the request, :%v", err)
+	}
+	defer resp.Body.Close()
+	var res e2eResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		s.t.Fatalf("error when decoding the response, :%v", err)
+	}
+	if resp.StatusCode != step.status {
+		s.t.Fatalf("wrong status, got=%d, want=%d (code=%s message=%s)", resp.StatusCode, step.status, res.Code, res.Message)
+	}
+	if res.Code != step.code {
+		s.t.Fatalf("wrong error code, got=%s, want=%s", res.Code, step.code)
+	}
+	if step.after != nil {
+		step.after(s, res)
+	}
+}
+
+func newE2ESuite(t *testing.T) *e2eSuite {
+	t.Setenv("JWTSecret", "e2e test secret")
+	t.Setenv("BOOTSTRAP_ADMIN_EMAIL", "admin@example.com")
+	passwords, err := helpers.NewPasswordPolicy(8, bcrypt.MinCost, defaultCommonPasswordsPath)
+	if err != nil {
+		t.Fatalf("error when loading the password policy, :%v", err)
+	}
+	depth := handlers.CommentDepth
+	handlers.CommentDepth = 1
+	t.Cleanup(func() { handlers.CommentDepth = depth })
+	mail := &outbox{}
+	server := httptest.NewServer(newServer(testStores(t), serverConfig{
+		mail:      mail,
+		passwords: passwords,
+		attempts:  throttle.NewMemoryStore(),
+		logDir:    t.TempDir(),
+	}))
+	t.Cleanup(server.Close)
+	return &e2eSuite{
+		t:    t,
+		url:  server.URL,
+		mail: mail,
+		vars: map[string]string{
+			"missing":  primitive.NewObjectID().Hex(),
+			"badToken": "not a token",
+		},
+	}
+}
+
+func saveLogin(name string) func(s *e2eSuite, res e2eResponse) {
+	return func(s *e2eSuite, res e2eResponse) {
+		var tokens struct {
+			Token        string `json:"token"`
+			RefreshToken string `json:"refreshToken"`
+			User         struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		}
+		s.decode(res.Data, &tokens)
+		s.vars[name+"Token"] = tokens.Token
+		s.vars[name+"Refresh"] = tokens.RefreshToken
+		s.vars[name] = tokens.User.ID
+	}
+}
+
+func saveToken(name string, email string) func(s *e2eSuite) {
+	return func(s *e2eSuite) {
+		s.vars[name] = s.mail.lastToken(email)
+	}
+}
+
+func expectChanged(want bool) func(s *e2eSuite, res e2eResponse) {
+	return func(s *e2eSuite, res e2eResponse) {
+		var change struct {
+			Changed bool `json:"changed"`
+		}
+		s.decode(res.Data, &change)
+		if change.Changed != want {
+			s.t.Errorf("wrong changed value, got=%v, want=%v", change.Changed, want)
+		}
+	}
+}
+
+// saves the id of the post or comment that was made
+func saveID(name string) func(s *e2eSuite, res e2eResponse) {
+	return func(s *e2eSuite, res e2eResponse) {
+		var entry struct {
+			ID string `json:"id"`
+		}
+		s.decode(res.Data, &entry)
+		s.vars[name] = entry.ID
+	}
+}
+
+// saves the 2fa code for the secret saved under name
+func saveTOTPCode(name string) func(s *e2eSuite) {
+	return func(s *e2eSuite) {
+		code, err := helpers.TOTPCode(s.vars[name+"Secret"], time.Now())
+		if err != nil {
+			s.t.Fatalf("error when making the 2fa code, :%v", err)
+		}
+		s.vars[name+"Code"] = code
+	}
+}
+
+// checks the ids of the posts or comments in a page and saves the next cursor
+func expectIDs(names ...string) func(s *e2eSuite, res e2eResponse) {
+	return func(s *e2eSuite, res e2eResponse) {
+		var entries []struct {
+			ID string `json:"id"`
+		}
+		s.decode(res.Data, &entries)
+		if len(entries) != len(names) {
+			s.t.Fatalf("wrong number of entries, got=%d, want=%d", len(entries), len(names))
+		}
+		for i, name := range names {
+			if entries[i].ID != s.vars[name] {
+				s.t.Errorf("wrong entry at %d, got=%s, want=%s", i, entries[i].ID, name)
+			}
+		}
+		s.vars["cursor"] = res.Meta.NextCursor
+	}
+}
+
+func expectCommentCount(want int64) func(s *e2eSuite, res e2eResponse) {
+	return func(s *e2eSuite, res e2eResponse) {
+		var post struct {
+			CommentCount int64 `json:"commentCount"`
+		}
+		s.decode(res.Data, &post)
+		if post.CommentCount != want {
+			s.t.Errorf("wrong comment count, got=%d, want=%d", post.CommentCount, want)
+		}
+	}
+}
+
+func TestEndToEnd(t *testing.T) {
+	aliceLogin := `{"username": "alice", "password": "correct horse battery"}`
+	bobLogin := `{"username": "bob", "password": "a long secret phrase"}`
+	postBody := `{"img": "https://example.com/cat.png", "desc": "a cat"}`
+	steps := []e2eStep{
+		// register
+		{name: "register alice", method: "POST", path: "/v1/auth/register", body: `{"username": "alice", "email": "alice@example.com", "password": "correct horse battery"}`, status: 201},
+		{name: "register bob", method: "POST", path: "/v1/auth/register", body: `{"username": "bob", "email": "bob@example.com", "password": "a long secret phrase"}`, status: 201},
+		{name: "register taken username", method: "POST", path: "/v1/auth/register", body: `{"username": "alice", "email": "other@example.com", "password": "correct horse battery"}`, status: 409, code: helpers.CodeConflict},
+		{name: "register bad email", method: "POST", path: "/v1/auth/register", body: `{"username": "carol", "email": "carol", "password": "correct horse battery"}`, status: 400, code: helpers.CodeValidation},
+		{name: "register weak password", method: "POST", path: "/v1/auth/register", body: `{"username": "carol", "email": "carol@example.com", "password": "short"}`, status: 400, code: helpers.CodeWeakPassword},
+		{name: "register broken json", method: "POST", path: "/v1/auth/register", body: `{"username": `, status: 400, code: helpers.CodeInvalidBody},
+
+		// verify and login
+		{name: "verify bad token", method: "POST", path: "/v1/auth/verify-email", body: `{"token": "nope"}`, status: 401, code: helpers.CodeInvalidToken},
+		{name: "verify alice", method: "POST", path: "/v1/auth/verify-email", before: saveToken("aliceVerify", "alice@example.com"), body: `{"token": "{aliceVerify}"}`, status: 200},
+		{name: "verify used token", method: "POST", path: "/v1/auth/verify-email", body: `{"token": "{aliceVerify}"}`, status: 401, code: helpers.CodeInvalidToken},
+		{name: "login wrong password", method: "POST", path: "/v1/auth/login", body: `{"username": "alice", "password": "wrong password"}`, status: 401, code: helpers.CodeInvalidCredentials},
+		{name: "login unknown user", method: "POST", path: "/v1/auth/login", body: `{"username": "nobody", "password": "wrong password"}`, status: 401, code: helpers.CodeInvalidCredentials},
+		{name: "login without password", method: "POST", path: "/v1/auth/login", body: `{"username": "alice"}`, status: 400, code: helpers.CodeBadRequest},
+		{name: "login alice", method: "POST", path: "/v1/auth/login", body: aliceLogin, status: 200, after: saveLogin("alice")},
+		{name: "login bob", method: "POST", path: "/v1/auth/login", body: bobLogin, status: 200, after: saveLogin("bob")},
+		{name: "refresh bad token", method: "POST", path: "/v1/auth/refresh", body: `{"refreshToken": "nope"}`, status: 401, code: helpers.CodeInvalidToken},
+		{name: "refresh alice", method: "POST", path: "/v1/auth/refresh", body: `{"refreshToken": "{aliceRefresh}"}`, status: 200},
+		{name: "refresh reused token", method: "POST", path: "/v1/auth/refresh", body: `{"refreshToken": "{aliceRefresh}"}`, status: 401, code: helpers.CodeInvalidToken},
+
+		// posts
+		{name: "create post without token", method: "POST", path: "/v1/posts", body: postBody, status: 401, code: helpers.CodeUnauthorized},
+		{name: "create post bad token", method: "POST", path: "/v1/posts", as: "bad", body: postBody, status: 401, code: helpers.CodeInvalidToken},
+		{name: "create post unverified", method: "POST", path: "/v1/posts", as: "bob", body: postBody, status: 403, code: helpers.CodeEmailNotVerified},
+		{name: "create post bad image", method: "POST", path: "/v1/posts", as: "alice", body: `{"img": "not a url"}`, status: 400, code: helpers.CodeValidation},
+		{name: "create post", method: "POST", path: "/v1/posts", as: "alice", body: postBody, status: 201, after: saveID("alicePost")},
+		{name: "get post", method: "GET", path: "/v1/posts/{alicePost}", status: 200},
+		{name: "get post bad id", method: "GET", path: "/v1/posts/123", status: 400, code: helpers.CodeInvalidID},
+		{name: "get missing post", method: "GET", path: "/v1/posts/{missing}", status: 404, code: helpers.CodeNotFound},
+		{name: "update someone elses post", method: "PUT", path: "/v1/posts/{alicePost}", as: "bob", body: `{"desc": "mine now"}`, status: 403, code: helpers.CodeForbidden},
+		{name: "update post bad image", method: "PUT", path: "/v1/posts/{alicePost}", as: "alice", body: `{"img": "not a url"}`, status: 400, code: helpers.CodeValidation},
+		{name: "update post", method: "PUT", path: "/v1/posts/{alicePost}", as: "alice", body: `{"desc": "a fat cat"}`, status: 200},
+
+		// likes
+		{name: "like without token", method: "POST", path: "/v1/posts/{alicePost}/like", status: 401, code: helpers.CodeUnauthorized},
+		{name: "like", method: "POST", path: "/v1/posts/{alicePost}/like", as: "bob", status: 200, after: expectChanged(true)},
+		{name: "like again", method: "POST", path: "/v1/posts/{alicePost}/like", as: "bob", status: 200, after: expectChanged(false)},
+		{name: "like missing post", method: "POST", path: "/v1/posts/{missing}/like", as: "bob", status: 404, code: helpers.CodeNotFound},
+		{name: "unlike", method: "POST", path: "/v1/posts/{alicePost}/unlike", as: "bob", status: 200, after: expectChanged(true)},
+		{name: "unlike again", method: "POST", path: "/v1/posts/{alicePost}/unlike", as: "bob", status: 200, after: expectChanged(false)},
+
+		// follows
+		{name: "follow unverified", method: "POST", path: "/v1/users/{alice}/follow", as: "bob", status: 403, code: helpers.CodeEmailNotVerified},
+		{name: "verify bob", method: "POST", path: "/v1/auth/verify-email", before: saveToken("bobVerify", "bob@example.com"), body: `{"token": "{bobVerify}"}`, status: 200},
+		{name: "follow yourself", method: "POST", path: "/v1/users/{bob}/follow", as: "bob", status: 403, code: helpers.CodeForbidden},
+		{name: "follow missing user", method: "POST", path: "/v1/users/{missing}/follow", as: "bob", status: 404, code: helpers.CodeNotFound},
+		{name: "follow bad id", method: "POST", path: "/v1/users/123/follow", as: "bob", status: 400, code: helpers.CodeInvalidID},
+		{name: "follow", method: "POST", path: "/v1/users/{alice}/follow", as: "bob", status: 200, after: expectChanged(true)},
+		{name: "follow again", method: "POST", path: "/v1/users/{alice}/follow", as: "bob", status: 200, after: expectChanged(false)},
+
+		// timeline
+		{name: "create bob post", method: "POST", path: "/v1/posts", as: "bob", body: postBody, status: 201, after: saveID("bobPost")},
+		{name: "timeline without token", method: "GET", path: "/v1/timeline/all", status: 401, code: helpers.CodeUnauthorized},
+		{name: "timeline", method: "GET", path: "/v1/timeline/all", as: "bob", status: 200, after: expectIDs("bobPost", "alicePost")},
+		{name: "timeline first page", method: "GET", path: "/v1/timeline/all?limit=1", as: "bob", status: 200, after: expectIDs("bobPost")},
+		{name: "timeline second page", method: "GET", path: "/v1/timeline/all?limit=1&cursor={cursor}", as: "bob", status: 200, after: expectIDs("alicePost")},
+		{name: "timeline of someone not following", method: "GET", path: "/v1/timeline/all", as: "alice", status: 200, after: expectIDs("alicePost")},
+		{name: "timeline bad limit", method: "GET", path: "/v1/timeline/all?limit=0", as: "bob", status: 400, code: helpers.CodeBadRequest},
+		{name: "timeline bad cursor", method: "GET", path: "/v1/timeline/all?cursor=nope", as: "bob", status: 400, code: helpers.CodeBadRequest},
+
+		// comments, replies can only be one deep in the suite
+		{name: "comment without token", method: "POST", path: "/v1/posts/{alicePost}/comments", body: `{"text": "nice"}`, status: 401, code: helpers.CodeUnauthorized},
+		{name: "comment on missing post", method: "POST", path: "/v1/posts/{missing}/comments", as: "bob", body: `{"text": "nice"}`, status: 404, code: helpers.CodeNotFound},
+		{name: "comment without text", method: "POST", path: "/v1/posts/{alicePost}/comments", as: "bob", body: `{"text": ""}`, status: 400, code: helpers.CodeValidation},
+		{name: "comment", method: "POST", path: "/v1/posts/{alicePost}/comments", as: "bob", body: `{"text": "nice cat"}`, status: 201, after: saveID("bobComment")},
+		{name: "second comment", method: "POST", path: "/v1/posts/{alicePost}/comments", as: "alice", body: `{"text": "thanks all"}`, status: 201, after: saveID("aliceComment")},
+		{name: "reply bad parent", method: "POST", path: "/v1/posts/{alicePost}/comments", as: "alice", body: `{"text": "thanks", "parentId": "123"}`, status: 400, code: helpers.CodeInvalidID},
+		{name: "reply missing parent", method: "POST", path: "/v1/posts/{alicePost}/comments", as: "alice", body: `{"text": "thanks", "parentId": "{missing}"}`, status: 404, code: helpers.CodeNotFound},
+		{name: "reply parent on other post", method: "POST", path: "/v1/posts/{bobPost}/comments", as: "alice", body: `{"text": "thanks", "parentId": "{bobComment}"}`, status: 404, code: helpers.CodeNotFound},
+		{name: "reply", method: "POST", path: "/v1/posts/{alicePost}/comments", as: "alice", body: `{"text": "thanks", "parentId": "{bobComment}"}`, status: 201, after: saveID("aliceReply")},
+		{name: "reply too deep", method: "POST", path: "/v1/posts/{alicePost}/comments", as: "bob", body: `{"text": "np", "parentId": "{aliceReply}"}`, status: 400, code: helpers.CodeBadRequest},
+		{name: "list comments", method: "GET", path: "/v1/posts/{alicePost}/comments", status: 200, after: expectIDs("aliceComment", "bobComment")},
+		{name: "list comments oldest first", method: "GET", path: "/v1/posts/{alicePost}/comments?order=oldest&limit=1", status: 200, after: expectIDs("bobComment")},
+		{name: "list comments next page", method: "GET", path: "/v1/posts/{alicePost}/comments?order=oldest&limit=1&cursor={cursor}", status: 200, after: expectIDs("aliceComment")},
+		{name: "list replies", method: "GET", path: "/v1/posts/{alicePost}/comments?parent={bobComment}", status: 200, after: expectIDs("aliceReply")},
+		{name: "list comments bad order", method: "GET", path: "/v1/posts/{alicePost}/comments?order=sideways", status: 400, code: helpers.CodeBadRequest},
+		{name: "list comments bad parent", method: "GET", path: "/v1/posts/{alicePost}/comments?parent=123", status: 400, code: helpers.CodeInvalidID},
+		{name: "list comments missing post", method: "GET", path: "/v1/posts/{missing}/comments", status: 404, code: helpers.CodeNotFound},
+		{name: "post comment count", method: "GET", path: "/v1/posts/{alicePost}", status: 200, after: expectCommentCount(3)},
+		{name: "edit someone elses comment", method: "PUT", path: "/v1/posts/{alicePost}/comments/{aliceReply}", as: "bob", body: `{"text": "mine now"}`, status: 403, code: helpers.CodeForbidden},
+		{name: "edit comment on other post", method: "PUT", path: "/v1/posts/{bobPost}/comments/{bobComment}", as: "bob", body: `{"text": "moved"}`, status: 404, code: helpers.CodeNotFound},
+		{name: "edit comment", method: "PUT", path: "/v1/posts/{alicePost}/comments/{bobComment}", as: "bob", body: `{"text": "very nice cat"}`, status: 200},
+		{name: "delete comment not yours", method: "DELETE", path: "/v1/posts/{alicePost}/comments/{aliceComment}", as: "bob", status: 403, code: helpers.CodeForbidden},
+		{name: "delete missing comment", method: "DELETE", path: "/v1/posts/{alicePost}/comments/{missing}", as: "alice", status: 404, code: helpers.CodeNotFound},
+		// alice owns the post so she can delete bobs comment, the reply goes with it
+		{name: "post owner deletes comment", method: "DELETE", path: "/v1/posts/{alicePost}/comments/{bobComment}", as: "alice", status: 200},
+		{name: "comments after delete", method: "GET", path: "/v1/posts/{alicePost}/comments?parent={bobComment}", status: 200, after: expectIDs()},
+		{name: "post comment count after delete", method: "GET", path: "/v1/posts/{alicePost}", status: 200, after: expectCommentCount(1)},
+
+		// api keys
+		{name: "create key for someone else", method: "POST", path: "/v1/users/{alice}/api-keys", as: "bob", body: `{"name": "bot", "scopes": ["timeline:read"]}`, status: 403, code: helpers.CodeForbidden},
+		{name: "create key unknown scope", method: "POST", path: "/v1/users/{alice}/api-keys", as: "alice", body: `{"name": "bot", "scopes": ["everything"]}`, status: 400, code: helpers.CodeBadRequest},
+		{name: "create key", method: "POST", path: "/v1/users/{alice}/api-keys", as: "alice", body: `{"name": "bot", "scopes": ["timeline:read"]}`, status: 201, after: func(s *e2eSuite, res e2eResponse) {
+			var key struct {
+				KeyID string `json:"keyId"`
+				Key   string `json:"key"`
+			}
+			s.decode(res.Data, &key)
+			s.vars["aliceKey"] = key.KeyID
+			s.vars["aliceKeyToken"] = key.Key
+		}},
+		{name: "timeline with key", method: "GET", path: "/v1/timeline/all", as: "aliceKey", status: 200, after: expectIDs("alicePost")},
+		{name: "post with key missing scope", method: "POST", path: "/v1/posts", as: "aliceKey", body: postBody, status: 403, code: helpers.CodeMissingScope},
+		{name: "list keys with key", method: "GET", path: "/v1/users/{alice}/api-keys", as: "aliceKey", status: 403, code: helpers.CodeMissingScope},
+		{name: "list keys", method: "GET", path: "/v1/users/{alice}/api-keys", as: "alice", status: 200},
+		{name: "revoke missing key", method: "DELETE", path: "/v1/users/{alice}/api-keys/{missing}", as: "alice", status: 404, code: helpers.CodeNotFound},
+		{name: "revoke key", method: "DELETE", path: "/v1/users/{alice}/api-keys/{aliceKey}", as: "alice", status: 200},
+		{name: "timeline with revoked key", method: "GET", path: "/v1/timeline/all", as: "aliceKey", status: 401, code: helpers.CodeInvalidToken},
+
+		// users, sessions and admin
+		{name: "get user", method: "GET", path: "/v1/users/{alice}", status: 200},
+		{name: "get missing user", method: "GET", path: "/v1/users/{missing}", status: 404, code: helpers.CodeNotFound},
+		{name: "update someone else", method: "PUT", path: "/v1/users/{alice}", as: "bob", body: aliceLogin, status: 403, code: helpers.CodeForbidden},
+		{name: "update wrong password", method: "PUT", path: "/v1/users/{alice}", as: "alice", body: `{"username": "alice", "password": "wrong password"}`, status: 401, code: helpers.CodeInvalidCredentials},
+		{name: "update wrong username", method: "PUT", path: "/v1/users/{alice}", as: "alice", body: bobLogin, status: 400, code: helpers.CodeBadRequest},
+		{name: "update user", method: "PUT", path: "/v1/users/{alice}", as: "alice", body: `{"username": "alice", "password": "correct horse battery", "city": "paris"}`, status: 200},
+		{name: "register carol", method: "POST", path: "/v1/auth/register", body: `{"username": "carol", "email": "carol@example.com", "password": "correct horse battery"}`, status: 201, after: func(s *e2eSuite, res e2eResponse) {
+			s.vars["carolVerify"] = s.mail.lastToken("carol@example.com")
+		}},
+		{name: "login carol", method: "POST", path: "/v1/auth/login", body: `{"username": "carol", "password": "correct horse battery"}`, status: 200, after: saveLogin("carol")},
+		{name: "change carols email", method: "PUT", path: "/v1/users/{carol}", as: "carol", body: `{"username": "carol", "password": "correct horse battery", "email": "carol@example.org"}`, status: 200},
+		{name: "verify token for old email", method: "POST", path: "/v1/auth/verify-email", body: `{"token": "{carolVerify}"}`, status: 401, code: helpers.CodeInvalidToken},
+		{name: "verify new email", method: "POST", path: "/v1/auth/verify-email", before: saveToken("carolNewVerify", "carol@example.org"), body: `{"token": "{carolNewVerify}"}`, status: 200},
+		{name: "create post verified", method: "POST", path: "/v1/posts", as: "carol", body: postBody, status: 201},
+		{name: "change verified email", method: "PUT", path: "/v1/users/{carol}", as: "carol", body: `{"username": "carol", "password": "correct horse battery", "email": "carol@example.net"}`, status: 200},
+		{name: "create post after email change", method: "POST", path: "/v1/posts", as: "carol", body: postBody, status: 403, code: helpers.CodeEmailNotVerified},
+		{name: "2fa enroll", method: "POST", path: "/v1/auth/2fa/enroll", as: "carol", status: 200, after: func(s *e2eSuite, res e2eResponse) {
+			var enroll struct {
+				Secret string `json:"secret"`
+			}
+			s.decode(res.Data, &enroll)
+			s.vars["carolSecret"] = enroll.Secret
+		}},
+		{name: "2fa confirm", method: "POST", path: "/v1/auth/2fa/confirm", as: "carol", before: saveTOTPCode("carol"), body: `{"code": "{carolCode}"}`, status: 200, after: func(s *e2eSuite, res e2eResponse) {
+			var confirm struct {
+				RecoveryCodes []string `json:"recoveryCodes"`
+			}
+			s.decode(res.Data, &confirm)
+			s.vars["carolRecovery"] = confirm.RecoveryCodes[0]
+		}},
+		{name: "2fa login", method: "POST", path: "/v1/auth/login", body: `{"username": "carol", "password": "correct horse battery"}`, status: 200, after: func(s *e2eSuite, res e2eResponse) {
+			var login struct {
+				ChallengeToken string `json:"challengeToken"`
+			}
+			s.decode(res.Data, &login)
+			s.vars["carolChallenge"] = login.ChallengeToken
+		}},
+		{name: "2fa verify reused code", method: "POST", path: "/v1/auth/2fa/verify", body: `{"challengeToken": "{carolChallenge}", "code": "{carolCode}"}`, status: 401, code: helpers.CodeInvalidCode},
+		{name: "2fa verify recovery code", method: "POST", path: "/v1/auth/2fa/verify", body: `{"challengeToken": "{carolChallenge}", "recoveryCode": "{carolRecovery}"}`, status: 200},
+		{name: "2fa verify reused recovery code", method: "POST", path: "/v1/auth/2fa/verify", body: `{"challengeToken": "{carolChallenge}", "recoveryCode": "{carolRecovery}"}`, status: 401, code: helpers.CodeInvalidCode},
+		{name: "sessions", method: "GET", path: "/v1/auth/sessions", as: "alice", status: 200},
+		{name: "revoke missing session", method: "DELETE", path: "/v1/auth/sessions/{missing}", as: "alice", status: 404, code: helpers.CodeNotFound},
+		{name: "grant role without permission", method: "POST", path: "/v1/admin/users/{bob}/roles", as: "alice", body: `{"role": "admin"}`, status: 403, code: helpers.CodeForbidden},
+		{name: "register admin", method: "POST", path: "/v1/auth/register", body: `{"username": "root", "email": "admin@example.com", "password": "correct horse battery"}`, status: 201},
+		{name: "verify admin", method: "POST", path: "/v1/auth/verify-email", before: saveToken("adminVerify", "admin@example.com"), body: `{"token": "{adminVerify}"}`, status: 200},
+		{name: "login admin", method: "POST", path: "/v1/auth/login", body: `{"username": "root", "password": "correct horse battery"}`, status: 200, after: saveLogin("admin")},
+		{name: "admin updates user without their password", method: "PUT", path: "/v1/users/{carol}", as: "admin", body: `{"desc": "changed by an admin"}`, status: 200},
+		{name: "admin deletes user without their password", method: "DELETE", path: "/v1/users/{carol}", as: "admin", status: 200},
+		{name: "get user deleted by admin", method: "GET", path: "/v1/users/{carol}", status: 404, code: helpers.CodeNotFound},
+		{name: "2fa verify bad challenge", method: "POST", path: "/v1/auth/2fa/verify", body: `{"challengeToken": "nope", "code": "123456"}`, status: 401, code: helpers.CodeInvalidToken},
+		{name: "2fa disable when not enabled", method: "POST", path: "/v1/auth/2fa/disable", as: "alice", body: `{"password": "correct horse battery"}`, status: 400, code: helpers.CodeBadRequest},
+
+		// routing
+		{name: "unknown route", method: "GET", path: "/v1/nothing", status: 404, code: helpers.CodeNotFound},
+		{name: "wrong method", method: "PATCH", path: "/v1/posts/{alicePost}", status: 405, code: helpers.CodeMethodNotAllowed},
+
+		// password reset
+		{name: "forgot password", method: "POST", path: "/v1/auth/forgot-password", body: `{"email": "alice@example.com"}`, status: 200},
+		{name: "reset bad token", method: "POST", path: "/v1/auth/reset-password", body: `{"token": "nope", "password": "a brand new password"}`, status: 401, code: helpers.CodeInvalidToken},
+		{name: "reset password", method: "POST", path: "/v1/auth/reset-password", before: saveToken("aliceReset", "alice@example.com"), body: `{"token": "{aliceReset}", "password": "a brand new password"}`, status: 200},
+		{name: "old session after reset", method: "GET", path: "/v1/auth/sessions", as: "alice", status: 401, code: helpers.CodeInvalidToken},
+		{name: "login old password", method: "POST", path: "/v1/auth/login", body: aliceLogin, status: 401, code: helpers.CodeInvalidCredentials},
+		{name: "login new password", method: "POST", path: "/v1/auth/login", body: `{"username": "alice", "password": "a brand new password"}`, status: 200, after: saveLogin("alice")},
+
+		// delete
+		{name: "delete someone elses post", method: "DELETE", path: "/v1/posts/{alicePost}", as: "bob", status: 403, code: helpers.CodeForbidden},
+		{name: "delete post", method: "DELETE", path: "/v1/posts/{alicePost}", as: "alice", status: 200},
+		{name: "get deleted post", method: "GET", path: "/v1/posts/{alicePost}", status: 404, code: helpers.CodeNotFound},
+		{name: "delete user wrong password", method: "DELETE", path: "/v1/users/{bob}", as: "bob", body: `{"username": "bob", "password": "wrong password"}`, status: 401, code: helpers.CodeInvalidCredentials},
+		{name: "delete user", method: "DELETE", path: "/v1/users/{bob}", as: "bob", body: bobLogin, status: 200},
+		{name: "get deleted user", method: "GET", path: "/v1/users/{bob}", status: 404, code: helpers.CodeNotFound},
+		{name: "deleted user is not a follower", method: "GET", path: "/v1/users/{alice}", status: 200, after: func(s *e2eSuite, res e2eResponse) {
+			var user struct {
+				Followers []string `json:"followers"`
+			}
+			s.decode(res.Data, &user)
+			if len(user.Followers) != 0 {
+				s.t.Errorf("deleted user is still a follower, got=%v", user.Followers)
+			}
+		}},
+		{name: "deleted user token", method: "GET", path: "/v1/timeline/all", as: "bob", status: 401, code: helpers.CodeInvalidToken},
+		{name: "logout", method: "POST", path: "/v1/auth/logout", as: "alice", status: 200},
+		{name: "token after logout", method: "GET", path: "/v1/timeline/all", as: "alice", status: 401, code: helpers.CodeInvalidToken},
+	}
+	s := newE2ESuite(t)
+	for _, step := range steps {
+		// each step needs the ones before it so the first failure stops the suite
+		if !t.Run(step.name, func(t *testing.T) {
+			s.t = t
+			s.run(step)
+		}) {
+			break
+		}
+	}
+}
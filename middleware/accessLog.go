@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"social-api/logger"
+	"strconv"
+	"time"
+)
+
+// writes one line for every request in key=value form so it can be grepped
+// or loaded into a log tool
+func AccessLog(log logger.Logger) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := newStatusWriter(w)
+			defer func() {
+				ip, _, err := net.SplitHostPort(r.RemoteAddr)
+				if err != nil {
+					ip = r.RemoteAddr
+				}
+				log.WriteToLogger(logger.INFO, fmt.Sprintf(
+					"method=%s path=%s status=%d bytes=%d latency=%s ip=%s requestId=%s userAgent=%s",
+					r.Method,
+					strconv.Quote(r.URL.Path),
+					sw.status,
+					sw.bytes,
+					time.Since(start),
+					ip,
+					GetRequestID(r),
+					strconv.Quote(r.UserAgent()),
+				))
+			}()
+			next(sw, r)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CORSConfig struct {
+	// "*" allows any origin (CORS panics if it is used with AllowCredentials)
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// how long the browser can cache the preflight response
+	MaxAge time.Duration
+}
+
+// config for our web client, only the origins need to be set
+func DefaultCORSConfig(origins []string) CORSConfig {
+	return CORSConfig{
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", RequestIDHeader},
+		ExposedHeaders:   []string{RequestIDHeader, "Retry-After"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+}
+
+// adds the cors headers for allowed origins and answers preflight requests,
+// needs to run before the router so OPTIONS requests never reach a route.
+// panics if "*" is used with AllowCredentials, that would let any site
+// make requests with the users cookies
+func CORS(config CORSConfig) func(http.HandlerFunc) http.HandlerFunc {
+	allowAny := false
+	allowed := make(map[string]bool)
+	for _, origin := range config.AllowedOrigins {
+		if origin == "*" {
+			if config.AllowCredentials {
+				panic("cors: the * origin cant be used with AllowCredentials, list the origins instead")
+			}
+			allowAny = true
+		}
+		allowed[strings.ToLower(origin)] = true
+	}
+	methods := strings.Join(config.AllowedMethods, ", ")
+	headers := strings.Join(config.AllowedHeaders, ", ")
+	exposed := strings.Join(config.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge.Seconds()))
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next(w, r)
+				return
+			}
+			w.Header().Add("Vary", "Origin")
+			if !allowAny && !allowed[strings.ToLower(origin)] {
+				// not one of our clients, dont add any headers so the browser blocks it
+				next(w, r)
+				return
+			}
+			if allowAny {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if config.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if !preflight {
+				if exposed != "" {
+					w.Header().Set("Access-Control-Expose-Headers", exposed)
+				}
+				next(w, r)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+			w.Header().Set("Access-Control-Max-Age", maxAge)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"social-api/logger"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	testtable := []struct {
+		header   string
+		expected string
+	}{
+		{header: "abc-123", expected: "abc-123"},
+		{header: "", expected: ""},
+		{header: "bad id with spaces", expected: ""},
+		{header: strings.Repeat("a", 65), expected: ""},
+	}
+	for _, tt := range testtable {
+		var seen string
+		handler := RequestID(func(w http.ResponseWriter, r *http.Request) {
+			seen = GetRequestID(r)
+		})
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.header != "" {
+			req.Header.Set(RequestIDHeader, tt.header)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if seen == "" || rec.Header().Get(RequestIDHeader) != seen {
+			t.Errorf("request id not on context and response, context=%s, header=%s", seen, rec.Header().Get(RequestIDHeader))
+		}
+		if tt.expected != "" && seen != tt.expected {
+			t.Errorf("wrong request id, got=%s, want=%s", seen, tt.expected)
+		}
+		if tt.expected == "" && seen == tt.header {
+			t.Errorf("invalid request id should have been replaced, got=%s", seen)
+		}
+	}
+}
+
+func TestRecover(t *testing.T) {
+	handler := RequestID(Recover(logger.NewLogger())(func(w http.ResponseWriter, r *http.Request) {
+		var user *struct{ Name string }
+		w.Write([]byte(user.Name))
+	}))
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("wrong status after panic, got=%d, want=%d", rec.Code, http.StatusInternalServerError)
+	}
+	var body struct {
+		Code      string `json:"code"`
+		RequestID string `json:"requestId"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("body after panic is not json, :%v", err)
+	}
+	if body.Code != "internal_error" || body.RequestID != rec.Header().Get(RequestIDHeader) {
+		t.Errorf("wrong body after panic, got=%+v", body)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	handler := CORS(DefaultCORSConfig([]string{"https://app.example.com"}))(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	testtable := []struct {
+		method       string
+		origin       string
+		expectedCode int
+		expectAllow  string
+	}{
+		{method: "OPTIONS", origin: "https://app.example.com", expectedCode: http.StatusNoContent, expectAllow: "https://app.example.com"},
+		{method: "GET", origin: "https://app.example.com", expectedCode: http.StatusTeapot, expectAllow: "https://app.example.com"},
+		{method: "GET", origin: "https://evil.example.com", expectedCode: http.StatusTeapot, expectAllow: ""},
+		{method: "GET", origin: "", expectedCode: http.StatusTeapot, expectAllow: ""},
+	}
+	for _, tt := range testtable {
+		req := httptest.NewRequest(tt.method, "/posts", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.method == "OPTIONS" {
+			req.Header.Set("Access-Control-Request-Method", "POST")
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != tt.expectedCode {
+			t.Errorf("wrong status for %s from %s, got=%d, want=%d", tt.method, tt.origin, rec.Code, tt.expectedCode)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.expectAllow {
+			t.Errorf("wrong allow origin for %s from %s, got=%s, want=%s", tt.method, tt.origin, got, tt.expectAllow)
+		}
+	}
+}
+
+func TestCORSWildcard(t *testing.T) {
+	config := DefaultCORSConfig([]string{"*"})
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("* with credentials should panic")
+			}
+		}()
+		CORS(config)
+	}()
+	config.AllowCredentials = false
+	handler := CORS(config)(func(w http.ResponseWriter, r *http.Request) {})
+	req := httptest.NewRequest("GET", "/posts", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("wrong allow origin, got=%s, want=*", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("credentials should not be allowed, got=%s", got)
+	}
+}
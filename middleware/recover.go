@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"social-api/logger"
+)
+
+// catches a panic in any handler after it, logs it with the stack and sends
+// a json 500 instead of dropping the connection
+func Recover(log logger.Logger) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			sw := newStatusWriter(w)
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				// let the server deal with the connection like it normally would
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				requestId := GetRequestID(r)
+				log.WriteToLogger(logger.ERROR, fmt.Sprintf("panic in %s %s requestId=%s: %v\n%s", r.Method, r.URL.Path, requestId, rec, debug.Stack()))
+				if sw.wroteHeader {
+					// too late to send a error, the status is already gone
+					return
+				}
+				sw.Header().Set("Content-Type", "application/json")
+				sw.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(sw).Encode(struct {
+					Code      string `json:"code"`
+					Message   string `json:"message"`
+					RequestID string `json:"requestId,omitempty"`
+				}{
+					Code:      "internal_error",
+					Message:   "internal server error",
+					RequestID: requestId,
+				})
+			}()
+			next(sw, r)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+type contextKey string
+
+const requestIdContextKey contextKey = "requestId"
+
+// header the request id is read from and sent back in
+const RequestIDHeader string = "X-Request-ID"
+
+// ids from the client are only kept if they look sane
+const maxRequestIDLength int = 64
+
+// gives every request a id, if the client (or a proxy) sent one it is
+// kept so the same id can be followed through every service
+func RequestID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIdContextKey, id)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// gets the id that RequestID put on the request (empty if there is none)
+func GetRequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIdContextKey).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		isAlphaNum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlphaNum && c != '-' && c != '_' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
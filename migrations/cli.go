@@ -0,0 +1,59 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const usage = "usage: migrate [--dry-run] status | up | down N"
+
+// runs the migrate subcommand, args are what comes after "migrate"
+func (mr *Runner) RunCLI(ctx context.Context, args []string) error {
+	var command []string
+	for _, arg := range args {
+		if arg == "--dry-run" || arg == "-dry-run" {
+			mr.DryRun = true
+			continue
+		}
+		command = append(command, arg)
+	}
+	if len(command) == 0 {
+		return errors.New(usage)
+	}
+	switch command[0] {
+	case "status":
+		if len(command) != 1 {
+			return errors.New(usage)
+		}
+		states, err := mr.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = "applied " + state.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(mr.Out, "%4d  %-40s %s\n", state.Migration.Version, state.Migration.Name, applied)
+		}
+		return nil
+	case "up":
+		if len(command) != 1 {
+			return errors.New(usage)
+		}
+		return mr.Up(ctx)
+	case "down":
+		if len(command) != 2 {
+			return errors.New(usage)
+		}
+		n, err := strconv.Atoi(command[1])
+		if err != nil {
+			return errors.New(usage)
+		}
+		return mr.Down(ctx, n)
+	}
+	return errors.New(usage)
+}
@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// accounts made before email verification existed never got the link, they
+// are counted as verified so they dont lose access to posting and following.
+// there is no down, it cant tell these users apart from ones who verified
+func init() {
+	Register(Migration{
+		Version: 3,
+		Name:    "mark existing accounts as verified",
+		Up: Steps(
+			Step{
+				Collection: "users",
+				Filter:     bson.D{primitive.E{Key: "emailVerified", Value: bson.D{primitive.E{Key: "$exists", Value: false}}}},
+				Update:     bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "emailVerified", Value: true}}}},
+			},
+		),
+	})
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fixes the spelling of the follow fields of users
+func init() {
+	Register(Migration{
+		Version: 4,
+		Name:    "rename follwers and follwings",
+		Up:      Steps(renameFields("users", "follwers", "followers", "follwings", "followings")),
+		Down:    Steps(renameFields("users", "followers", "follwers", "followings", "follwings")),
+	})
+}
+
+// pairs are old name then new name, only documents that still have one of
+// the old names are matched
+func renameFields(collection string, pairs ...string) Step {
+	var exists bson.A
+	var rename bson.D
+	for i := 0; i < len(pairs); i += 2 {
+		exists = append(exists, bson.D{primitive.E{Key: pairs[i], Value: bson.D{primitive.E{Key: "$exists", Value: true}}}})
+		rename = append(rename, primitive.E{Key: pairs[i], Value: pairs[i+1]})
+	}
+	return Step{
+		Collection: collection,
+		Filter:     bson.D{primitive.E{Key: "$or", Value: exists}},
+		Update:     bson.D{primitive.E{Key: "$rename", Value: rename}},
+	}
+}
@@ -0,0 +1,427 @@
+// Package migrations changes the data in the database to match the types
+// when they change. every migration has a version, the versions that have
+// been applied are saved in the _migrations collection so each one only
+// runs once per database.
+//
+// a migration is a func that gets the database. most of them are a list of
+// steps made with Steps, each step is one UpdateMany whose filter only
+// matches the documents that still need changing. this keeps the steps safe
+// to run again if a migration fails part way and lets a dry run count the
+// documents a step would change.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const migrationCollectionName string = "_migrations"
+
+// the lock is a document in the migration collection with this id, the
+// applied versions are the documents with a number id
+const lockID string = "lock"
+
+// a lock older than this is from a instance that died mid run, the runner
+// pushes the expiry forward while it works so long migrations keep the lock
+var LockTimeout = 30 * time.Minute
+
+var ErrLocked = errors.New("migrations are being run by another instance")
+
+var ErrLockLost = errors.New("migration lock was lost while running")
+
+// Func changes the data for one direction of a migration. in a dry run (see
+// DryRun) it must not change anything, only say what it would do
+type Func func(ctx context.Context, db *mongo.Database) error
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      Func
+	// nil when the migration cant be undone
+	Down Func
+}
+
+// Step is one UpdateMany, update is a update document or a mongo.Pipeline
+type Step struct {
+	Collection string
+	Filter     bson.D
+	Update     any
+}
+
+// makes a Func that runs the steps in order, a dry run counts the documents
+// each step would change. panics on a step without a collection, filter or
+// update, a empty filter would change every document on every run
+func Steps(steps ...Step) Func {
+	for _, step := range steps {
+		if step.Collection == "" || len(step.Filter) == 0 || step.Update == nil {
+			panic("migrations: step needs a collection, filter and update")
+		}
+	}
+	return func(ctx context.Context, db *mongo.Database) error {
+		for i, step := range steps {
+			c := db.Collection(step.Collection)
+			if DryRun(ctx) {
+				count, err := c.CountDocuments(ctx, step.Filter)
+				if err != nil {
+					return fmt.Errorf("step %d: %w", i+1, err)
+				}
+				Printf(ctx, "  step %d would change %d documents in %s\n", i+1, count, step.Collection)
+				continue
+			}
+			result, err := c.UpdateMany(ctx, step.Filter, step.Update)
+			if err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+			Printf(ctx, "  step %d changed %d documents in %s\n", i+1, result.ModifiedCount, step.Collection)
+		}
+		return nil
+	}
+}
+
+// a Down for migrations whose changes cant be undone but shouldnt stop the
+// ones before them from being rolled back
+func Noop(ctx context.Context, db *mongo.Database) error {
+	return nil
+}
+
+type contextKey string
+
+const runContextKey contextKey = "run"
+
+// what the runner passes down to the migration funcs
+type runInfo struct {
+	dryRun bool
+	out    io.Writer
+}
+
+func withRun(ctx context.Context, info runInfo) context.Context {
+	return context.WithValue(ctx, runContextKey, info)
+}
+
+// true if the migration is being run with --dry-run
+func DryRun(ctx context.Context) bool {
+	info, _ := ctx.Value(runContextKey).(runInfo)
+	return info.dryRun
+}
+
+// writes the progress of a migration to the output of the runner
+func Printf(ctx context.Context, format string, args ...any) {
+	info, ok := ctx.Value(runContextKey).(runInfo)
+	if !ok || info.out == nil {
+		return
+	}
+	fmt.Fprintf(info.out, format, args...)
+}
+
+var registry []Migration
+
+// adds a migration, each migration file calls this from init
+func Register(m Migration) {
+	for _, existing := range registry {
+		if existing.Version == m.Version {
+			panic(fmt.Sprintf("migrations: version %d used by %q and %q", m.Version, existing.Name, m.Name))
+		}
+	}
+	registry = append(registry, m)
+	sort.Slice(registry, func(i, j int) bool { return registry[i].Version < registry[j].Version })
+}
+
+// State is a migration and when it was applied (nil if it hasnt been)
+type State struct {
+	Migration Migration
+	AppliedAt *time.Time
+}
+
+// a applied version as it is saved in the migration collection
+type record struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+type lock struct {
+	ID        string    `bson:"_id"`
+	Owner     string    `bson:"owner"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// Runner applies and rolls back the registered migrations
+type Runner struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+	migrations []Migration
+	// a dry run only counts the documents each step would change
+	DryRun bool
+	// where the progress is written
+	Out io.Writer
+}
+
+func NewRunner(db *mongo.Database) *Runner {
+	return &Runner{
+		db:         db,
+		collection: db.Collection(migrationCollectionName),
+		migrations: registry,
+		Out:        os.Stdout,
+	}
+}
+
+// every registered migration in version order with when it was applied
+func (mr *Runner) Status(ctx context.Context) ([]State, error) {
+	applied, err := mr.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]State, 0, len(mr.migrations))
+	for _, m := range mr.migrations {
+		state := State{Migration: m}
+		if r, ok := applied[m.Version]; ok {
+			appliedAt := r.AppliedAt
+			state.AppliedAt = &appliedAt
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// the migrations that havent been applied yet, in the order they will run
+func (mr *Runner) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := mr.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return pending(mr.migrations, applied), nil
+}
+
+// applies every pending migration in version order, stops at the first
+// one that fails
+func (mr *Runner) Up(ctx context.Context) error {
+	return mr.locked(ctx, func(ctx context.Context) error {
+		applied, err := mr.applied(ctx)
+		if err != nil {
+			return err
+		}
+		todo := pending(mr.migrations, applied)
+		if len(todo) == 0 {
+			fmt.Fprintln(mr.Out, "no pending migrations")
+		}
+		for _, m := range todo {
+			if err := mr.run(ctx, m, m.Up, "up"); err != nil {
+				return err
+			}
+			if mr.DryRun {
+				continue
+			}
+			r := record{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}
+			if _, err := mr.collection.InsertOne(ctx, r); err != nil {
+				return fmt.Errorf("saving migration %d: %w", m.Version, err)
+			}
+		}
+		return nil
+	})
+}
+
+// rolls back the last n applied migrations, newest first
+func (mr *Runner) Down(ctx context.Context, n int) error {
+	return mr.locked(ctx, func(ctx context.Context) error {
+		applied, err := mr.applied(ctx)
+		if err != nil {
+			return err
+		}
+		todo, err := rollbacks(mr.migrations, applied, n)
+		if err != nil {
+			return err
+		}
+		for _, m := range todo {
+			if err := mr.run(ctx, m, m.Down, "down"); err != nil {
+				return err
+			}
+			if mr.DryRun {
+				continue
+			}
+			if _, err := mr.collection.DeleteOne(ctx, bson.D{primitive.E{Key: "_id", Value: m.Version}}); err != nil {
+				return fmt.Errorf("removing migration %d: %w", m.Version, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (mr *Runner) run(ctx context.Context, m Migration, fn Func, direction string) error {
+	prefix := "applying"
+	if mr.DryRun {
+		prefix = "dry run"
+	}
+	fmt.Fprintf(mr.Out, "%s %s %d %s\n", prefix, direction, m.Version, m.Name)
+	ctx = withRun(ctx, runInfo{dryRun: mr.DryRun, out: mr.Out})
+	if err := fn(ctx, mr.db); err != nil {
+		return fmt.Errorf("migration %d %s: %w", m.Version, direction, err)
+	}
+	return nil
+}
+
+func (mr *Runner) applied(ctx context.Context) (map[int]record, error) {
+	filter := bson.D{primitive.E{Key: "_id", Value: bson.D{primitive.E{Key: "$type", Value: "number"}}}}
+	cur, err := mr.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var records []record
+	if err := cur.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]record, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// runs fn while holding the migration lock, a dry run doesnt change
+// anything so it doesnt need the lock. the lock is refreshed while fn runs,
+// if it cant be the ctx given to fn is canceled so fn stops before another
+// instance can take the lock over
+func (mr *Runner) locked(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mr.DryRun {
+		return fn(ctx)
+	}
+	owner, err := lockOwner()
+	if err != nil {
+		return err
+	}
+	if err := mr.lock(ctx, owner); err != nil {
+		return err
+	}
+	defer mr.collection.DeleteOne(context.Background(), bson.D{
+		primitive.E{Key: "_id", Value: lockID},
+		primitive.E{Key: "owner", Value: owner},
+	})
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	lost := make(chan error, 1)
+	go func() {
+		lost <- mr.refreshLock(ctx, owner)
+		cancel()
+	}()
+	err = fn(ctx)
+	cancel()
+	if refreshErr := <-lost; refreshErr != nil {
+		return fmt.Errorf("%w: %v", ErrLockLost, refreshErr)
+	}
+	return err
+}
+
+// pushes the expiry of the lock forward every third of LockTimeout until ctx
+// is done, returns a error if the lock couldnt be kept
+func (mr *Runner) refreshLock(ctx context.Context, owner string) error {
+	if LockTimeout < 3 {
+		return nil
+	}
+	ticker := time.NewTicker(LockTimeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		filter := bson.D{
+			primitive.E{Key: "_id", Value: lockID},
+			primitive.E{Key: "owner", Value: owner},
+		}
+		update := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "expires_at", Value: time.Now().Add(LockTimeout)}}}}
+		result, err := mr.collection.UpdateOne(ctx, filter, update)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errors.New("another instance took the lock")
+		}
+	}
+}
+
+func (mr *Runner) lock(ctx context.Context, owner string) error {
+	now := time.Now()
+	l := lock{ID: lockID, Owner: owner, ExpiresAt: now.Add(LockTimeout)}
+	_, err := mr.collection.InsertOne(ctx, l)
+	if err == nil {
+		return nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	// someone has the lock, take it over only if it has expired
+	expired := bson.D{
+		primitive.E{Key: "_id", Value: lockID},
+		primitive.E{Key: "expires_at", Value: bson.D{primitive.E{Key: "$lt", Value: now}}},
+	}
+	result, err := mr.collection.ReplaceOne(ctx, expired, l)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrLocked
+	}
+	return nil
+}
+
+func lockOwner() (string, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), primitive.NewObjectID().Hex()), nil
+}
+
+func pending(migrations []Migration, applied map[int]record) []Migration {
+	var todo []Migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; !ok {
+			todo = append(todo, m)
+		}
+	}
+	return todo
+}
+
+// the last n applied migrations newest first, errors if one of them is
+// unknown to this build or cant be undone so nothing is half rolled back
+func rollbacks(migrations []Migration, applied map[int]record, n int) ([]Migration, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("number of migrations to roll back has to be at least 1, got %d", n)
+	}
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	if n > len(versions) {
+		n = len(versions)
+	}
+	byVersion := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+	todo := make([]Migration, 0, n)
+	for _, version := range versions[:n] {
+		m, ok := byVersion[version]
+		if !ok {
+			return nil, fmt.Errorf("migration %d (%s) is applied but not known to this build", version, applied[version].Name)
+		}
+		if m.Down == nil {
+			return nil, fmt.Errorf("migration %d (%s) cannot be rolled back", m.Version, m.Name)
+		}
+		todo = append(todo, m)
+	}
+	return todo, nil
+}
@@ -0,0 +1,108 @@
+package migrations
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRegistry(t *testing.T) {
+	for i, m := range registry {
+		if m.Version != i+1 {
+			t.Errorf("versions should count up from 1 with no gaps, got=%d, want=%d", m.Version, i+1)
+		}
+		if m.Name == "" || m.Up == nil {
+			t.Errorf("migration %d needs a name and a up", m.Version)
+		}
+	}
+}
+
+func TestStepsEmptyFilter(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("a step without a filter should panic")
+		}
+	}()
+	Steps(Step{Collection: "users", Update: bson.D{}})
+}
+
+func TestRunContext(t *testing.T) {
+	var out strings.Builder
+	ctx := withRun(context.Background(), runInfo{dryRun: true, out: &out})
+	if !DryRun(ctx) {
+		t.Errorf("dry run should be passed down to the migration")
+	}
+	Printf(ctx, "step %d", 1)
+	if out.String() != "step 1" {
+		t.Errorf("wrong output, got=%s, want=%s", out.String(), "step 1")
+	}
+	// migrations can be called outside of the runner
+	if DryRun(context.Background()) {
+		t.Errorf("dry run should be false without the runner")
+	}
+	Printf(context.Background(), "nothing")
+}
+
+func TestPending(t *testing.T) {
+	all := []Migration{{Version: 1}, {Version: 2}, {Version: 3}}
+	testtable := []struct {
+		applied  map[int]record
+		expected []int
+	}{
+		{applied: map[int]record{}, expected: []int{1, 2, 3}},
+		{applied: map[int]record{1: {Version: 1}, 2: {Version: 2}}, expected: []int{3}},
+		// a gap is filled in when a older migration is added later
+		{applied: map[int]record{1: {Version: 1}, 3: {Version: 3}}, expected: []int{2}},
+		{applied: map[int]record{1: {Version: 1}, 2: {Version: 2}, 3: {Version: 3}}, expected: nil},
+	}
+	for _, tt := range testtable {
+		got := pending(all, tt.applied)
+		if len(got) != len(tt.expected) {
+			t.Fatalf("wrong number of pending migrations, got=%d, want=%d", len(got), len(tt.expected))
+		}
+		for i, m := range got {
+			if m.Version != tt.expected[i] {
+				t.Errorf("wrong pending migration, got=%d, want=%d", m.Version, tt.expected[i])
+			}
+		}
+	}
+}
+
+func TestRollbacks(t *testing.T) {
+	down := Noop
+	all := []Migration{{Version: 1, Down: down}, {Version: 2}, {Version: 3, Down: down}, {Version: 4, Down: down}}
+	testtable := []struct {
+		applied  map[int]record
+		n        int
+		expected []int
+		err      string
+	}{
+		{applied: map[int]record{3: {}, 4: {}}, n: 1, expected: []int{4}},
+		{applied: map[int]record{3: {}, 4: {}}, n: 5, expected: []int{4, 3}},
+		{applied: map[int]record{1: {}, 2: {}, 3: {}}, n: 2, err: "cannot be rolled back"},
+		{applied: map[int]record{4: {}, 9: {Name: "from the future"}}, n: 1, err: "not known to this build"},
+		{applied: map[int]record{4: {}}, n: 0, err: "at least 1"},
+	}
+	for _, tt := range testtable {
+		got, err := rollbacks(all, tt.applied, tt.n)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("wrong error, got=%v, want=%s", err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error, got=%v", err)
+		}
+		if len(got) != len(tt.expected) {
+			t.Fatalf("wrong number of migrations, got=%d, want=%d", len(got), len(tt.expected))
+		}
+		for i, m := range got {
+			if m.Version != tt.expected[i] {
+				t.Errorf("wrong migration order, got=%d, want=%d", m.Version, tt.expected[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package migrations
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// a field in a collection that holds the id of another document
+type reference struct {
+	collection string
+	field      string
+	array      bool
+}
+
+// references that used to be stored as hex strings
+var objectIDReferences = []reference{
+	{collection: "posts", field: "userId"},
+	{collection: "posts", field: "likes", array: true},
+	{collection: "users", field: "follwers", array: true},
+	{collection: "users", field: "follwings", array: true},
+}
+
+// the same references after migration 4 fixed the spelling of the follow fields
+var renamedReferences = []reference{
+	{collection: "posts", field: "userId"},
+	{collection: "posts", field: "likes", array: true},
+	{collection: "users", field: "followers", array: true},
+	{collection: "users", field: "followings", array: true},
+}
+
+func init() {
+	Register(Migration{
+		Version: 1,
+		Name:    "store references as ObjectIDs",
+		Up:      Steps(convertReferences(objectIDReferences, "string", "objectId")...),
+		Down:    Steps(convertReferences(objectIDReferences, "objectId", "string")...),
+	})
+	// the first version of migration 1 left strings that arent hex ids in
+	// place, this drops them from databases it already ran on (new databases
+	// get nothing to do here). the dropped ids cant be brought back so down
+	// does nothing, that way it doesnt stop rolling back past it
+	Register(Migration{
+		Version: 5,
+		Name:    "drop references that arent ObjectIDs",
+		Up:      Steps(convertReferences(renamedReferences, "string", "objectId")...),
+		Down:    Noop,
+	})
+}
+
+// turns the value into the type inside a update pipeline, values that cant
+// be converted (like a string that isnt a hex id) become null so they can
+// be dropped, they cant point at any document anyway
+func convert(value string, to string) bson.D {
+	return bson.D{primitive.E{Key: "$convert", Value: bson.D{
+		primitive.E{Key: "input", Value: value},
+		primitive.E{Key: "to", Value: to},
+		primitive.E{Key: "onError", Value: nil},
+		primitive.E{Key: "onNull", Value: nil},
+	}}}
+}
+
+// a step for every reference, only documents that still have the from
+// type are matched
+func convertReferences(refs []reference, from string, to string) []Step {
+	steps := make([]Step, 0, len(refs))
+	for _, ref := range refs {
+		var filter bson.D
+		var set bson.D
+		if ref.array {
+			filter = bson.D{primitive.E{Key: ref.field, Value: bson.D{primitive.E{Key: "$elemMatch", Value: bson.D{primitive.E{Key: "$type", Value: from}}}}}}
+			converted := bson.D{primitive.E{Key: "$map", Value: bson.D{
+				primitive.E{Key: "input", Value: "$" + ref.field},
+				primitive.E{Key: "as", Value: "id"},
+				primitive.E{Key: "in", Value: convert("$$id", to)},
+			}}}
+			// the ids that couldnt be converted are pulled out of the array
+			set = bson.D{primitive.E{Key: ref.field, Value: bson.D{primitive.E{Key: "$filter", Value: bson.D{
+				primitive.E{Key: "input", Value: converted},
+				primitive.E{Key: "as", Value: "id"},
+				primitive.E{Key: "cond", Value: bson.D{primitive.E{Key: "$ne", Value: bson.A{"$$id", nil}}}},
+			}}}}}
+		} else {
+			filter = bson.D{primitive.E{Key: ref.field, Value: bson.D{primitive.E{Key: "$type", Value: from}}}}
+			// a id that couldnt be converted is unset
+			set = bson.D{primitive.E{Key: ref.field, Value: bson.D{primitive.E{Key: "$ifNull", Value: bson.A{
+				convert("$"+ref.field, to),
+				"$$REMOVE",
+			}}}}}
+		}
+		steps = append(steps, Step{
+			Collection: ref.collection,
+			Filter:     filter,
+			Update:     mongo.Pipeline{bson.D{primitive.E{Key: "$set", Value: set}}},
+		})
+	}
+	return steps
+}
@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"social-api/types"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// users made before roles existed have a isAdmin flag instead
+func init() {
+	unsetIsAdmin := primitive.E{Key: "$unset", Value: bson.D{primitive.E{Key: "isAdmin", Value: ""}}}
+	Register(Migration{
+		Version: 2,
+		Name:    "replace isAdmin with roles",
+		Up: Steps(
+			Step{
+				Collection: "users",
+				Filter:     bson.D{primitive.E{Key: "isAdmin", Value: true}},
+				Update: bson.D{
+					primitive.E{Key: "$addToSet", Value: bson.D{primitive.E{Key: "roles", Value: bson.D{primitive.E{Key: "$each", Value: []types.Role{types.RoleUser, types.RoleAdmin}}}}}},
+					unsetIsAdmin,
+				},
+			},
+			Step{
+				Collection: "users",
+				Filter:     bson.D{primitive.E{Key: "roles", Value: bson.D{primitive.E{Key: "$exists", Value: false}}}},
+				Update: bson.D{
+					primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "roles", Value: []types.Role{types.RoleUser}}}},
+					unsetIsAdmin,
+				},
+			},
+			Step{
+				Collection: "users",
+				Filter:     bson.D{primitive.E{Key: "isAdmin", Value: bson.D{primitive.E{Key: "$exists", Value: true}}}},
+				Update:     bson.D{unsetIsAdmin},
+			},
+		),
+		// moderators go back to being normal users, the flag only knew admins
+		Down: Steps(
+			Step{
+				Collection: "users",
+				Filter:     bson.D{primitive.E{Key: "roles", Value: bson.D{primitive.E{Key: "$exists", Value: true}}}},
+				Update: mongo.Pipeline{
+					bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "isAdmin", Value: bson.D{primitive.E{Key: "$in", Value: bson.A{
+						types.RoleAdmin,
+						bson.D{primitive.E{Key: "$ifNull", Value: bson.A{"$roles", bson.A{}}}},
+					}}}}}}},
+					bson.D{primitive.E{Key: "$unset", Value: "roles"}},
+				},
+			},
+		),
+	})
+}
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"social-api/database"
+	"social-api/types"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const apiKeyCollectionName string = "apiKeys"
+
+func init() {
+	database.RegisterIndexes(apiKeyCollectionName,
+		database.Index{Name: "keyHash_1", Keys: bson.D{primitive.E{Key: "keyHash", Value: 1}}, Unique: true},
+		database.Index{Name: "userId_1", Keys: bson.D{primitive.E{Key: "userId", Value: 1}}},
+	)
+}
+
+//types here have to implement the  Modeler interface
+
+type APIKeyModel struct {
+	Collection *mongo.Collection
+}
+
+// simple search when you need to get a entry without any filter options
+// will only return single entry
+func (km *APIKeyModel) GetEntry(ctx context.Context, key bson.D) (*types.APIKeys, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	var entry types.APIKeys
+	// bson.d is a drivitive of primitive int so cannont be
+	// nil, so just check the length of the key
+	if len(key) == 0 {
+		return nil, errors.New("empty filter given")
+	}
+	err := km.Collection.FindOne(ctx, key).Decode(&entry)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (km *APIKeyModel) GetEntryAdvanced(ctx context.Context, filter bson.D, sort bson.D, opts ...QueryOption) ([]*types.APIKeys, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	cur, err := km.Collection.Find(ctx, filter, findOptions(sort, opts))
+	if err != nil {
+		return nil, err
+	}
+	entrys := []*types.APIKeys{}
+	if err = cur.All(ctx, &entrys); err != nil {
+		return nil, err
+	}
+	return entrys, nil
+}
+
+func (km *APIKeyModel) Count(ctx context.Context, filter bson.D) (int64, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	return km.Collection.CountDocuments(ctx, filter)
+}
+
+func (km *APIKeyModel) AddEntry(ctx context.Context, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	if len(val) <= 2 {
+		return errors.New("not enough values given to add api key")
+	}
+	if _, err := km.Collection.InsertOne(ctx, val); err != nil {
+		return err
+	}
+	return nil
+
+}
+func (km *APIKeyModel) RemoveEntry(ctx context.Context, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	if len(val) == 0 {
+		return errors.New("empty val value given")
+	}
+	if _, err := km.Collection.DeleteOne(ctx, val); err != nil {
+		return err
+	}
+	return nil
+}
+func (km *APIKeyModel) ModifyEntry(ctx context.Context, filter bson.D, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	if len(filter) == 0 {
+		return errors.New("empty filter value given")
+	}
+	if len(val) == 0 {
+		return errors.New("no empty update value given")
+	}
+	if _, err := km.Collection.UpdateOne(ctx, filter, val); err != nil {
+		return err
+	}
+	return nil
+}
+
+func NewAPIKeyModel(client *mongo.Database) *APIKeyModel {
+	c := client.Collection(apiKeyCollectionName)
+	return &APIKeyModel{
+		Collection: c,
+	}
+}
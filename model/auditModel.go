@@ -0,0 +1,102 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"social-api/database"
+	"social-api/types"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const auditCollectionName string = "auditLog"
+
+//types here have to implement the  Modeler interface
+
+type AuditModel struct {
+	Collection *mongo.Collection
+}
+
+// simple search when you need to get a entry without any filter options
+// will only return single entry
+func (am *AuditModel) GetEntry(ctx context.Context, key bson.D) (*types.AuditEntries, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	var entry types.AuditEntries
+	// bson.d is a drivitive of primitive int so cannont be
+	// nil, so just check the length of the key
+	if len(key) == 0 {
+		return nil, errors.New("empty filter given")
+	}
+	err := am.Collection.FindOne(ctx, key).Decode(&entry)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (am *AuditModel) GetEntryAdvanced(ctx context.Context, filter bson.D, sort bson.D, opts ...QueryOption) ([]*types.AuditEntries, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	cur, err := am.Collection.Find(ctx, filter, findOptions(sort, opts))
+	if err != nil {
+		return nil, err
+	}
+	entrys := []*types.AuditEntries{}
+	if err = cur.All(ctx, &entrys); err != nil {
+		return nil, err
+	}
+	return entrys, nil
+}
+
+func (am *AuditModel) Count(ctx context.Context, filter bson.D) (int64, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	return am.Collection.CountDocuments(ctx, filter)
+}
+
+func (am *AuditModel) AddEntry(ctx context.Context, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	if len(val) <= 2 {
+		return errors.New("not enough values given to add audit entry")
+	}
+	if _, err := am.Collection.InsertOne(ctx, val); err != nil {
+		return err
+	}
+	return nil
+
+}
+func (am *AuditModel) RemoveEntry(ctx context.Context, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	if len(val) == 0 {
+		return errors.New("empty val value given")
+	}
+	if _, err := am.Collection.DeleteOne(ctx, val); err != nil {
+		return err
+	}
+	return nil
+}
+func (am *AuditModel) ModifyEntry(ctx context.Context, filter bson.D, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	if len(filter) == 0 {
+		return errors.New("empty filter value given")
+	}
+	if len(val) == 0 {
+		return errors.New("no empty update value given")
+	}
+	if _, err := am.Collection.UpdateOne(ctx, filter, val); err != nil {
+		return err
+	}
+	return nil
+}
+
+func NewAuditModel(client *mongo.Database) *AuditModel {
+	c := client.Collection(auditCollectionName)
+	return &AuditModel{
+		Collection: c,
+	}
+}
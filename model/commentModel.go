@@ -0,0 +1,141 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"social-api/database"
+	"social-api/types"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const commentCollectionName string = "comments"
+
+// comments are listed per post and parent in _id order, ancestors finds
+// the replies of a thread when it is deleted
+func init() {
+	database.RegisterIndexes(commentCollectionName,
+		database.Index{Name: "postId_1_parentId_1__id_1", Keys: bson.D{
+			primitive.E{Key: "postId", Value: 1},
+			primitive.E{Key: "parentId", Value: 1},
+			primitive.E{Key: "_id", Value: 1},
+		}},
+		database.Index{Name: "ancestors_1", Keys: bson.D{primitive.E{Key: "ancestors", Value: 1}}},
+		database.Index{Name: "userId_1", Keys: bson.D{primitive.E{Key: "userId", Value: 1}}},
+	)
+}
+
+//types here have to implement the  Modeler interface
+
+type CommentModel struct {
+	Collection *mongo.Collection
+}
+
+func (cm *CommentModel) GetEntry(ctx context.Context, key bson.D) (*types.Comments, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	var entry types.Comments
+	if err := cm.Collection.FindOne(ctx, key).Decode(&entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (cm *CommentModel) GetEntryAdvanced(ctx context.Context, filter bson.D, sort bson.D, opts ...QueryOption) ([]*types.Comments, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	cur, err := cm.Collection.Find(ctx, filter, findOptions(sort, opts))
+	if err != nil {
+		return nil, err
+	}
+	entrys := []*types.Comments{}
+	if err = cur.All(ctx, &entrys); err != nil {
+		return nil, err
+	}
+	return entrys, nil
+}
+
+func (cm *CommentModel) Count(ctx context.Context, filter bson.D) (int64, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	return cm.Collection.CountDocuments(ctx, filter)
+}
+
+func (cm *CommentModel) AddEntry(ctx context.Context, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	if len(val) < 3 {
+		return errors.New("not enough values given to add comment")
+	}
+	if _, err := cm.Collection.InsertOne(ctx, val); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cm *CommentModel) RemoveEntry(ctx context.Context, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	if _, err := cm.Collection.DeleteOne(ctx, val); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cm *CommentModel) ModifyEntry(ctx context.Context, filter bson.D, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	if _, err := cm.Collection.UpdateOne(ctx, filter, val); err != nil {
+		return err
+	}
+	return nil
+}
+
+// deletes the comment and every reply under it
+func (cm *CommentModel) RemoveThread(ctx context.Context, commentId primitive.ObjectID) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	result, err := cm.Collection.DeleteMany(ctx, threadFilter(commentId))
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// deletes every comment on the post, used when the post is deleted
+func (cm *CommentModel) RemovePostComments(ctx context.Context, postId primitive.ObjectID) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	_, err := cm.Collection.DeleteMany(ctx, bson.D{primitive.E{Key: "postId", Value: postId}})
+	return err
+}
+
+// the comment and its replies, the memory model uses this as well
+func threadFilter(commentId primitive.ObjectID) bson.D {
+	return bson.D{primitive.E{Key: "$or", Value: bson.A{
+		bson.D{primitive.E{Key: "_id", Value: commentId}},
+		bson.D{primitive.E{Key: "ancestors", Value: commentId}},
+	}}}
+}
+
+// the comments that go when a account is removed, the ones the user wrote,
+// the ones on their posts and the replies under the ones they wrote
+func accountCommentsFilter(userId primitive.ObjectID, postIds []primitive.ObjectID, commentIds []primitive.ObjectID) bson.D {
+	return bson.D{primitive.E{Key: "$or", Value: bson.A{
+		bson.D{primitive.E{Key: "userId", Value: userId}},
+		bson.D{primitive.E{Key: "postId", Value: bson.D{primitive.E{Key: "$in", Value: postIds}}}},
+		bson.D{primitive.E{Key: "ancestors", Value: bson.D{primitive.E{Key: "$in", Value: commentIds}}}},
+	}}}
+}
+
+func NewCommentModel(client *mongo.Database) *CommentModel {
+	c := client.Collection(commentCollectionName)
+	return &CommentModel{
+		Collection: c,
+	}
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// adds a thread of comments on a new post, every comment is a reply to the
+// one before it. returns the post and the comment ids from the top down
+func addCommentThread(t *testing.T, comments CommentStore, size int) (primitive.ObjectID, []primitive.ObjectID) {
+	postId := primitive.NewObjectID()
+	var ids []primitive.ObjectID
+	for i := 0; i < size; i++ {
+		id := primitive.NewObjectID()
+		comment := bson.D{
+			primitive.E{Key: "_id", Value: id},
+			primitive.E{Key: "postId", Value: postId},
+			primitive.E{Key: "userId", Value: primitive.NewObjectID()},
+			primitive.E{Key: "ancestors", Value: append([]primitive.ObjectID{}, ids...)},
+			primitive.E{Key: "text", Value: "comment"},
+		}
+		if i > 0 {
+			comment = append(comment, primitive.E{Key: "parentId", Value: ids[i-1]})
+		}
+		if err := comments.AddEntry(context.Background(), comment); err != nil {
+			t.Fatalf("error when adding the comment, :%v", err)
+		}
+		ids = append(ids, id)
+	}
+	return postId, ids
+}
+
+// the same checks run on the mongo and memory models
+func checkCommentRemoval(t *testing.T, comments CommentStore) {
+	testtable := []struct {
+		remove   int
+		expected int64
+	}{
+		// the middle of the thread takes everything under it
+		{remove: 1, expected: 1},
+		{remove: 3, expected: 3},
+		{remove: 0, expected: 0},
+	}
+	for i, tt := range testtable {
+		postId, ids := addCommentThread(t, comments, 4)
+		if err := comments.RemoveThread(context.Background(), ids[tt.remove]); err != nil {
+			t.Fatalf("case %d error when removing the thread, :%v", i, err)
+		}
+		count, err := comments.Count(context.Background(), bson.D{primitive.E{Key: "postId", Value: postId}})
+		if err != nil {
+			t.Fatalf("case %d error when counting, :%v", i, err)
+		}
+		if count != tt.expected {
+			t.Errorf("case %d wrong number of comments left, got=%d, want=%d", i, count, tt.expected)
+		}
+		if err := comments.RemoveThread(context.Background(), ids[tt.remove]); !errors.Is(err, mongo.ErrNoDocuments) {
+			t.Errorf("case %d wrong error when removing again, got=%v, want=%v", i, err, mongo.ErrNoDocuments)
+		}
+		comments.RemovePostComments(context.Background(), postId)
+	}
+	postId, _ := addCommentThread(t, comments, 3)
+	if err := comments.RemovePostComments(context.Background(), postId); err != nil {
+		t.Fatalf("error when removing the comments of the post, :%v", err)
+	}
+	if count, _ := comments.Count(context.Background(), bson.D{primitive.E{Key: "postId", Value: postId}}); count != 0 {
+		t.Errorf("comments of the post were kept, got=%d", count)
+	}
+}
+
+func TestCommentRemoveThread(t *testing.T) {
+	checkCommentRemoval(t, NewCommentModel(testDatabase(t)))
+}
+
+func TestMemoryCommentRemoveThread(t *testing.T) {
+	checkCommentRemoval(t, NewMemoryCommentModel(NewMemoryDatabase()))
+}
@@ -0,0 +1,679 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"social-api/database"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MemoryDatabase keeps collections of documents in memory so the handlers
+// can be tested without a mongo server. it understands the filters and
+// updates the api uses (equality, $in, $ne, $lt/$gt, $exists, $or,
+// $elemMatch, $type, and the $set/$unset/$inc/$addToSet/$push/$pull
+// updates), anything else returns a error so a test cant pass by accident
+type MemoryDatabase struct {
+	mu          sync.Mutex
+	collections map[string][]bson.D
+	// transactions run one at a time
+	tx sync.Mutex
+}
+
+func NewMemoryDatabase() *MemoryDatabase {
+	return &MemoryDatabase{collections: make(map[string][]bson.D)}
+}
+
+// runs fn and puts every collection back the way it was if fn fails, this
+// also makes MemoryDatabase a Transactor
+func (md *MemoryDatabase) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	md.tx.Lock()
+	defer md.tx.Unlock()
+	md.mu.Lock()
+	// documents are never changed in place so copying the slices is enough
+	snapshot := make(map[string][]bson.D, len(md.collections))
+	for name, docs := range md.collections {
+		snapshot[name] = append([]bson.D(nil), docs...)
+	}
+	md.mu.Unlock()
+	if err := fn(ctx); err != nil {
+		md.mu.Lock()
+		md.collections = snapshot
+		md.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// the methods below expect md.mu to be held
+
+func (md *MemoryDatabase) find(collection string, filter bson.D, sortBy bson.D, q QueryOptions) ([]bson.D, error) {
+	filter, err := normalizeDoc(filter)
+	if err != nil {
+		return nil, err
+	}
+	var found []bson.D
+	for _, doc := range md.collections[collection] {
+		ok, err := matches(doc, filter)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			found = append(found, doc)
+		}
+	}
+	if err := sortDocs(found, sortBy); err != nil {
+		return nil, err
+	}
+	if q.Skip > 0 {
+		if q.Skip >= int64(len(found)) {
+			found = nil
+		} else {
+			found = found[q.Skip:]
+		}
+	}
+	if q.Limit > 0 && q.Limit < int64(len(found)) {
+		found = found[:q.Limit]
+	}
+	if q.Projection != nil {
+		return project(found, q.Projection)
+	}
+	return found, nil
+}
+
+func (md *MemoryDatabase) insert(collection string, val bson.D) error {
+	doc, err := normalizeDoc(val)
+	if err != nil {
+		return err
+	}
+	if _, ok := lookup(doc, "_id"); !ok {
+		doc = append(bson.D{primitive.E{Key: "_id", Value: primitive.NewObjectID()}}, doc...)
+	}
+	if err := md.checkUnique(collection, doc, -1); err != nil {
+		return err
+	}
+	md.collections[collection] = append(md.collections[collection], doc)
+	return nil
+}
+
+// returns how many documents matched the filter
+func (md *MemoryDatabase) update(collection string, filter bson.D, update bson.D, many bool) (int, error) {
+	filter, err := normalizeDoc(filter)
+	if err != nil {
+		return 0, err
+	}
+	update, err = normalizeDoc(update)
+	if err != nil {
+		return 0, err
+	}
+	matched := 0
+	docs := md.collections[collection]
+	for i, doc := range docs {
+		ok, err := matches(doc, filter)
+		if err != nil {
+			return matched, err
+		}
+		if !ok {
+			continue
+		}
+		changed, err := applyUpdate(doc, update)
+		if err != nil {
+			return matched, err
+		}
+		if err := md.checkUnique(collection, changed, i); err != nil {
+			return matched, err
+		}
+		docs[i] = changed
+		matched++
+		if !many {
+			break
+		}
+	}
+	return matched, nil
+}
+
+// returns how many documents were removed
+func (md *MemoryDatabase) delete(collection string, filter bson.D, many bool) (int, error) {
+	filter, err := normalizeDoc(filter)
+	if err != nil {
+		return 0, err
+	}
+	var kept []bson.D
+	deleted := 0
+	for _, doc := range md.collections[collection] {
+		ok, err := matches(doc, filter)
+		if err != nil {
+			return 0, err
+		}
+		if ok && (many || deleted == 0) {
+			deleted++
+			continue
+		}
+		kept = append(kept, doc)
+	}
+	md.collections[collection] = kept
+	return deleted, nil
+}
+
+// the ids of the documents that match the filter, same as distinctIDs
+func (md *MemoryDatabase) ids(collection string, filter bson.D) ([]primitive.ObjectID, error) {
+	docs, err := md.find(collection, filter, nil, QueryOptions{})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(docs))
+	for _, doc := range docs {
+		value, _ := lookup(doc, "_id")
+		if id, ok := value.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// acts like the _id index and the unique indexes registered for the
+// collection, skip is the index of the document being updated
+func (md *MemoryDatabase) checkUnique(collection string, doc bson.D, skip int) error {
+	keys := [][]string{{"_id"}}
+	for _, index := range database.RegisteredIndexes(collection) {
+		if index.Unique {
+			var fields []string
+			for _, key := range index.Keys {
+				fields = append(fields, key.Key)
+			}
+			keys = append(keys, fields)
+		}
+	}
+	for i, other := range md.collections[collection] {
+		if i == skip {
+			continue
+		}
+		for _, fields := range keys {
+			same := true
+			for _, field := range fields {
+				a, _ := lookup(doc, field)
+				b, _ := lookup(other, field)
+				if !equal(a, b) {
+					same = false
+					break
+				}
+			}
+			if same {
+				return mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+					Code:    11000,
+					Message: fmt.Sprintf("E11000 duplicate key error collection: %s index: %s", collection, strings.Join(fields, "_")),
+				}}}
+			}
+		}
+	}
+	return nil
+}
+
+// documents and filters are round tripped through bson so the values are
+// the same types a server would send back (bson.A, int32, DateTime...)
+func normalizeDoc(val any) (bson.D, error) {
+	if val == nil {
+		return bson.D{}, nil
+	}
+	raw, err := bson.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func lookup(doc bson.D, path string) (any, bool) {
+	first, rest, nested := strings.Cut(path, ".")
+	for _, e := range doc {
+		if e.Key != first {
+			continue
+		}
+		if !nested {
+			return e.Value, true
+		}
+		if inner, ok := e.Value.(bson.D); ok {
+			return lookup(inner, rest)
+		}
+		return nil, false
+	}
+	return nil, false
+}
+
+// returns a copy of the doc with the field set
+func withField(doc bson.D, path string, value any) bson.D {
+	first, rest, nested := strings.Cut(path, ".")
+	out := make(bson.D, 0, len(doc)+1)
+	set := false
+	for _, e := range doc {
+		if e.Key == first {
+			if nested {
+				inner, _ := e.Value.(bson.D)
+				e.Value = withField(inner, rest, value)
+			} else {
+				e.Value = value
+			}
+			set = true
+		}
+		out = append(out, e)
+	}
+	if !set {
+		if nested {
+			value = withField(bson.D{}, rest, value)
+		}
+		out = append(out, primitive.E{Key: first, Value: value})
+	}
+	return out
+}
+
+func withoutField(doc bson.D, field string) bson.D {
+	out := make(bson.D, 0, len(doc))
+	for _, e := range doc {
+		if e.Key != field {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func matches(doc bson.D, filter bson.D) (bool, error) {
+	for _, e := range filter {
+		switch e.Key {
+		case "$or", "$and":
+			clauses, ok := e.Value.(bson.A)
+			if !ok {
+				return false, errors.New("memory model: " + e.Key + " needs a array")
+			}
+			some, all := false, true
+			for _, clause := range clauses {
+				sub, _ := clause.(bson.D)
+				ok, err := matches(doc, sub)
+				if err != nil {
+					return false, err
+				}
+				some = some || ok
+				all = all && ok
+			}
+			if (e.Key == "$or" && !some) || (e.Key == "$and" && !all) {
+				return false, nil
+			}
+			continue
+		}
+		if strings.HasPrefix(e.Key, "$") {
+			return false, errors.New("memory model: unsupported filter " + e.Key)
+		}
+		value, exists := lookup(doc, e.Key)
+		ok, err := matchValue(value, exists, e.Value)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func isOperatorDoc(v any) (bson.D, bool) {
+	d, ok := v.(bson.D)
+	if !ok || len(d) == 0 || !strings.HasPrefix(d[0].Key, "$") {
+		return nil, false
+	}
+	return d, true
+}
+
+// checks the value of a field against the condition for it in the filter
+func matchValue(value any, exists bool, condition any) (bool, error) {
+	ops, ok := isOperatorDoc(condition)
+	if !ok {
+		return equalOrContains(value, condition), nil
+	}
+	for _, op := range ops {
+		var ok bool
+		switch op.Key {
+		case "$eq":
+			ok = equalOrContains(value, op.Value)
+		case "$ne":
+			ok = !equalOrContains(value, op.Value)
+		case "$in", "$nin":
+			options, isArray := op.Value.(bson.A)
+			if !isArray {
+				return false, errors.New("memory model: " + op.Key + " needs a array")
+			}
+			for _, option := range options {
+				if equalOrContains(value, option) {
+					ok = true
+					break
+				}
+			}
+			if op.Key == "$nin" {
+				ok = !ok
+			}
+		case "$gt", "$gte", "$lt", "$lte":
+			ok = anyElement(value, func(v any) bool {
+				c, comparable := compare(v, op.Value)
+				if !comparable {
+					return false
+				}
+				switch op.Key {
+				case "$gt":
+					return c > 0
+				case "$gte":
+					return c >= 0
+				case "$lt":
+					return c < 0
+				}
+				return c <= 0
+			})
+		case "$exists":
+			want, _ := op.Value.(bool)
+			ok = exists == want
+		case "$type":
+			name, _ := op.Value.(string)
+			ok = anyElement(value, func(v any) bool { return typeName(v) == name || (name == "number" && isNumber(v)) })
+		case "$elemMatch":
+			array, isArray := value.(bson.A)
+			if !isArray {
+				break
+			}
+			_, onValue := isOperatorDoc(op.Value)
+			for _, elem := range array {
+				var err error
+				// {$elemMatch: {field: ...}} is a filter on documents in the array,
+				// {$elemMatch: {$op: ...}} is a condition on the values
+				if sub, isDoc := elem.(bson.D); isDoc && !onValue {
+					cond, _ := op.Value.(bson.D)
+					ok, err = matches(sub, cond)
+				} else {
+					ok, err = matchValue(elem, true, op.Value)
+				}
+				if err != nil {
+					return false, err
+				}
+				if ok {
+					break
+				}
+			}
+		default:
+			return false, errors.New("memory model: unsupported filter operator " + op.Key)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// a array field matches if it equals the value or one of its elements does
+func equalOrContains(value any, want any) bool {
+	if equal(value, want) {
+		return true
+	}
+	if array, ok := value.(bson.A); ok {
+		for _, elem := range array {
+			if equal(elem, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func anyElement(value any, check func(any) bool) bool {
+	if array, ok := value.(bson.A); ok {
+		for _, elem := range array {
+			if check(elem) {
+				return true
+			}
+		}
+		return false
+	}
+	return check(value)
+}
+
+func applyUpdate(doc bson.D, update bson.D) (bson.D, error) {
+	out := append(bson.D(nil), doc...)
+	for _, op := range update {
+		fields, ok := op.Value.(bson.D)
+		if !ok {
+			return nil, errors.New("memory model: only update operators are supported, got " + op.Key)
+		}
+		for _, f := range fields {
+			current, _ := lookup(out, f.Key)
+			switch op.Key {
+			case "$set":
+				out = withField(out, f.Key, f.Value)
+			case "$unset":
+				out = withoutField(out, f.Key)
+			case "$inc":
+				sum, err := add(current, f.Value)
+				if err != nil {
+					return nil, err
+				}
+				out = withField(out, f.Key, sum)
+			case "$addToSet", "$push":
+				array, _ := current.(bson.A)
+				array = append(bson.A(nil), array...)
+				values := bson.A{f.Value}
+				if each, ok := isOperatorDoc(f.Value); ok && each[0].Key == "$each" {
+					values, _ = each[0].Value.(bson.A)
+				}
+				for _, v := range values {
+					if op.Key == "$push" || !equalOrContains(array, v) {
+						array = append(array, v)
+					}
+				}
+				out = withField(out, f.Key, array)
+			case "$pull":
+				array, isArray := current.(bson.A)
+				if !isArray {
+					// like mongo a missing field stays missing
+					continue
+				}
+				kept := bson.A{}
+				for _, elem := range array {
+					ok, err := matchValue(elem, true, f.Value)
+					if err != nil {
+						return nil, err
+					}
+					if !ok {
+						kept = append(kept, elem)
+					}
+				}
+				out = withField(out, f.Key, kept)
+			default:
+				return nil, errors.New("memory model: unsupported update operator " + op.Key)
+			}
+		}
+	}
+	return out, nil
+}
+
+func sortDocs(docs []bson.D, sortBy bson.D) error {
+	if len(sortBy) == 0 {
+		return nil
+	}
+	directions := make([]int, len(sortBy))
+	for i, key := range sortBy {
+		n, ok := toFloat(key.Value)
+		if !ok || (n != 1 && n != -1) {
+			return errors.New("memory model: sort direction has to be 1 or -1")
+		}
+		directions[i] = int(n)
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		for k, key := range sortBy {
+			a, _ := lookup(docs[i], key.Key)
+			b, _ := lookup(docs[j], key.Key)
+			if c := order(a, b); c != 0 {
+				return c*directions[k] < 0
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+// only inclusion and exclusion of top level fields
+func project(docs []bson.D, projection any) ([]bson.D, error) {
+	fields, err := normalizeDoc(projection)
+	if err != nil {
+		return nil, err
+	}
+	include := map[string]bool{}
+	exclude := map[string]bool{}
+	for _, f := range fields {
+		if n, ok := toFloat(f.Value); (ok && n != 0) || f.Value == true {
+			include[f.Key] = true
+		} else {
+			exclude[f.Key] = true
+		}
+	}
+	out := make([]bson.D, 0, len(docs))
+	for _, doc := range docs {
+		var projected bson.D
+		for _, e := range doc {
+			keep := !exclude[e.Key] && (len(include) == 0 || include[e.Key] || e.Key == "_id")
+			if keep {
+				projected = append(projected, e)
+			}
+		}
+		out = append(out, projected)
+	}
+	return out, nil
+}
+
+func isNumber(v any) bool {
+	_, ok := toFloat(v)
+	return ok
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func add(a any, b any) (any, error) {
+	if a == nil {
+		return b, nil
+	}
+	x, okA := toFloat(a)
+	y, okB := toFloat(b)
+	if !okA || !okB {
+		return nil, errors.New("memory model: $inc needs numbers")
+	}
+	if _, isFloat := a.(float64); isFloat {
+		return x + y, nil
+	}
+	if _, isFloat := b.(float64); isFloat {
+		return x + y, nil
+	}
+	return int64(x + y), nil
+}
+
+func equal(a any, b any) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// the $type names of the values normalizeDoc makes
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case int32:
+		return "int"
+	case int64:
+		return "long"
+	case float64:
+		return "double"
+	case bool:
+		return "bool"
+	case primitive.ObjectID:
+		return "objectId"
+	case primitive.DateTime:
+		return "date"
+	case bson.A:
+		return "array"
+	case bson.D:
+		return "object"
+	}
+	return "unknown"
+}
+
+// compares values of the same kind, false if they cant be compared
+func compare(a any, b any) (int, bool) {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case primitive.ObjectID:
+		if y, ok := b.(primitive.ObjectID); ok {
+			return bytes.Compare(x[:], y[:]), true
+		}
+	case primitive.DateTime:
+		if y, ok := b.(primitive.DateTime); ok {
+			return compare(int64(x), int64(y))
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			if x == y {
+				return 0, true
+			}
+			if !x {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
+// mongo sorts values of different types by the type first
+var typeOrder = map[string]int{
+	"null": 0, "int": 1, "long": 1, "double": 1, "string": 2, "object": 3,
+	"array": 4, "objectId": 5, "bool": 6, "date": 7, "unknown": 8,
+}
+
+func order(a any, b any) int {
+	if c, ok := compare(a, b); ok {
+		return c
+	}
+	return typeOrder[typeName(a)] - typeOrder[typeName(b)]
+}
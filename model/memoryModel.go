@@ -0,0 +1,377 @@
+package model
+
+import (
+	"context"
+	"social-api/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MemoryModel implements the Modeler on a collection of a MemoryDatabase,
+// T is the type the documents are decoded into
+type MemoryModel[T any] struct {
+	db         *MemoryDatabase
+	collection string
+}
+
+func NewMemoryModel[T any](db *MemoryDatabase, collection string) *MemoryModel[T] {
+	return &MemoryModel[T]{db: db, collection: collection}
+}
+
+func (mm *MemoryModel[T]) GetEntry(ctx context.Context, key bson.D) (*T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	mm.db.mu.Lock()
+	docs, err := mm.db.find(mm.collection, key, nil, QueryOptions{Limit: 1})
+	mm.db.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	return decode[T](docs[0])
+}
+
+func (mm *MemoryModel[T]) GetEntryAdvanced(ctx context.Context, filter bson.D, sort bson.D, opts ...QueryOption) ([]*T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	mm.db.mu.Lock()
+	docs, err := mm.db.find(mm.collection, filter, sort, NewQueryOptions(opts...))
+	mm.db.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	entrys := make([]*T, 0, len(docs))
+	for _, doc := range docs {
+		entry, err := decode[T](doc)
+		if err != nil {
+			return nil, err
+		}
+		entrys = append(entrys, entry)
+	}
+	return entrys, nil
+}
+
+func (mm *MemoryModel[T]) Count(ctx context.Context, filter bson.D) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	mm.db.mu.Lock()
+	defer mm.db.mu.Unlock()
+	docs, err := mm.db.find(mm.collection, filter, nil, QueryOptions{})
+	return int64(len(docs)), err
+}
+
+func (mm *MemoryModel[T]) AddEntry(ctx context.Context, val bson.D) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mm.db.mu.Lock()
+	defer mm.db.mu.Unlock()
+	return mm.db.insert(mm.collection, val)
+}
+
+func (mm *MemoryModel[T]) RemoveEntry(ctx context.Context, val bson.D) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mm.db.mu.Lock()
+	defer mm.db.mu.Unlock()
+	_, err := mm.db.delete(mm.collection, val, false)
+	return err
+}
+
+func (mm *MemoryModel[T]) ModifyEntry(ctx context.Context, filter bson.D, val bson.D) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mm.db.mu.Lock()
+	defer mm.db.mu.Unlock()
+	_, err := mm.db.update(mm.collection, filter, val, false)
+	return err
+}
+
+// same as updateMembers but on the memory database, md.mu has to be held
+func (mm *MemoryModel[T]) updateMembers(id primitive.ObjectID, filter bson.D, update bson.D) (bool, error) {
+	matched, err := mm.db.update(mm.collection, filter, update, false)
+	if err != nil || matched == 1 {
+		return matched == 1, err
+	}
+	exists, err := mm.db.find(mm.collection, bson.D{primitive.E{Key: "_id", Value: id}}, nil, QueryOptions{Limit: 1})
+	if err != nil {
+		return false, err
+	}
+	if len(exists) == 0 {
+		return false, mongo.ErrNoDocuments
+	}
+	return false, nil
+}
+
+func decode[T any](doc bson.D) (*T, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var entry T
+	if err := bson.Unmarshal(raw, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// MemoryPostModel is the PostStore on a MemoryDatabase
+type MemoryPostModel struct {
+	*MemoryModel[types.Posts]
+}
+
+func NewMemoryPostModel(db *MemoryDatabase) *MemoryPostModel {
+	return &MemoryPostModel{NewMemoryModel[types.Posts](db, postCollectionName)}
+}
+
+func (mp *MemoryPostModel) Like(ctx context.Context, postId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	mp.db.mu.Lock()
+	defer mp.db.mu.Unlock()
+	filter, update := addMemberUpdate(postId, "likes", userId)
+	return mp.updateMembers(postId, filter, update)
+}
+
+func (mp *MemoryPostModel) Unlike(ctx context.Context, postId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	mp.db.mu.Lock()
+	defer mp.db.mu.Unlock()
+	filter, update := removeMemberUpdate(postId, "likes", userId)
+	return mp.updateMembers(postId, filter, update)
+}
+
+// MemoryUserModel is the UserStore on a MemoryDatabase, every method holds
+// the lock for the whole call so the two sided writes are atomic
+type MemoryUserModel struct {
+	*MemoryModel[types.Users]
+}
+
+func NewMemoryUserModel(db *MemoryDatabase) *MemoryUserModel {
+	return &MemoryUserModel{NewMemoryModel[types.Users](db, userCollectionName)}
+}
+
+func (mum *MemoryUserModel) Follow(ctx context.Context, userId primitive.ObjectID, followId primitive.ObjectID) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	mum.db.mu.Lock()
+	defer mum.db.mu.Unlock()
+	filter, update := addMemberUpdate(followId, "followers", userId)
+	changed, err := mum.updateMembers(followId, filter, update)
+	if err != nil {
+		return false, err
+	}
+	filter, update = addMemberUpdate(userId, "followings", followId)
+	if _, err := mum.updateMembers(userId, filter, update); err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+func (mum *MemoryUserModel) Unfollow(ctx context.Context, userId primitive.ObjectID, followId primitive.ObjectID) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	mum.db.mu.Lock()
+	defer mum.db.mu.Unlock()
+	filter, update := removeMemberUpdate(followId, "followers", userId)
+	changed, err := mum.updateMembers(followId, filter, update)
+	if err != nil {
+		return false, err
+	}
+	filter, update = removeMemberUpdate(userId, "followings", followId)
+	if _, err := mum.updateMembers(userId, filter, update); err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+func (mum *MemoryUserModel) UseRecoveryCode(ctx context.Context, userId primitive.ObjectID, hash string) (bool, error) {
+	filter, update := recoveryCodeUpdate(userId, hash)
+	return mum.updateOnce(ctx, filter, update)
+}
+
+func (mum *MemoryUserModel) UseTOTPStep(ctx context.Context, userId primitive.ObjectID, step int64) (bool, error) {
+	filter, update := totpStepUpdate(userId, step)
+	return mum.updateOnce(ctx, filter, update)
+}
+
+func (mum *MemoryUserModel) updateOnce(ctx context.Context, filter bson.D, update bson.D) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	mum.db.mu.Lock()
+	defer mum.db.mu.Unlock()
+	matched, err := mum.db.update(userCollectionName, filter, update, false)
+	return matched == 1, err
+}
+
+// same cascade as UserModel.RemoveAccount
+func (mum *MemoryUserModel) RemoveAccount(ctx context.Context, userId primitive.ObjectID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mum.db.mu.Lock()
+	defer mum.db.mu.Unlock()
+	deleted, err := mum.db.delete(userCollectionName, bson.D{primitive.E{Key: "_id", Value: userId}}, false)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return mongo.ErrNoDocuments
+	}
+	followsFilter, pullFollows := pullFollowsUpdate(userId)
+	if _, err := mum.db.update(userCollectionName, followsFilter, pullFollows, true); err != nil {
+		return err
+	}
+	owned := bson.D{primitive.E{Key: "userId", Value: userId}}
+	postIds, err := mum.db.ids(postCollectionName, owned)
+	if err != nil {
+		return err
+	}
+	commentIds, err := mum.db.ids(commentCollectionName, owned)
+	if err != nil {
+		return err
+	}
+	if _, err := mum.db.delete(commentCollectionName, accountCommentsFilter(userId, postIds, commentIds), true); err != nil {
+		return err
+	}
+	if _, err := mum.db.delete(postCollectionName, owned, true); err != nil {
+		return err
+	}
+	pullLikes := bson.D{primitive.E{Key: "$pull", Value: bson.D{primitive.E{Key: "likes", Value: userId}}}}
+	if _, err := mum.db.update(postCollectionName, bson.D{primitive.E{Key: "likes", Value: userId}}, pullLikes, true); err != nil {
+		return err
+	}
+	for _, name := range []string{sessionCollectionName, userTokenCollectionName, apiKeyCollectionName} {
+		if _, err := mum.db.delete(name, owned, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MemoryCommentModel is the CommentStore on a MemoryDatabase
+type MemoryCommentModel struct {
+	*MemoryModel[types.Comments]
+}
+
+func NewMemoryCommentModel(db *MemoryDatabase) *MemoryCommentModel {
+	return &MemoryCommentModel{NewMemoryModel[types.Comments](db, commentCollectionName)}
+}
+
+func (mc *MemoryCommentModel) RemoveThread(ctx context.Context, commentId primitive.ObjectID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mc.db.mu.Lock()
+	defer mc.db.mu.Unlock()
+	deleted, err := mc.db.delete(commentCollectionName, threadFilter(commentId), true)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (mc *MemoryCommentModel) RemovePostComments(ctx context.Context, postId primitive.ObjectID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mc.db.mu.Lock()
+	defer mc.db.mu.Unlock()
+	_, err := mc.db.delete(commentCollectionName, bson.D{primitive.E{Key: "postId", Value: postId}}, true)
+	return err
+}
+
+// the other collections only need the plain Modeler
+
+// MemorySessionModel is the SessionStore on a MemoryDatabase
+type MemorySessionModel struct {
+	*MemoryModel[types.Sessions]
+}
+
+func NewMemorySessionModel(db *MemoryDatabase) *MemorySessionModel {
+	return &MemorySessionModel{NewMemoryModel[types.Sessions](db, sessionCollectionName)}
+}
+
+func (ms *MemorySessionModel) RotateToken(ctx context.Context, oldHash string, now time.Time, set bson.D) (*types.Sessions, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ms.db.mu.Lock()
+	defer ms.db.mu.Unlock()
+	filter, update := rotateTokenUpdate(oldHash, now, set)
+	docs, err := ms.db.find(sessionCollectionName, filter, nil, QueryOptions{Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	id, _ := lookup(docs[0], "_id")
+	key := bson.D{primitive.E{Key: "_id", Value: id}}
+	if _, err := ms.db.update(sessionCollectionName, key, update, false); err != nil {
+		return nil, err
+	}
+	docs, err = ms.db.find(sessionCollectionName, key, nil, QueryOptions{Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	return decode[types.Sessions](docs[0])
+}
+
+// MemoryUserTokenModel is the UserTokenStore on a MemoryDatabase
+type MemoryUserTokenModel struct {
+	*MemoryModel[types.UserTokens]
+}
+
+func NewMemoryUserTokenModel(db *MemoryDatabase) *MemoryUserTokenModel {
+	return &MemoryUserTokenModel{NewMemoryModel[types.UserTokens](db, userTokenCollectionName)}
+}
+
+func (mt *MemoryUserTokenModel) UseToken(ctx context.Context, tokenId primitive.ObjectID, usedAt time.Time) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	mt.db.mu.Lock()
+	defer mt.db.mu.Unlock()
+	filter, update := useTokenUpdate(tokenId, usedAt)
+	matched, err := mt.db.update(userTokenCollectionName, filter, update, false)
+	return matched == 1, err
+}
+
+func NewMemoryAPIKeyModel(db *MemoryDatabase) *MemoryModel[types.APIKeys] {
+	return NewMemoryModel[types.APIKeys](db, apiKeyCollectionName)
+}
+
+func NewMemoryAuditModel(db *MemoryDatabase) *MemoryModel[types.AuditEntries] {
+	return NewMemoryModel[types.AuditEntries](db, auditCollectionName)
+}
+
+// the memory models have to keep up with the interfaces the handlers use
+var (
+	_ PostStore                        = (*MemoryPostModel)(nil)
+	_ UserStore                        = (*MemoryUserModel)(nil)
+	_ CommentStore                     = (*MemoryCommentModel)(nil)
+	_ UserTokenStore                   = (*MemoryUserTokenModel)(nil)
+	_ SessionStore                     = (*MemorySessionModel)(nil)
+	_ Modeler[*types.Sessions, bson.D] = (*MemoryModel[types.Sessions])(nil)
+	_ Transactor                       = (*MemoryDatabase)(nil)
+)
@@ -0,0 +1,330 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"social-api/types"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ids made in order so the _id sort is known
+func memoryPosts(t *testing.T, db *MemoryDatabase) ([]primitive.ObjectID, primitive.ObjectID, primitive.ObjectID) {
+	alice := primitive.NewObjectID()
+	bob := primitive.NewObjectID()
+	posts := NewMemoryPostModel(db)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var ids []primitive.ObjectID
+	for i := 0; i < 5; i++ {
+		post := types.NewPost()
+		post.UserID = alice
+		if i%2 == 1 {
+			post.UserID = bob
+			post.Likes = []primitive.ObjectID{alice}
+		}
+		post.Image = "image.png"
+		post.CreatedAt = start.Add(time.Duration(i) * time.Hour)
+		if err := posts.AddEntry(context.Background(), buildTestPost(post)); err != nil {
+			t.Fatalf("error when adding the post, :%v", err)
+		}
+		ids = append(ids, post.PostID)
+	}
+	return ids, alice, bob
+}
+
+func buildTestPost(post *types.Posts) bson.D {
+	return bson.D{
+		primitive.E{Key: "_id", Value: post.PostID},
+		primitive.E{Key: "userId", Value: post.UserID},
+		primitive.E{Key: "img", Value: post.Image},
+		primitive.E{Key: "likes", Value: post.Likes},
+		primitive.E{Key: "created_at", Value: post.CreatedAt},
+	}
+}
+
+func TestMemoryGetEntryAdvanced(t *testing.T) {
+	db := NewMemoryDatabase()
+	ids, alice, bob := memoryPosts(t, db)
+	posts := NewMemoryPostModel(db)
+	newest := bson.D{primitive.E{Key: "created_at", Value: -1}}
+	testtable := []struct {
+		filter   bson.D
+		sort     bson.D
+		opts     []QueryOption
+		expected []primitive.ObjectID
+	}{
+		{filter: bson.D{primitive.E{Key: "userId", Value: bob}}, sort: newest, expected: []primitive.ObjectID{ids[3], ids[1]}},
+		{filter: bson.D{primitive.E{Key: "userId", Value: bson.D{primitive.E{Key: "$in", Value: []primitive.ObjectID{alice, bob}}}}}, sort: newest, opts: []QueryOption{Limit(2)}, expected: []primitive.ObjectID{ids[4], ids[3]}},
+		{filter: bson.D{}, sort: bson.D{primitive.E{Key: "_id", Value: 1}}, opts: []QueryOption{Skip(3)}, expected: []primitive.ObjectID{ids[3], ids[4]}},
+		// how the pages after a cursor are found
+		{filter: bson.D{primitive.E{Key: "_id", Value: bson.D{primitive.E{Key: "$lt", Value: ids[2]}}}}, sort: bson.D{primitive.E{Key: "_id", Value: -1}}, expected: []primitive.ObjectID{ids[1], ids[0]}},
+		// equality on a array field matches any element
+		{filter: bson.D{primitive.E{Key: "likes", Value: alice}}, sort: newest, expected: []primitive.ObjectID{ids[3], ids[1]}},
+		{filter: bson.D{primitive.E{Key: "likes", Value: bson.D{primitive.E{Key: "$ne", Value: alice}}}}, sort: newest, expected: []primitive.ObjectID{ids[4], ids[2], ids[0]}},
+		{filter: bson.D{primitive.E{Key: "likes", Value: bson.D{primitive.E{Key: "$elemMatch", Value: bson.D{primitive.E{Key: "$type", Value: "objectId"}}}}}}, sort: newest, expected: []primitive.ObjectID{ids[3], ids[1]}},
+		{filter: bson.D{primitive.E{Key: "created_at", Value: bson.D{primitive.E{Key: "$gte", Value: time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)}}}}, sort: newest, expected: []primitive.ObjectID{ids[4], ids[3]}},
+		{filter: bson.D{primitive.E{Key: "$or", Value: bson.A{bson.D{primitive.E{Key: "_id", Value: ids[0]}}, bson.D{primitive.E{Key: "_id", Value: ids[4]}}}}}, sort: newest, expected: []primitive.ObjectID{ids[4], ids[0]}},
+		{filter: bson.D{primitive.E{Key: "desc", Value: bson.D{primitive.E{Key: "$exists", Value: true}}}}, sort: newest, expected: nil},
+	}
+	for i, tt := range testtable {
+		got, err := posts.GetEntryAdvanced(context.Background(), tt.filter, tt.sort, tt.opts...)
+		if err != nil {
+			t.Fatalf("case %d error when calling GetEntryAdvanced, :%v", i, err)
+		}
+		if got == nil {
+			t.Errorf("case %d should get a empty slice and not nil", i)
+		}
+		if len(got) != len(tt.expected) {
+			t.Fatalf("case %d wrong number of posts, got=%d, want=%d", i, len(got), len(tt.expected))
+		}
+		for j, post := range got {
+			if post.PostID != tt.expected[j] {
+				t.Errorf("case %d wrong post at %d, got=%s, want=%s", i, j, post.PostID.Hex(), tt.expected[j].Hex())
+			}
+		}
+	}
+	projected, err := posts.GetEntryAdvanced(context.Background(), bson.D{}, nil, Project(bson.D{primitive.E{Key: "userId", Value: 1}}))
+	if err != nil || len(projected) != 5 {
+		t.Fatalf("error when projecting, got=%d posts, err=%v", len(projected), err)
+	}
+	if projected[0].PostID.IsZero() || projected[0].UserID.IsZero() || projected[0].Image != "" {
+		t.Errorf("projection should keep only _id and userId, got=%+v", projected[0])
+	}
+	if count, _ := posts.Count(context.Background(), bson.D{primitive.E{Key: "userId", Value: alice}}); count != 3 {
+		t.Errorf("wrong count, got=%d, want=%d", count, 3)
+	}
+}
+
+func TestMemoryModifyEntry(t *testing.T) {
+	db := NewMemoryDatabase()
+	users := NewMemoryUserModel(db)
+	user := types.NewUser()
+	user.Username = "memory"
+	user.Email = "memory@gmail.com"
+	key := bson.D{primitive.E{Key: "_id", Value: user.UserID}}
+	doc := bson.D{
+		primitive.E{Key: "_id", Value: user.UserID},
+		primitive.E{Key: "username", Value: user.Username},
+		primitive.E{Key: "email", Value: user.Email},
+		primitive.E{Key: "roles", Value: user.Roles},
+		primitive.E{Key: "relationship", Value: 1},
+		primitive.E{Key: "city", Value: "paris"},
+	}
+	if err := users.AddEntry(context.Background(), doc); err != nil {
+		t.Fatalf("error when adding the user, :%v", err)
+	}
+	testtable := []struct {
+		update bson.D
+		check  func(u *types.Users) bool
+	}{
+		{update: bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "desc", Value: "hello"}}}}, check: func(u *types.Users) bool { return u.Desc == "hello" }},
+		{update: bson.D{primitive.E{Key: "$unset", Value: bson.D{primitive.E{Key: "city", Value: ""}}}}, check: func(u *types.Users) bool { return u.City == "" }},
+		{update: bson.D{primitive.E{Key: "$inc", Value: bson.D{primitive.E{Key: "relationship", Value: 2}}}}, check: func(u *types.Users) bool { return u.Relationship == 3 }},
+		{update: bson.D{primitive.E{Key: "$addToSet", Value: bson.D{primitive.E{Key: "roles", Value: bson.D{primitive.E{Key: "$each", Value: []types.Role{types.RoleUser, types.RoleAdmin}}}}}}}, check: func(u *types.Users) bool { return len(u.Roles) == 2 }},
+		{update: bson.D{primitive.E{Key: "$pull", Value: bson.D{primitive.E{Key: "roles", Value: types.RoleUser}}}}, check: func(u *types.Users) bool { return len(u.Roles) == 1 && u.Roles[0] == types.RoleAdmin }},
+		{update: bson.D{primitive.E{Key: "$push", Value: bson.D{primitive.E{Key: "recoveryCodes", Value: "a"}}}}, check: func(u *types.Users) bool { return len(u.RecoveryCodes) == 1 }},
+	}
+	for i, tt := range testtable {
+		if err := users.ModifyEntry(context.Background(), key, tt.update); err != nil {
+			t.Fatalf("case %d error when modifying, :%v", i, err)
+		}
+		got, err := users.GetEntry(context.Background(), key)
+		if err != nil {
+			t.Fatalf("case %d error when getting the user, :%v", i, err)
+		}
+		if !tt.check(got) {
+			t.Errorf("case %d update was not applied, got=%+v", i, got)
+		}
+	}
+	// a replacement document is not something the api does
+	if err := users.ModifyEntry(context.Background(), key, bson.D{primitive.E{Key: "desc", Value: "x"}}); err == nil {
+		t.Errorf("expected a error for a update without operators")
+	}
+}
+
+func TestMemoryUniqueIndexes(t *testing.T) {
+	users := NewMemoryUserModel(NewMemoryDatabase())
+	id := primitive.NewObjectID()
+	testtable := []struct {
+		doc       bson.D
+		duplicate bool
+	}{
+		{doc: bson.D{primitive.E{Key: "_id", Value: id}, primitive.E{Key: "username", Value: "a"}, primitive.E{Key: "email", Value: "a@a.com"}}},
+		{doc: bson.D{primitive.E{Key: "_id", Value: id}, primitive.E{Key: "username", Value: "b"}, primitive.E{Key: "email", Value: "b@b.com"}}, duplicate: true},
+		{doc: bson.D{primitive.E{Key: "_id", Value: primitive.NewObjectID()}, primitive.E{Key: "username", Value: "a"}, primitive.E{Key: "email", Value: "c@c.com"}}, duplicate: true},
+		{doc: bson.D{primitive.E{Key: "_id", Value: primitive.NewObjectID()}, primitive.E{Key: "username", Value: "d"}, primitive.E{Key: "email", Value: "d@d.com"}}},
+	}
+	for i, tt := range testtable {
+		err := users.AddEntry(context.Background(), tt.doc)
+		if mongo.IsDuplicateKeyError(err) != tt.duplicate {
+			t.Errorf("case %d wrong duplicate error, got=%v, want duplicate=%v", i, err, tt.duplicate)
+		}
+	}
+}
+
+func TestMemoryLikeFollow(t *testing.T) {
+	db := NewMemoryDatabase()
+	ids, alice, bob := memoryPosts(t, db)
+	posts := NewMemoryPostModel(db)
+	users := NewMemoryUserModel(db)
+	for _, id := range []primitive.ObjectID{alice, bob} {
+		doc := bson.D{primitive.E{Key: "_id", Value: id}, primitive.E{Key: "username", Value: id.Hex()}, primitive.E{Key: "email", Value: id.Hex() + "@a.com"}, primitive.E{Key: "followers", Value: bson.A{}}, primitive.E{Key: "followings", Value: bson.A{}}}
+		if err := users.AddEntry(context.Background(), doc); err != nil {
+			t.Fatalf("error when adding the user, :%v", err)
+		}
+	}
+	testtable := []struct {
+		op       func(context.Context, primitive.ObjectID, primitive.ObjectID) (bool, error)
+		a        primitive.ObjectID
+		b        primitive.ObjectID
+		expected bool
+		err      error
+	}{
+		{op: posts.Like, a: ids[0], b: bob, expected: true},
+		{op: posts.Like, a: ids[0], b: bob, expected: false},
+		{op: posts.Unlike, a: ids[0], b: bob, expected: true},
+		{op: posts.Unlike, a: ids[0], b: bob, expected: false},
+		{op: posts.Like, a: primitive.NewObjectID(), b: bob, err: mongo.ErrNoDocuments},
+		{op: users.Follow, a: alice, b: bob, expected: true},
+		{op: users.Follow, a: alice, b: bob, expected: false},
+		{op: users.Unfollow, a: alice, b: bob, expected: true},
+		{op: users.Follow, a: alice, b: primitive.NewObjectID(), err: mongo.ErrNoDocuments},
+	}
+	for i, tt := range testtable {
+		changed, err := tt.op(context.Background(), tt.a, tt.b)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("case %d wrong error, got=%v, want=%v", i, err, tt.err)
+		}
+		if changed != tt.expected {
+			t.Errorf("case %d wrong changed value, got=%v, want=%v", i, changed, tt.expected)
+		}
+	}
+	if _, err := users.Follow(context.Background(), bob, alice); err != nil {
+		t.Fatalf("error when following, :%v", err)
+	}
+	comments := NewMemoryCommentModel(db)
+	bobComment := primitive.NewObjectID()
+	keptComment := primitive.NewObjectID()
+	testComments := []bson.D{
+		// bobs comment and the reply to it go, so does alices comment on bobs post
+		{primitive.E{Key: "_id", Value: bobComment}, primitive.E{Key: "postId", Value: ids[0]}, primitive.E{Key: "userId", Value: bob}, primitive.E{Key: "ancestors", Value: bson.A{}}},
+		{primitive.E{Key: "_id", Value: primitive.NewObjectID()}, primitive.E{Key: "postId", Value: ids[0]}, primitive.E{Key: "userId", Value: alice}, primitive.E{Key: "parentId", Value: bobComment}, primitive.E{Key: "ancestors", Value: bson.A{bobComment}}},
+		{primitive.E{Key: "_id", Value: primitive.NewObjectID()}, primitive.E{Key: "postId", Value: ids[1]}, primitive.E{Key: "userId", Value: alice}, primitive.E{Key: "ancestors", Value: bson.A{}}},
+		{primitive.E{Key: "_id", Value: keptComment}, primitive.E{Key: "postId", Value: ids[0]}, primitive.E{Key: "userId", Value: alice}, primitive.E{Key: "ancestors", Value: bson.A{}}},
+	}
+	for _, comment := range testComments {
+		if err := comments.AddEntry(context.Background(), comment); err != nil {
+			t.Fatalf("error when adding the comment, :%v", err)
+		}
+	}
+	if err := users.RemoveAccount(context.Background(), bob); err != nil {
+		t.Fatalf("error when removing the account, :%v", err)
+	}
+	aliceUser, _ := users.GetEntry(context.Background(), bson.D{primitive.E{Key: "_id", Value: alice}})
+	if aliceUser == nil || len(aliceUser.Followers) != 0 {
+		t.Errorf("removed user is still a follower, got=%+v", aliceUser)
+	}
+	if count, _ := posts.Count(context.Background(), bson.D{primitive.E{Key: "userId", Value: bob}}); count != 0 {
+		t.Errorf("posts of the removed user were kept, got=%d", count)
+	}
+	left, _ := comments.GetEntryAdvanced(context.Background(), bson.D{}, nil)
+	if len(left) != 1 || left[0].CommentID != keptComment {
+		t.Errorf("wrong comments left after removing the account, got=%d comments", len(left))
+	}
+}
+
+func TestMemoryUseToken(t *testing.T) {
+	tokens := NewMemoryUserTokenModel(NewMemoryDatabase())
+	now := time.Now()
+	valid := types.NewUserToken(primitive.NewObjectID(), "a@a.com", types.VerifyEmailToken, time.Hour)
+	expired := types.NewUserToken(primitive.NewObjectID(), "a@a.com", types.VerifyEmailToken, -time.Hour)
+	for _, token := range []*types.UserTokens{valid, expired} {
+		doc := bson.D{
+			primitive.E{Key: "_id", Value: token.TokenID},
+			primitive.E{Key: "tokenHash", Value: token.TokenID.Hex()},
+			primitive.E{Key: "expires_at", Value: token.ExpiresAt},
+			primitive.E{Key: "used_at", Value: token.UsedAt},
+		}
+		if err := tokens.AddEntry(context.Background(), doc); err != nil {
+			t.Fatalf("error when adding the token, :%v", err)
+		}
+	}
+	testtable := []struct {
+		id       primitive.ObjectID
+		expected bool
+	}{
+		{id: valid.TokenID, expected: true},
+		{id: valid.TokenID, expected: false},
+		{id: expired.TokenID, expected: false},
+		{id: primitive.NewObjectID(), expected: false},
+	}
+	for i, tt := range testtable {
+		used, err := tokens.UseToken(context.Background(), tt.id, now)
+		if err != nil {
+			t.Fatalf("case %d error when using the token, :%v", i, err)
+		}
+		if used != tt.expected {
+			t.Errorf("case %d wrong used value, got=%v, want=%v", i, used, tt.expected)
+		}
+	}
+}
+
+func TestMemoryRotateToken(t *testing.T) {
+	sessions := NewMemorySessionModel(NewMemoryDatabase())
+	now := time.Now()
+	for _, session := range []bson.D{
+		{primitive.E{Key: "_id", Value: primitive.NewObjectID()}, primitive.E{Key: "tokenHash", Value: "a"}, primitive.E{Key: "expires_at", Value: now.Add(time.Hour)}},
+		{primitive.E{Key: "_id", Value: primitive.NewObjectID()}, primitive.E{Key: "tokenHash", Value: "expired"}, primitive.E{Key: "expires_at", Value: now.Add(-time.Hour)}},
+	} {
+		if err := sessions.AddEntry(context.Background(), session); err != nil {
+			t.Fatalf("error when adding the session, :%v", err)
+		}
+	}
+	testtable := []struct {
+		oldHash string
+		newHash string
+		err     error
+	}{
+		{oldHash: "a", newHash: "b"},
+		{oldHash: "a", newHash: "c", err: mongo.ErrNoDocuments},
+		{oldHash: "b", newHash: "c"},
+		{oldHash: "expired", newHash: "d", err: mongo.ErrNoDocuments},
+	}
+	for i, tt := range testtable {
+		set := bson.D{primitive.E{Key: "tokenHash", Value: tt.newHash}}
+		session, err := sessions.RotateToken(context.Background(), tt.oldHash, now, set)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("case %d wrong error, got=%v, want=%v", i, err, tt.err)
+		}
+		if err == nil && session.TokenHash != tt.newHash {
+			t.Errorf("case %d wrong token hash, got=%s, want=%s", i, session.TokenHash, tt.newHash)
+		}
+	}
+}
+
+func TestMemoryTransaction(t *testing.T) {
+	db := NewMemoryDatabase()
+	posts := NewMemoryPostModel(db)
+	failed := errors.New("second write failed")
+	err := db.Transaction(context.Background(), func(ctx context.Context) error {
+		if err := posts.AddEntry(ctx, bson.D{primitive.E{Key: "img", Value: "a.png"}}); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("wrong error, got=%v, want=%v", err, failed)
+	}
+	if count, _ := posts.Count(context.Background(), bson.D{}); count != 0 {
+		t.Errorf("write was not rolled back, got=%d posts", count)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := posts.GetEntry(ctx, bson.D{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("wrong error for a cancelled request, got=%v, want=%v", err, context.Canceled)
+	}
+}
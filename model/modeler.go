@@ -1,12 +1,83 @@
 package model
 
+import (
+	"context"
+	"social-api/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
 // Modeler is the interface that all database types will need to implement
 // the return values need to be a generic so we type assert them
+// every method takes the context of the request so the call is stopped
+// when the client goes away
 type Modeler[T any, V any] interface {
-	GetEntry(key V) (T, error)
-	// filter will have all the search parameters
-	GetEntryAdvanced(filter V, sort V) ([]T, error)
-	AddEntry(val V) error
-	RemoveEntry(val V) error
-	ModifyEntry(filter V, val V) error
+	GetEntry(ctx context.Context, key V) (T, error)
+	// filter will have all the search parameters, a empty slice is returned
+	// when nothing matches. opts can limit, skip and project the results
+	GetEntryAdvanced(ctx context.Context, filter V, sort V, opts ...QueryOption) ([]T, error)
+	Count(ctx context.Context, filter V) (int64, error)
+	AddEntry(ctx context.Context, val V) error
+	RemoveEntry(ctx context.Context, val V) error
+	ModifyEntry(ctx context.Context, filter V, val V) error
+}
+
+// PostStore is the post Modeler with the like operations, likes are added and
+// removed in one atomic update so likes at the same time dont overwrite each other.
+// the bool is false when the post was already in the asked for state
+type PostStore interface {
+	Modeler[*types.Posts, bson.D]
+	Like(ctx context.Context, postId primitive.ObjectID, userId primitive.ObjectID) (bool, error)
+	Unlike(ctx context.Context, postId primitive.ObjectID, userId primitive.ObjectID) (bool, error)
+}
+
+// UserStore is the user Modeler with the follow operations, userId is the
+// user doing the following. works the same way as the PostStore likes
+type UserStore interface {
+	Modeler[*types.Users, bson.D]
+	Follow(ctx context.Context, userId primitive.ObjectID, followId primitive.ObjectID) (bool, error)
+	Unfollow(ctx context.Context, userId primitive.ObjectID, followId primitive.ObjectID) (bool, error)
+	// deletes the user with everything that belongs to them
+	RemoveAccount(ctx context.Context, userId primitive.ObjectID) error
+	// take away the recovery code and save the totp step, false when the code
+	// or an equal or later step was already used by another request
+	UseRecoveryCode(ctx context.Context, userId primitive.ObjectID, hash string) (bool, error)
+	UseTOTPStep(ctx context.Context, userId primitive.ObjectID, step int64) (bool, error)
+}
+
+// SessionStore is the session Modeler with the refresh token rotation,
+// RotateToken returns mongo.ErrNoDocuments when the token was already used
+type SessionStore interface {
+	Modeler[*types.Sessions, bson.D]
+	RotateToken(ctx context.Context, oldHash string, now time.Time, set bson.D) (*types.Sessions, error)
+}
+
+// UserTokenStore is the token Modeler with the single use check, UseToken
+// is false when the token was used first by another request
+type UserTokenStore interface {
+	Modeler[*types.UserTokens, bson.D]
+	UseToken(ctx context.Context, tokenId primitive.ObjectID, usedAt time.Time) (bool, error)
+}
+
+// CommentStore is the comment Modeler with the deletes that take the replies
+// with them, a deleted comment never leaves replies without a parent
+type CommentStore interface {
+	Modeler[*types.Comments, bson.D]
+	RemoveThread(ctx context.Context, commentId primitive.ObjectID) error
+	RemovePostComments(ctx context.Context, postId primitive.ObjectID) error
+}
+
+// Timeouts are the longest a single database call can take, the call is
+// cancelled after this even if the request is still waiting on it
+type Timeouts struct {
+	Read  time.Duration
+	Write time.Duration
+}
+
+// timeouts used by every model, main sets these from the env at boot
+var OperationTimeouts = Timeouts{
+	Read:  5 * time.Second,
+	Write: 10 * time.Second,
 }
@@ -5,15 +5,23 @@ package model
 import (
 	"context"
 	"errors"
+	"social-api/database"
 	"social-api/types"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const postCollectionName string = "posts"
 
+// posts are listed by user newest first
+func init() {
+	database.RegisterIndexes(postCollectionName,
+		database.Index{Name: "userId_1_created_at_-1", Keys: bson.D{primitive.E{Key: "userId", Value: 1}, primitive.E{Key: "created_at", Value: -1}}},
+	)
+}
+
 //types here have to implement the  Modeler interface
 
 type PostModel struct {
@@ -22,56 +30,80 @@ type PostModel struct {
 
 // simple search when you need to get a entry without any filter options
 // will only return single entry
-func (pm *PostModel) GetEntry(key bson.D) (*types.Posts, error) {
+func (pm *PostModel) GetEntry(ctx context.Context, key bson.D) (*types.Posts, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
 	var entry types.Posts
-	err := pm.Collection.FindOne(context.TODO(), key).Decode(&entry)
+	err := pm.Collection.FindOne(ctx, key).Decode(&entry)
 	if err != nil {
 		return nil, err
 	}
 	return &entry, nil
 }
 
-func (pm *PostModel) GetEntryAdvanced(filter bson.D, sort bson.D) ([]*types.Posts, error) {
-	opts := options.Find().SetSort(sort)
-	cur, err := pm.Collection.Find(context.TODO(), filter, opts)
+func (pm *PostModel) GetEntryAdvanced(ctx context.Context, filter bson.D, sort bson.D, opts ...QueryOption) ([]*types.Posts, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	cur, err := pm.Collection.Find(ctx, filter, findOptions(sort, opts))
 	if err != nil {
 		return nil, err
 	}
-	var entrys []*types.Posts
-	if err = cur.All(context.TODO(), &entrys); err != nil {
+	entrys := []*types.Posts{}
+	if err = cur.All(ctx, &entrys); err != nil {
 		return nil, err
 	}
-	// gonna return a error if no data return for the given filters
-	if len(entrys) == 0 {
-		return nil, errors.New("no values found")
-	}
 	return entrys, nil
+}
 
+func (pm *PostModel) Count(ctx context.Context, filter bson.D) (int64, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	return pm.Collection.CountDocuments(ctx, filter)
 }
 
-func (pm *PostModel) AddEntry(val bson.D) error {
+func (pm *PostModel) AddEntry(ctx context.Context, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
 	if len(val) < 3 {
 		return errors.New("not enough values given to add post")
 	}
-	if _, err := pm.Collection.InsertOne(context.TODO(), val); err != nil {
+	if _, err := pm.Collection.InsertOne(ctx, val); err != nil {
 		return err
 	}
 	return nil
 
 }
-func (pm *PostModel) RemoveEntry(val bson.D) error {
-	if _, err := pm.Collection.DeleteOne(context.TODO(), val); err != nil {
+func (pm *PostModel) RemoveEntry(ctx context.Context, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	if _, err := pm.Collection.DeleteOne(ctx, val); err != nil {
 		return err
 	}
 	return nil
 }
-func (pm *PostModel) ModifyEntry(filter bson.D, val bson.D) error {
-	if _, err := pm.Collection.UpdateOne(context.TODO(), filter, val); err != nil {
+func (pm *PostModel) ModifyEntry(ctx context.Context, filter bson.D, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	if _, err := pm.Collection.UpdateOne(ctx, filter, val); err != nil {
 		return err
 	}
 	return nil
 }
 
+// adds the user to the likes of the post
+func (pm *PostModel) Like(ctx context.Context, postId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	return addMember(ctx, pm.Collection, postId, "likes", userId)
+}
+
+// takes the user out of the likes of the post
+func (pm *PostModel) Unlike(ctx context.Context, postId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	return removeMember(ctx, pm.Collection, postId, "likes", userId)
+}
+
 func NewPostModel(client *mongo.Database) *PostModel {
 	c := client.Collection(postCollectionName)
 	return &PostModel{
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"errors"
 	"os"
 	"social-api/database"
@@ -10,26 +11,45 @@ import (
 	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// references to other documents are stored as ObjectIDs
+func mustObjectID(hex string) primitive.ObjectID {
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// the mongo model tests need a live server so they only run with
+// E2E_MONGO=true like the end to end suite, the memory models are tested
+// without one
+func testDatabase(t *testing.T) *mongo.Database {
+	if os.Getenv("E2E_MONGO") != "true" {
+		t.Skip("needs a mongo server, set E2E_MONGO=true to run it")
+	}
+	// (the dot env doesnt work with test files)
+	godotenv.Load(".env")
+	client := database.ConnectDatabase(os.Getenv("MONGO_URL"), os.Getenv("DATABASE_NAME"))
+	if _, err := database.SyncIndexes(context.Background(), client); err != nil {
+		t.Fatalf("error when syncing the indexes, :%v", err)
+	}
+	return client
+}
+
 func TestPostGetEntry(t *testing.T) {
 	testtable := []struct {
 		input    bson.D
 		expected types.Posts
 	}{
-		{input: bson.D{primitive.E{Key: "img", Value: "image.png"}}, expected: types.Posts{UserID: "633483d5d284eb292ef26363", Image: "image.png", Likes: []string{}}},
+		{input: bson.D{primitive.E{Key: "img", Value: "image.png"}}, expected: types.Posts{UserID: mustObjectID("633483d5d284eb292ef26363"), Image: "image.png", Likes: []primitive.ObjectID{}}},
 	}
-	// (the dot env doesnt work with test files)
-	// need to replace the with the actual URI when testing
-	godotenv.Load(".env")
-	uri := os.Getenv("MONGO_URL")
-	db_name := os.Getenv("DATABASE_NAME")
-	client := database.ConnectDatabase(
-		uri,
-		db_name)
+	client := testDatabase(t)
 	userModel := NewPostModel(client)
 	for _, tt := range testtable {
-		gotPost, modelError := userModel.GetEntry(tt.input)
+		gotPost, modelError := userModel.GetEntry(context.Background(), tt.input)
 		if modelError != nil {
 			t.Fatalf("error when calling the GetEntry fucntion, :%v", modelError)
 		}
@@ -50,21 +70,14 @@ func TestPostAddEntry(t *testing.T) {
 		input    bson.D
 		expected error
 	}{
-		{input: bson.D{primitive.E{Key: "_id", Value: primitive.NewObjectID()}, primitive.E{Key: "userId", Value: "63348350d284eb292ef2635f"}, primitive.E{Key: "img", Value: "image1.png"}, primitive.E{Key: "likes", Value: []string{}}}, expected: nil},
-		{input: bson.D{primitive.E{Key: "_id", Value: primitive.NewObjectID()}, primitive.E{Key: "userId", Value: "63348350d284eb292ef2635f"}, primitive.E{Key: "img", Value: "image2.png"}, primitive.E{Key: "likes", Value: []string{}}}, expected: nil},
-		{input: bson.D{primitive.E{Key: "img", Value: "image2.png"}, primitive.E{Key: "likes", Value: []string{}}}, expected: errors.New("not enough values given to add user")},
+		{input: bson.D{primitive.E{Key: "_id", Value: primitive.NewObjectID()}, primitive.E{Key: "userId", Value: mustObjectID("63348350d284eb292ef2635f")}, primitive.E{Key: "img", Value: "image1.png"}, primitive.E{Key: "likes", Value: []primitive.ObjectID{}}}, expected: nil},
+		{input: bson.D{primitive.E{Key: "_id", Value: primitive.NewObjectID()}, primitive.E{Key: "userId", Value: mustObjectID("63348350d284eb292ef2635f")}, primitive.E{Key: "img", Value: "image2.png"}, primitive.E{Key: "likes", Value: []primitive.ObjectID{}}}, expected: nil},
+		{input: bson.D{primitive.E{Key: "img", Value: "image2.png"}, primitive.E{Key: "likes", Value: []primitive.ObjectID{}}}, expected: errors.New("not enough values given to add user")},
 	}
-	// (the dot env doesnt work with test files)
-	// need to replace the with the actual URI when testing
-	godotenv.Load(".env")
-	uri := os.Getenv("MONGO_URL")
-	db_name := os.Getenv("DATABASE_NAME")
-	client := database.ConnectDatabase(
-		uri,
-		db_name)
+	client := testDatabase(t)
 	postModel := NewPostModel(client)
 	for _, tt := range testtable {
-		err := postModel.AddEntry(tt.input)
+		err := postModel.AddEntry(context.Background(), tt.input)
 		if err != nil {
 			if err.Error() != tt.expected.Error() {
 				t.Errorf("wrong error when inserting, got=%s, want=%s", err.Error(), tt.expected.Error())
@@ -81,14 +94,7 @@ func TestPostModifyEntry(t *testing.T) {
 	}{
 		{idString: "64dcfc7fe38b735c64135796", inputVal: bson.D{primitive.E{Key: "$set", Value: bson.D{{Key: "img", Value: "testimage23.png"}}}}, expected: nil},
 	}
-	// (the dot env doesnt work with test files)
-	// need to replace the with the actual URI when testing
-	godotenv.Load(".env")
-	uri := os.Getenv("MONGO_URL")
-	db_name := os.Getenv("DATABASE_NAME")
-	client := database.ConnectDatabase(
-		uri,
-		db_name)
+	client := testDatabase(t)
 	userModel := NewUserModel(client)
 	for _, tt := range testtable {
 		id, hexError := primitive.ObjectIDFromHex(tt.idString)
@@ -97,7 +103,7 @@ func TestPostModifyEntry(t *testing.T) {
 		}
 
 		filter := bson.D{primitive.E{Key: "_id", Value: id}}
-		err := userModel.ModifyEntry(filter, tt.inputVal)
+		err := userModel.ModifyEntry(context.Background(), filter, tt.inputVal)
 		if err != nil {
 			if err.Error() != tt.expected.Error() {
 				t.Errorf("wrong error when updating to model, got=%s, want=%s", err.Error(), tt.expected.Error())
@@ -113,24 +119,17 @@ func TestPostRemoveEntry(t *testing.T) {
 		expected error
 	}{
 		{idString: "64dcfc7fe38b735c64135796", expected: nil},
-		{idString: "", input: bson.D{primitive.E{Key: "userId", Value: "63348350d284eb292ef2635f"}}, expected: nil},
+		{idString: "", input: bson.D{primitive.E{Key: "userId", Value: mustObjectID("63348350d284eb292ef2635f")}}, expected: nil},
 		{idString: "", input: bson.D{}, expected: errors.New("empty val value given")},
 	}
-	// (the dot env doesnt work with test files)
-	// need to replace the with the actual URI when testing
-	godotenv.Load(".env")
-	uri := os.Getenv("MONGO_URL")
-	db_name := os.Getenv("DATABASE_NAME")
-	client := database.ConnectDatabase(
-		uri,
-		db_name)
+	client := testDatabase(t)
 	postModel := NewPostModel(client)
 	for _, tt := range testtable {
 		if tt.idString != "" {
 			id, _ := primitive.ObjectIDFromHex(tt.idString)
 			tt.input = bson.D{primitive.E{Key: "_id", Value: id}}
 		}
-		err := postModel.RemoveEntry(tt.input)
+		err := postModel.RemoveEntry(context.Background(), tt.input)
 		if err != nil {
 			if err.Error() != tt.expected.Error() {
 				t.Errorf("wrong error when removing from the model, got=%s, want=%s", err.Error(), tt.expected.Error())
@@ -144,21 +143,19 @@ func TestPostGetEntryAdvanced(t *testing.T) {
 	testtable := []struct {
 		input    bson.D
 		sort     bson.D
+		opts     []QueryOption
 		expected int
 	}{
 		{input: bson.D{primitive.E{Key: "img", Value: "image1.png"}}, sort: bson.D{primitive.E{Key: "_id", Value: 1}}, expected: 5},
+		{input: bson.D{primitive.E{Key: "img", Value: "image1.png"}}, sort: bson.D{primitive.E{Key: "_id", Value: 1}}, opts: []QueryOption{Limit(2)}, expected: 2},
+		{input: bson.D{primitive.E{Key: "img", Value: "image1.png"}}, sort: bson.D{primitive.E{Key: "_id", Value: 1}}, opts: []QueryOption{Skip(4)}, expected: 1},
+		// nothing matching is a empty list and not a error
+		{input: bson.D{primitive.E{Key: "img", Value: "not-a-real-image.png"}}, sort: bson.D{primitive.E{Key: "_id", Value: 1}}, expected: 0},
 	}
-	// (the dot env doesnt work with test files)
-	// need to replace the with the actual URI when testing
-	godotenv.Load(".env")
-	uri := os.Getenv("MONGO_URL")
-	db_name := os.Getenv("DATABASE_NAME")
-	client := database.ConnectDatabase(
-		uri,
-		db_name)
+	client := testDatabase(t)
 	userModel := NewPostModel(client)
 	for _, tt := range testtable {
-		gotPostArray, modelError := userModel.GetEntryAdvanced(tt.input, tt.sort)
+		gotPostArray, modelError := userModel.GetEntryAdvanced(context.Background(), tt.input, tt.sort, tt.opts...)
 		if modelError != nil {
 			t.Fatalf("error when calling the GetEntry fucntion, :%v", modelError)
 		}
@@ -167,3 +164,66 @@ func TestPostGetEntryAdvanced(t *testing.T) {
 		}
 	}
 }
+
+func TestPostLikeUnlike(t *testing.T) {
+	client := testDatabase(t)
+	postModel := NewPostModel(client)
+	postId := primitive.NewObjectID()
+	userId := primitive.NewObjectID()
+	post := bson.D{primitive.E{Key: "_id", Value: postId}, primitive.E{Key: "userId", Value: userId}, primitive.E{Key: "img", Value: "like.png"}, primitive.E{Key: "likes", Value: []primitive.ObjectID{}}}
+	if err := postModel.AddEntry(context.Background(), post); err != nil {
+		t.Fatalf("error when adding the post, :%v", err)
+	}
+	defer postModel.RemoveEntry(context.Background(), bson.D{primitive.E{Key: "_id", Value: postId}})
+	testtable := []struct {
+		op       func(context.Context, primitive.ObjectID, primitive.ObjectID) (bool, error)
+		postId   primitive.ObjectID
+		expected bool
+		likes    int
+		err      error
+	}{
+		{op: postModel.Like, postId: postId, expected: true, likes: 1},
+		// liking twice doesnt add the user again
+		{op: postModel.Like, postId: postId, expected: false, likes: 1},
+		{op: postModel.Unlike, postId: postId, expected: true, likes: 0},
+		{op: postModel.Unlike, postId: postId, expected: false, likes: 0},
+		{op: postModel.Like, postId: primitive.NewObjectID(), expected: false, likes: 0, err: mongo.ErrNoDocuments},
+	}
+	for _, tt := range testtable {
+		changed, err := tt.op(context.Background(), tt.postId, userId)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("wrong error, got=%v, want=%v", err, tt.err)
+		}
+		if changed != tt.expected {
+			t.Errorf("wrong changed value, got=%v, want=%v", changed, tt.expected)
+		}
+		gotPost, modelError := postModel.GetEntry(context.Background(), bson.D{primitive.E{Key: "_id", Value: postId}})
+		if modelError != nil {
+			t.Fatalf("error when calling the GetEntry fucntion, :%v", modelError)
+		}
+		if len(gotPost.Likes) != tt.likes {
+			t.Errorf("wrong number of likes, got=%d, want=%d", len(gotPost.Likes), tt.likes)
+		}
+	}
+}
+
+func TestPostCount(t *testing.T) {
+	testtable := []struct {
+		input    bson.D
+		expected int64
+	}{
+		{input: bson.D{primitive.E{Key: "img", Value: "image1.png"}}, expected: 5},
+		{input: bson.D{primitive.E{Key: "img", Value: "not-a-real-image.png"}}, expected: 0},
+	}
+	client := testDatabase(t)
+	postModel := NewPostModel(client)
+	for _, tt := range testtable {
+		count, modelError := postModel.Count(context.Background(), tt.input)
+		if modelError != nil {
+			t.Fatalf("error when calling the Count fucntion, :%v", modelError)
+		}
+		if count != tt.expected {
+			t.Errorf("wrong count, got=%d, want=%d", count, tt.expected)
+		}
+	}
+}
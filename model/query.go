@@ -0,0 +1,61 @@
+package model
+
+import (
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// QueryOptions are the extra settings for GetEntryAdvanced, the zero value
+// returns every match with all of its fields
+type QueryOptions struct {
+	// most entries to return, 0 means no limit
+	Limit int64
+	// how many matches to skip before the first one returned
+	Skip int64
+	// fields to return, like bson.D{{"username", 1}}, nil returns every field
+	Projection any
+}
+
+type QueryOption func(*QueryOptions)
+
+func Limit(n int64) QueryOption {
+	return func(q *QueryOptions) {
+		q.Limit = n
+	}
+}
+
+func Skip(n int64) QueryOption {
+	return func(q *QueryOptions) {
+		q.Skip = n
+	}
+}
+
+// only the fields in the projection are loaded, the rest are left as zero values
+func Project(fields any) QueryOption {
+	return func(q *QueryOptions) {
+		q.Projection = fields
+	}
+}
+
+// applies the options in order, later ones win
+func NewQueryOptions(opts ...QueryOption) QueryOptions {
+	var q QueryOptions
+	for _, opt := range opts {
+		opt(&q)
+	}
+	return q
+}
+
+func findOptions(sort any, opts []QueryOption) *options.FindOptions {
+	q := NewQueryOptions(opts...)
+	find := options.Find().SetSort(sort)
+	if q.Limit > 0 {
+		find.SetLimit(q.Limit)
+	}
+	if q.Skip > 0 {
+		find.SetSkip(q.Skip)
+	}
+	if q.Projection != nil {
+		find.SetProjection(q.Projection)
+	}
+	return find
+}
@@ -0,0 +1,140 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"social-api/database"
+	"social-api/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const sessionCollectionName string = "sessions"
+
+// mongo deletes sessions once they expire
+func init() {
+	database.RegisterIndexes(sessionCollectionName,
+		database.Index{Name: "tokenHash_1", Keys: bson.D{primitive.E{Key: "tokenHash", Value: 1}}, Unique: true},
+		database.Index{Name: "userId_1", Keys: bson.D{primitive.E{Key: "userId", Value: 1}}},
+		database.Index{Name: "expires_at_1", Keys: bson.D{primitive.E{Key: "expires_at", Value: 1}}, TTL: true},
+	)
+}
+
+//types here have to implement the  Modeler interface
+
+type SessionModel struct {
+	Collection *mongo.Collection
+}
+
+// simple search when you need to get a entry without any filter options
+// will only return single entry
+func (sm *SessionModel) GetEntry(ctx context.Context, key bson.D) (*types.Sessions, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	var entry types.Sessions
+	// bson.d is a drivitive of primitive int so cannont be
+	// nil, so just check the length of the key
+	if len(key) == 0 {
+		return nil, errors.New("empty filter given")
+	}
+	err := sm.Collection.FindOne(ctx, key).Decode(&entry)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (sm *SessionModel) GetEntryAdvanced(ctx context.Context, filter bson.D, sort bson.D, opts ...QueryOption) ([]*types.Sessions, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	cur, err := sm.Collection.Find(ctx, filter, findOptions(sort, opts))
+	if err != nil {
+		return nil, err
+	}
+	entrys := []*types.Sessions{}
+	if err = cur.All(ctx, &entrys); err != nil {
+		return nil, err
+	}
+	return entrys, nil
+}
+
+func (sm *SessionModel) Count(ctx context.Context, filter bson.D) (int64, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	return sm.Collection.CountDocuments(ctx, filter)
+}
+
+func (sm *SessionModel) AddEntry(ctx context.Context, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	if len(val) <= 2 {
+		return errors.New("not enough values given to add session")
+	}
+	if _, err := sm.Collection.InsertOne(ctx, val); err != nil {
+		return err
+	}
+	return nil
+
+}
+func (sm *SessionModel) RemoveEntry(ctx context.Context, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	if len(val) == 0 {
+		return errors.New("empty val value given")
+	}
+	if _, err := sm.Collection.DeleteOne(ctx, val); err != nil {
+		return err
+	}
+	return nil
+}
+func (sm *SessionModel) ModifyEntry(ctx context.Context, filter bson.D, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	if len(filter) == 0 {
+		return errors.New("empty filter value given")
+	}
+	if len(val) == 0 {
+		return errors.New("no empty update value given")
+	}
+	if _, err := sm.Collection.UpdateOne(ctx, filter, val); err != nil {
+		return err
+	}
+	return nil
+}
+
+// swaps the refresh token hash of the session for the new one in set, it
+// only matches while the old hash is still there and the session hasnt
+// expired so the same refresh token cant be used twice. returns
+// mongo.ErrNoDocuments if nothing matched
+func (sm *SessionModel) RotateToken(ctx context.Context, oldHash string, now time.Time, set bson.D) (*types.Sessions, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	filter, update := rotateTokenUpdate(oldHash, now, set)
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var entry types.Sessions
+	if err := sm.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// the filter and update of RotateToken, the memory model uses them as well
+func rotateTokenUpdate(oldHash string, now time.Time, set bson.D) (bson.D, bson.D) {
+	filter := bson.D{
+		primitive.E{Key: "tokenHash", Value: oldHash},
+		primitive.E{Key: "expires_at", Value: bson.D{primitive.E{Key: "$gt", Value: now}}},
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: set}}
+	return filter, update
+}
+
+func NewSessionModel(client *mongo.Database) *SessionModel {
+	c := client.Collection(sessionCollectionName)
+	return &SessionModel{
+		Collection: c,
+	}
+}
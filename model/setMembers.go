@@ -0,0 +1,69 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// adds member to the array field of the document with the id, the filter only
+// matches when the member is missing so two calls at once cant both add it.
+// returns false if the member was already there, mongo.ErrNoDocuments if the
+// document doesnt exist
+func addMember(ctx context.Context, c *mongo.Collection, id primitive.ObjectID, field string, member primitive.ObjectID) (bool, error) {
+	filter, update := addMemberUpdate(id, field, member)
+	return updateMembers(ctx, c, id, filter, update)
+}
+
+// opposite of addMember, returns false if the member wasnt in the array
+func removeMember(ctx context.Context, c *mongo.Collection, id primitive.ObjectID, field string, member primitive.ObjectID) (bool, error) {
+	filter, update := removeMemberUpdate(id, field, member)
+	return updateMembers(ctx, c, id, filter, update)
+}
+
+// the filter and update of addMember, the memory models use them as well
+func addMemberUpdate(id primitive.ObjectID, field string, member primitive.ObjectID) (bson.D, bson.D) {
+	filter := bson.D{
+		primitive.E{Key: "_id", Value: id},
+		primitive.E{Key: field, Value: bson.D{primitive.E{Key: "$ne", Value: member}}},
+	}
+	update := bson.D{
+		primitive.E{Key: "$addToSet", Value: bson.D{primitive.E{Key: field, Value: member}}},
+		primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "updated_at", Value: time.Now()}}},
+	}
+	return filter, update
+}
+
+func removeMemberUpdate(id primitive.ObjectID, field string, member primitive.ObjectID) (bson.D, bson.D) {
+	filter := bson.D{
+		primitive.E{Key: "_id", Value: id},
+		primitive.E{Key: field, Value: member},
+	}
+	update := bson.D{
+		primitive.E{Key: "$pull", Value: bson.D{primitive.E{Key: field, Value: member}}},
+		primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "updated_at", Value: time.Now()}}},
+	}
+	return filter, update
+}
+
+func updateMembers(ctx context.Context, c *mongo.Collection, id primitive.ObjectID, filter bson.D, update bson.D) (bool, error) {
+	result, err := c.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	if result.MatchedCount == 1 {
+		return true, nil
+	}
+	// nothing matched, either there was nothing to change or the id is wrong
+	count, err := c.CountDocuments(ctx, bson.D{primitive.E{Key: "_id", Value: id}})
+	if err != nil {
+		return false, err
+	}
+	if count == 0 {
+		return false, mongo.ErrNoDocuments
+	}
+	return false, nil
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"context"
+	"social-api/database"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Transactor runs writes that touch more than one document as one unit of
+// work, the model calls in fn have to use the ctx fn is given
+type Transactor interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type MongoTransactor struct {
+	db *mongo.Database
+}
+
+func (mt *MongoTransactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return database.Transaction(ctx, mt.db, fn)
+}
+
+func NewTransactor(client *mongo.Database) *MongoTransactor {
+	return &MongoTransactor{
+		db: client,
+	}
+}
@@ -3,15 +3,24 @@ package model
 import (
 	"context"
 	"errors"
+	"social-api/database"
 	"social-api/types"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const userCollectionName string = "users"
 
+// usernames and emails are looked up on login and have to be unique
+func init() {
+	database.RegisterIndexes(userCollectionName,
+		database.Index{Name: "username_1", Keys: bson.D{primitive.E{Key: "username", Value: 1}}, Unique: true},
+		database.Index{Name: "email_1", Keys: bson.D{primitive.E{Key: "email", Value: 1}}, Unique: true},
+	)
+}
+
 //types here have to implement the  Modeler interface
 
 type UserModel struct {
@@ -20,70 +29,252 @@ type UserModel struct {
 
 // simple search when you need to get a entry without any filter options
 // will only return single entry
-func (um *UserModel) GetEntry(key bson.D) (*types.Users, error) {
+func (um *UserModel) GetEntry(ctx context.Context, key bson.D) (*types.Users, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
 	var entry types.Users
 	// bson.d is a drivitive of primitive int so cannont be
 	// nil, so just check the length of the key
 	if len(key) == 0 {
 		return nil, errors.New("empty filter given")
 	}
-	err := um.Collection.FindOne(context.TODO(), key).Decode(&entry)
+	err := um.Collection.FindOne(ctx, key).Decode(&entry)
 	if err != nil {
 		return nil, err
 	}
 	return &entry, nil
 }
 
-func (um *UserModel) GetEntryAdvanced(filter bson.D, sort bson.D) ([]*types.Users, error) {
-	opts := options.Find().SetSort(sort)
-	cur, err := um.Collection.Find(context.TODO(), filter, opts)
+func (um *UserModel) GetEntryAdvanced(ctx context.Context, filter bson.D, sort bson.D, opts ...QueryOption) ([]*types.Users, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	cur, err := um.Collection.Find(ctx, filter, findOptions(sort, opts))
 	if err != nil {
 		return nil, err
 	}
-	var entrys []*types.Users
-	if err = cur.All(context.TODO(), &entrys); err != nil {
+	entrys := []*types.Users{}
+	if err = cur.All(ctx, &entrys); err != nil {
 		return nil, err
 	}
-	// gonna return a error if no data return for the given filters
-	if len(entrys) == 0 {
-		return nil, errors.New("no values found")
-	}
 	return entrys, nil
+}
 
+func (um *UserModel) Count(ctx context.Context, filter bson.D) (int64, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	return um.Collection.CountDocuments(ctx, filter)
 }
 
-func (um *UserModel) AddEntry(val bson.D) error {
+func (um *UserModel) AddEntry(ctx context.Context, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
 	if len(val) <= 2 {
 		return errors.New("not enough values given to add user")
 	}
-	if _, err := um.Collection.InsertOne(context.TODO(), val); err != nil {
+	if _, err := um.Collection.InsertOne(ctx, val); err != nil {
 		return err
 	}
 	return nil
 
 }
-func (um *UserModel) RemoveEntry(val bson.D) error {
+func (um *UserModel) RemoveEntry(ctx context.Context, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
 	if len(val) == 0 {
 		return errors.New("empty val value given")
 	}
-	if _, err := um.Collection.DeleteOne(context.TODO(), val); err != nil {
+	if _, err := um.Collection.DeleteOne(ctx, val); err != nil {
 		return err
 	}
 	return nil
 }
-func (um *UserModel) ModifyEntry(filter bson.D, val bson.D) error {
+func (um *UserModel) ModifyEntry(ctx context.Context, filter bson.D, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
 	if len(filter) == 0 {
 		return errors.New("empty filter value given")
 	}
 	if len(val) == 0 {
 		return errors.New("no empty update value given")
 	}
-	if _, err := um.Collection.UpdateOne(context.TODO(), filter, val); err != nil {
+	if _, err := um.Collection.UpdateOne(ctx, filter, val); err != nil {
 		return err
 	}
 	return nil
 }
 
+// adds userId to the followers of followId and followId to the followings of
+// userId, both users are changed in one transaction. the followings are always
+// updated so a half done follow from a standalone server gets fixed by
+// calling this again
+func (um *UserModel) Follow(ctx context.Context, userId primitive.ObjectID, followId primitive.ObjectID) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	var changed bool
+	err := database.Transaction(ctx, um.Collection.Database(), func(ctx context.Context) error {
+		var err error
+		if changed, err = addMember(ctx, um.Collection, followId, "followers", userId); err != nil {
+			return err
+		}
+		_, err = addMember(ctx, um.Collection, userId, "followings", followId)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+// opposite of Follow
+func (um *UserModel) Unfollow(ctx context.Context, userId primitive.ObjectID, followId primitive.ObjectID) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	var changed bool
+	err := database.Transaction(ctx, um.Collection.Database(), func(ctx context.Context) error {
+		var err error
+		if changed, err = removeMember(ctx, um.Collection, followId, "followers", userId); err != nil {
+			return err
+		}
+		_, err = removeMember(ctx, um.Collection, userId, "followings", followId)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+// pulls the recovery code, it only matches while the code is still there so
+// two requests cant both use it
+func (um *UserModel) UseRecoveryCode(ctx context.Context, userId primitive.ObjectID, hash string) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	filter, update := recoveryCodeUpdate(userId, hash)
+	return um.updateOnce(ctx, filter, update)
+}
+
+// saves the step of the totp code, it only matches if the step is newer
+// than the last one so a code cant be used twice
+func (um *UserModel) UseTOTPStep(ctx context.Context, userId primitive.ObjectID, step int64) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	filter, update := totpStepUpdate(userId, step)
+	return um.updateOnce(ctx, filter, update)
+}
+
+func (um *UserModel) updateOnce(ctx context.Context, filter bson.D, update bson.D) (bool, error) {
+	result, err := um.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// the filters and updates of UseRecoveryCode and UseTOTPStep, the memory
+// model uses them as well
+func recoveryCodeUpdate(userId primitive.ObjectID, hash string) (bson.D, bson.D) {
+	filter := bson.D{
+		primitive.E{Key: "_id", Value: userId},
+		primitive.E{Key: "recoveryCodes", Value: hash},
+	}
+	update := bson.D{primitive.E{Key: "$pull", Value: bson.D{primitive.E{Key: "recoveryCodes", Value: hash}}}}
+	return filter, update
+}
+
+func totpStepUpdate(userId primitive.ObjectID, step int64) (bson.D, bson.D) {
+	filter := bson.D{
+		primitive.E{Key: "_id", Value: userId},
+		// users from before the field was added dont have it yet
+		primitive.E{Key: "$or", Value: bson.A{
+			bson.D{primitive.E{Key: "totpLastStep", Value: bson.D{primitive.E{Key: "$lt", Value: step}}}},
+			bson.D{primitive.E{Key: "totpLastStep", Value: bson.D{primitive.E{Key: "$exists", Value: false}}}},
+		}},
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "totpLastStep", Value: step}}}}
+	return filter, update
+}
+
+// deletes the user and everything that belongs to them in one transaction,
+// their posts, comments, sessions, tokens and api keys go (with the comments
+// on their posts and the replies to their comments) and they are taken out
+// of the follows and likes of everyone else. the audit log is kept
+func (um *UserModel) RemoveAccount(ctx context.Context, userId primitive.ObjectID) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	db := um.Collection.Database()
+	owned := bson.D{primitive.E{Key: "userId", Value: userId}}
+	return database.Transaction(ctx, db, func(ctx context.Context) error {
+		result, err := um.Collection.DeleteOne(ctx, bson.D{primitive.E{Key: "_id", Value: userId}})
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
+		followsFilter, pullFollows := pullFollowsUpdate(userId)
+		if _, err := um.Collection.UpdateMany(ctx, followsFilter, pullFollows); err != nil {
+			return err
+		}
+		posts := db.Collection(postCollectionName)
+		comments := db.Collection(commentCollectionName)
+		// the ids are needed before the posts and comments are gone
+		postIds, err := distinctIDs(ctx, posts, owned)
+		if err != nil {
+			return err
+		}
+		commentIds, err := distinctIDs(ctx, comments, owned)
+		if err != nil {
+			return err
+		}
+		if _, err := comments.DeleteMany(ctx, accountCommentsFilter(userId, postIds, commentIds)); err != nil {
+			return err
+		}
+		if _, err := posts.DeleteMany(ctx, owned); err != nil {
+			return err
+		}
+		pullLikes := bson.D{primitive.E{Key: "$pull", Value: bson.D{primitive.E{Key: "likes", Value: userId}}}}
+		if _, err := posts.UpdateMany(ctx, bson.D{primitive.E{Key: "likes", Value: userId}}, pullLikes); err != nil {
+			return err
+		}
+		for _, name := range []string{sessionCollectionName, userTokenCollectionName, apiKeyCollectionName} {
+			if _, err := db.Collection(name).DeleteMany(ctx, owned); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// the filter and update that take the user out of the follows of everyone
+// else, only the users that follow them or are followed by them are touched.
+// the memory model uses them as well
+func pullFollowsUpdate(userId primitive.ObjectID) (bson.D, bson.D) {
+	filter := bson.D{primitive.E{Key: "$or", Value: bson.A{
+		bson.D{primitive.E{Key: "followers", Value: userId}},
+		bson.D{primitive.E{Key: "followings", Value: userId}},
+	}}}
+	update := bson.D{primitive.E{Key: "$pull", Value: bson.D{
+		primitive.E{Key: "followers", Value: userId},
+		primitive.E{Key: "followings", Value: userId},
+	}}}
+	return filter, update
+}
+
+// the ids of the documents that match the filter
+func distinctIDs(ctx context.Context, c *mongo.Collection, filter bson.D) ([]primitive.ObjectID, error) {
+	values, err := c.Distinct(ctx, "_id", filter)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(values))
+	for _, value := range values {
+		if id, ok := value.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
 func NewUserModel(client *mongo.Database) *UserModel {
 	c := client.Collection(userCollectionName)
 	return &UserModel{
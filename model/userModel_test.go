@@ -1,15 +1,14 @@
 package model
 
 import (
+	"context"
 	"errors"
-	"os"
-	"social-api/database"
 	"social-api/types"
 	"testing"
 
-	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func TestUserGetEntry(t *testing.T) {
@@ -18,24 +17,17 @@ func TestUserGetEntry(t *testing.T) {
 		input    bson.D
 		expected types.Users
 	}{
-		{userId: "", input: bson.D{primitive.E{Key: "username", Value: "bob"}}, expected: types.Users{Username: "bob", Email: "bob@gmail.com", Password: "$2b$10$t4UkW8gp83Mmk2O8IXgKseOrvH8Eg2SaYaU4Az5rRWrAVq8B5KdfW", ProfilePic: "", CoverPic: "", Follwers: []string{"testUser"}, Follwings: []string{"testUser"}, IsAdmin: false}},
-		{userId: "633356b45715fd08fc68798e", expected: types.Users{Username: "gabe", Email: "gabe@gmail.com", Password: "$2b$10$t4UkW8gp83Mmk2O8IXgKseOrvH8Eg2SaYaU4Az5rRWrAVq8B5KdfW", ProfilePic: "", CoverPic: "", Follwers: []string{"testUser"}, Follwings: []string{}, IsAdmin: false}},
-	}
-	// (the dot env doesnt work with test files)
-	// need to replace the with the actual URI when testing
-	godotenv.Load(".env")
-	uri := os.Getenv("MONGO_URL")
-	db_name := os.Getenv("DATABASE_NAME")
-	client := database.ConnectDatabase(
-		uri,
-		db_name)
+		{userId: "", input: bson.D{primitive.E{Key: "username", Value: "bob"}}, expected: types.Users{Username: "bob", Email: "bob@gmail.com", Password: "$2b$10$t4UkW8gp83Mmk2O8IXgKseOrvH8Eg2SaYaU4Az5rRWrAVq8B5KdfW", ProfilePic: "", CoverPic: "", Followers: []primitive.ObjectID{primitive.NewObjectID()}, Followings: []primitive.ObjectID{primitive.NewObjectID()}}},
+		{userId: "633356b45715fd08fc68798e", expected: types.Users{Username: "gabe", Email: "gabe@gmail.com", Password: "$2b$10$t4UkW8gp83Mmk2O8IXgKseOrvH8Eg2SaYaU4Az5rRWrAVq8B5KdfW", ProfilePic: "", CoverPic: "", Followers: []primitive.ObjectID{primitive.NewObjectID()}, Followings: []primitive.ObjectID{}}},
+	}
+	client := testDatabase(t)
 	userModel := NewUserModel(client)
 	for _, tt := range testtable {
 		if tt.userId != "" {
 			id, _ := primitive.ObjectIDFromHex(tt.userId)
 			tt.input = bson.D{primitive.E{Key: "_id", Value: id}}
 		}
-		gotUser, modelError := userModel.GetEntry(tt.input)
+		gotUser, modelError := userModel.GetEntry(context.Background(), tt.input)
 		if modelError != nil {
 			t.Fatalf("error when calling the GetEntry fucntion, :%v", modelError)
 		}
@@ -45,11 +37,11 @@ func TestUserGetEntry(t *testing.T) {
 		if gotUser.Email != tt.expected.Email {
 			t.Errorf("wrong email, got=%s, want=%s", gotUser.Email, tt.expected.Email)
 		}
-		if len(gotUser.Follwers) != len(tt.expected.Follwers) {
-			t.Errorf("wrong number of followers, got=%d, want=%d", len(gotUser.Follwers), len(tt.expected.Follwers))
+		if len(gotUser.Followers) != len(tt.expected.Followers) {
+			t.Errorf("wrong number of followers, got=%d, want=%d", len(gotUser.Followers), len(tt.expected.Followers))
 		}
-		if len(gotUser.Follwings) != len(tt.expected.Follwings) {
-			t.Errorf("wrong number of following, got=%d, want=%d", len(gotUser.Follwings), len(tt.expected.Follwings))
+		if len(gotUser.Followings) != len(tt.expected.Followings) {
+			t.Errorf("wrong number of following, got=%d, want=%d", len(gotUser.Followings), len(tt.expected.Followings))
 		}
 	}
 
@@ -65,17 +57,10 @@ func TestUserAddEntry(t *testing.T) {
 		{input: bson.D{primitive.E{Key: "_id", Value: primitive.NewObjectID()}, primitive.E{Key: "username", Value: "gilson"}, primitive.E{Key: "email", Value: "gilson@gmail.com"}, primitive.E{Key: "password", Value: "gilsonpassword"}}, expected: nil},
 		{input: bson.D{primitive.E{Key: "password", Value: "failpassword"}}, expected: errors.New("not enough values given to add user")},
 	}
-	// (the dot env doesnt work with test files)
-	// need to replace the with the actual URI when testing
-	godotenv.Load(".env")
-	uri := os.Getenv("MONGO_URL")
-	db_name := os.Getenv("DATABASE_NAME")
-	client := database.ConnectDatabase(
-		uri,
-		db_name)
+	client := testDatabase(t)
 	userModel := NewUserModel(client)
 	for _, tt := range testtable {
-		err := userModel.AddEntry(tt.input)
+		err := userModel.AddEntry(context.Background(), tt.input)
 		if err != nil {
 			if err.Error() != tt.expected.Error() {
 				t.Errorf("wrong error when adding to model, got=%s, want=%s", err.Error(), tt.expected.Error())
@@ -95,17 +80,10 @@ func TestUserModifyEntry(t *testing.T) {
 		{inputFilter: bson.D{primitive.E{Key: "username", Value: "gilson"}}, inputVal: bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "password", Value: "gilsonPassword2"}}}}, expected: nil},
 		{inputFilter: bson.D{primitive.E{Key: "username", Value: "gilson"}}, inputVal: bson.D{{}}, expected: errors.New("no empty update value given")},
 	}
-	// (the dot env doesnt work with test files)
-	// need to replace the with the actual URI when testing
-	godotenv.Load(".env")
-	uri := os.Getenv("MONGO_URL")
-	db_name := os.Getenv("DATABASE_NAME")
-	client := database.ConnectDatabase(
-		uri,
-		db_name)
+	client := testDatabase(t)
 	userModel := NewUserModel(client)
 	for _, tt := range testtable {
-		err := userModel.ModifyEntry(tt.inputFilter, tt.inputVal)
+		err := userModel.ModifyEntry(context.Background(), tt.inputFilter, tt.inputVal)
 		if err != nil {
 			if err.Error() != tt.expected.Error() {
 				t.Errorf("wrong error when updating to model, got=%s, want=%s", err.Error(), tt.expected.Error())
@@ -122,17 +100,10 @@ func TestUserRemoveEntry(t *testing.T) {
 		{input: bson.D{primitive.E{Key: "username", Value: "gilson"}}, expected: nil},
 		{input: bson.D{}, expected: errors.New("empty val value given")},
 	}
-	// (the dot env doesnt work with test files)
-	// need to replace the with the actual URI when testing
-	godotenv.Load(".env")
-	uri := os.Getenv("MONGO_URL")
-	db_name := os.Getenv("DATABASE_NAME")
-	client := database.ConnectDatabase(
-		uri,
-		db_name)
+	client := testDatabase(t)
 	userModel := NewUserModel(client)
 	for _, tt := range testtable {
-		err := userModel.RemoveEntry(tt.input)
+		err := userModel.RemoveEntry(context.Background(), tt.input)
 		if err != nil {
 			if err.Error() != tt.expected.Error() {
 				t.Errorf("wrong error when removing from the model, got=%s, want=%s", err.Error(), tt.expected.Error())
@@ -149,17 +120,10 @@ func TestUserGetEntryAdvanced(t *testing.T) {
 	}{
 		{input: bson.D{primitive.E{Key: "profilePicture", Value: ""}}, sort: bson.D{primitive.E{Key: "_id", Value: 1}}, expected: 3},
 	}
-	// (the dot env doesnt work with test files)
-	// need to replace the with the actual URI when testing
-	godotenv.Load(".env")
-	uri := os.Getenv("MONGO_URL")
-	db_name := os.Getenv("DATABASE_NAME")
-	client := database.ConnectDatabase(
-		uri,
-		db_name)
+	client := testDatabase(t)
 	userModel := NewUserModel(client)
 	for _, tt := range testtable {
-		usersArray, modelError := userModel.GetEntryAdvanced(tt.input, tt.sort)
+		usersArray, modelError := userModel.GetEntryAdvanced(context.Background(), tt.input, tt.sort)
 		if modelError != nil {
 			t.Fatalf("error when calling the GetEntry fucntion, :%v", modelError)
 		}
@@ -168,3 +132,103 @@ func TestUserGetEntryAdvanced(t *testing.T) {
 		}
 	}
 }
+
+func TestUserFollowUnfollow(t *testing.T) {
+	client := testDatabase(t)
+	userModel := NewUserModel(client)
+	userId := primitive.NewObjectID()
+	followId := primitive.NewObjectID()
+	for i, id := range []primitive.ObjectID{userId, followId} {
+		user := bson.D{primitive.E{Key: "_id", Value: id}, primitive.E{Key: "username", Value: "follow" + string(rune('a'+i))}, primitive.E{Key: "email", Value: "follow" + string(rune('a'+i)) + "@gmail.com"}, primitive.E{Key: "password", Value: "followpassword"}}
+		if err := userModel.AddEntry(context.Background(), user); err != nil {
+			t.Fatalf("error when adding the user, :%v", err)
+		}
+		defer userModel.RemoveEntry(context.Background(), bson.D{primitive.E{Key: "_id", Value: id}})
+	}
+	testtable := []struct {
+		op         func(context.Context, primitive.ObjectID, primitive.ObjectID) (bool, error)
+		followId   primitive.ObjectID
+		expected   bool
+		followers  int
+		followings int
+		err        error
+	}{
+		{op: userModel.Follow, followId: followId, expected: true, followers: 1, followings: 1},
+		// following twice doesnt add the user again
+		{op: userModel.Follow, followId: followId, expected: false, followers: 1, followings: 1},
+		{op: userModel.Unfollow, followId: followId, expected: true, followers: 0, followings: 0},
+		{op: userModel.Unfollow, followId: followId, expected: false, followers: 0, followings: 0},
+		{op: userModel.Follow, followId: primitive.NewObjectID(), expected: false, followers: 0, followings: 0, err: mongo.ErrNoDocuments},
+	}
+	for _, tt := range testtable {
+		changed, err := tt.op(context.Background(), userId, tt.followId)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("wrong error, got=%v, want=%v", err, tt.err)
+		}
+		if changed != tt.expected {
+			t.Errorf("wrong changed value, got=%v, want=%v", changed, tt.expected)
+		}
+		user, _ := userModel.GetEntry(context.Background(), bson.D{primitive.E{Key: "_id", Value: userId}})
+		followed, _ := userModel.GetEntry(context.Background(), bson.D{primitive.E{Key: "_id", Value: followId}})
+		if user == nil || followed == nil {
+			t.Fatalf("error when getting the users back")
+		}
+		if len(followed.Followers) != tt.followers {
+			t.Errorf("wrong number of followers, got=%d, want=%d", len(followed.Followers), tt.followers)
+		}
+		if len(user.Followings) != tt.followings {
+			t.Errorf("wrong number of followings, got=%d, want=%d", len(user.Followings), tt.followings)
+		}
+	}
+}
+
+func TestUserRemoveAccount(t *testing.T) {
+	client := testDatabase(t)
+	userModel := NewUserModel(client)
+	postModel := NewPostModel(client)
+	userId := primitive.NewObjectID()
+	friendId := primitive.NewObjectID()
+	for i, id := range []primitive.ObjectID{userId, friendId} {
+		user := bson.D{primitive.E{Key: "_id", Value: id}, primitive.E{Key: "username", Value: "remove" + string(rune('a'+i))}, primitive.E{Key: "email", Value: "remove" + string(rune('a'+i)) + "@gmail.com"}, primitive.E{Key: "password", Value: "removepassword"}}
+		if err := userModel.AddEntry(context.Background(), user); err != nil {
+			t.Fatalf("error when adding the user, :%v", err)
+		}
+	}
+	defer userModel.RemoveEntry(context.Background(), bson.D{primitive.E{Key: "_id", Value: friendId}})
+	postId := primitive.NewObjectID()
+	friendPostId := primitive.NewObjectID()
+	posts := []bson.D{
+		{primitive.E{Key: "_id", Value: postId}, primitive.E{Key: "userId", Value: userId}, primitive.E{Key: "img", Value: "remove.png"}, primitive.E{Key: "likes", Value: []primitive.ObjectID{}}},
+		{primitive.E{Key: "_id", Value: friendPostId}, primitive.E{Key: "userId", Value: friendId}, primitive.E{Key: "img", Value: "friend.png"}, primitive.E{Key: "likes", Value: []primitive.ObjectID{userId}}},
+	}
+	for _, post := range posts {
+		if err := postModel.AddEntry(context.Background(), post); err != nil {
+			t.Fatalf("error when adding the post, :%v", err)
+		}
+	}
+	defer postModel.RemoveEntry(context.Background(), bson.D{primitive.E{Key: "_id", Value: friendPostId}})
+	if _, err := userModel.Follow(context.Background(), friendId, userId); err != nil {
+		t.Fatalf("error when following the user, :%v", err)
+	}
+
+	if err := userModel.RemoveAccount(context.Background(), userId); err != nil {
+		t.Fatalf("error when removing the account, :%v", err)
+	}
+	if _, err := userModel.GetEntry(context.Background(), bson.D{primitive.E{Key: "_id", Value: userId}}); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("user was not removed, got=%v, want=%v", err, mongo.ErrNoDocuments)
+	}
+	if _, err := postModel.GetEntry(context.Background(), bson.D{primitive.E{Key: "_id", Value: postId}}); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("post of the user was not removed, got=%v, want=%v", err, mongo.ErrNoDocuments)
+	}
+	friend, _ := userModel.GetEntry(context.Background(), bson.D{primitive.E{Key: "_id", Value: friendId}})
+	if friend == nil || len(friend.Followings) != 0 {
+		t.Errorf("removed user is still followed, got=%v", friend)
+	}
+	friendPost, _ := postModel.GetEntry(context.Background(), bson.D{primitive.E{Key: "_id", Value: friendPostId}})
+	if friendPost == nil || len(friendPost.Likes) != 0 {
+		t.Errorf("like of removed user was not removed, got=%v", friendPost)
+	}
+	if err := userModel.RemoveAccount(context.Background(), userId); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("wrong error when removing again, got=%v, want=%v", err, mongo.ErrNoDocuments)
+	}
+}
@@ -0,0 +1,138 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"social-api/database"
+	"social-api/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const userTokenCollectionName string = "userTokens"
+
+// tokens are deleted a day after they expire so a late click still gets
+// the expired message and not the invalid one
+func init() {
+	database.RegisterIndexes(userTokenCollectionName,
+		database.Index{Name: "tokenHash_1", Keys: bson.D{primitive.E{Key: "tokenHash", Value: 1}}, Unique: true},
+		database.Index{Name: "expires_at_1", Keys: bson.D{primitive.E{Key: "expires_at", Value: 1}}, TTL: true, ExpireAfter: 24 * time.Hour},
+	)
+}
+
+//types here have to implement the  Modeler interface
+
+type UserTokenModel struct {
+	Collection *mongo.Collection
+}
+
+// simple search when you need to get a entry without any filter options
+// will only return single entry
+func (tm *UserTokenModel) GetEntry(ctx context.Context, key bson.D) (*types.UserTokens, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	var entry types.UserTokens
+	// bson.d is a drivitive of primitive int so cannont be
+	// nil, so just check the length of the key
+	if len(key) == 0 {
+		return nil, errors.New("empty filter given")
+	}
+	err := tm.Collection.FindOne(ctx, key).Decode(&entry)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (tm *UserTokenModel) GetEntryAdvanced(ctx context.Context, filter bson.D, sort bson.D, opts ...QueryOption) ([]*types.UserTokens, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	cur, err := tm.Collection.Find(ctx, filter, findOptions(sort, opts))
+	if err != nil {
+		return nil, err
+	}
+	entrys := []*types.UserTokens{}
+	if err = cur.All(ctx, &entrys); err != nil {
+		return nil, err
+	}
+	return entrys, nil
+}
+
+func (tm *UserTokenModel) Count(ctx context.Context, filter bson.D) (int64, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Read)
+	defer cancel()
+	return tm.Collection.CountDocuments(ctx, filter)
+}
+
+func (tm *UserTokenModel) AddEntry(ctx context.Context, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	if len(val) <= 2 {
+		return errors.New("not enough values given to add user token")
+	}
+	if _, err := tm.Collection.InsertOne(ctx, val); err != nil {
+		return err
+	}
+	return nil
+
+}
+func (tm *UserTokenModel) RemoveEntry(ctx context.Context, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	if len(val) == 0 {
+		return errors.New("empty val value given")
+	}
+	if _, err := tm.Collection.DeleteOne(ctx, val); err != nil {
+		return err
+	}
+	return nil
+}
+func (tm *UserTokenModel) ModifyEntry(ctx context.Context, filter bson.D, val bson.D) error {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	if len(filter) == 0 {
+		return errors.New("empty filter value given")
+	}
+	if len(val) == 0 {
+		return errors.New("no empty update value given")
+	}
+	if _, err := tm.Collection.UpdateOne(ctx, filter, val); err != nil {
+		return err
+	}
+	return nil
+}
+
+// marks the token as used, false when it was already used or has expired
+// so two requests with the same token cant both use it
+func (tm *UserTokenModel) UseToken(ctx context.Context, tokenId primitive.ObjectID, usedAt time.Time) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx, OperationTimeouts.Write)
+	defer cancel()
+	filter, update := useTokenUpdate(tokenId, usedAt)
+	result, err := tm.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// the filter only matches a token that can still be used, the memory
+// model uses this as well
+func useTokenUpdate(tokenId primitive.ObjectID, usedAt time.Time) (bson.D, bson.D) {
+	filter := bson.D{
+		primitive.E{Key: "_id", Value: tokenId},
+		primitive.E{Key: "used_at", Value: nil},
+		primitive.E{Key: "expires_at", Value: bson.D{primitive.E{Key: "$gt", Value: usedAt}}},
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "used_at", Value: usedAt}}}}
+	return filter, update
+}
+
+func NewUserTokenModel(client *mongo.Database) *UserTokenModel {
+	c := client.Collection(userTokenCollectionName)
+	return &UserTokenModel{
+		Collection: c,
+	}
+}
@@ -0,0 +1,240 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// small router so main doesnt have to split the url by hand, patterns look
+// like /posts/{id}/like where {id} can be read with Param in the handler
+
+type Middleware func(http.HandlerFunc) http.HandlerFunc
+
+type contextKey string
+
+const paramsContextKey contextKey = "params"
+
+type route struct {
+	method   string
+	segments []string
+	handler  http.HandlerFunc
+	// middleware of the group the route is in, also wraps the 405 for the
+	// path so things like cors preflight still run
+	group []Middleware
+}
+
+type Router struct {
+	routes     []*route
+	middleware []Middleware
+	// called when no route matches the path, defaults to a plain 404
+	NotFound http.HandlerFunc
+	// called when the path matches but not the method, the Allow
+	// header is already set when it runs, defaults to a plain 405
+	MethodNotAllowed http.HandlerFunc
+}
+
+func New() *Router {
+	return &Router{
+		NotFound: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("no endpoint for given url"))
+		},
+		MethodNotAllowed: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("method not allowed for given url"))
+		},
+	}
+}
+
+// adds middleware that runs for every request, even ones that dont match
+// a route (first added is the outer most)
+func (rt *Router) Use(mw ...Middleware) {
+	rt.middleware = append(rt.middleware, mw...)
+}
+
+// makes a group of routes that share a prefix and middleware
+func (rt *Router) Group(prefix string, mw ...Middleware) *Group {
+	return &Group{router: rt, prefix: cleanPath(prefix), middleware: mw}
+}
+
+func (rt *Router) Handle(method string, pattern string, handler http.HandlerFunc, mw ...Middleware) {
+	rt.Group("").Handle(method, pattern, handler, mw...)
+}
+
+func (rt *Router) GET(pattern string, handler http.HandlerFunc, mw ...Middleware) {
+	rt.Handle(http.MethodGet, pattern, handler, mw...)
+}
+
+func (rt *Router) POST(pattern string, handler http.HandlerFunc, mw ...Middleware) {
+	rt.Handle(http.MethodPost, pattern, handler, mw...)
+}
+
+func (rt *Router) PUT(pattern string, handler http.HandlerFunc, mw ...Middleware) {
+	rt.Handle(http.MethodPut, pattern, handler, mw...)
+}
+
+func (rt *Router) DELETE(pattern string, handler http.HandlerFunc, mw ...Middleware) {
+	rt.Handle(http.MethodDelete, pattern, handler, mw...)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	chain(rt.dispatch, rt.middleware)(w, r)
+}
+
+// finds the route for the request, sends a 405 with the Allow header
+// if the path matches but the method doesnt
+func (rt *Router) dispatch(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.Path)
+	// only the most specific pattern that matches the path is used
+	var matched []*route
+	for _, rte := range rt.routes {
+		if _, ok := match(rte.segments, segments); !ok {
+			continue
+		}
+		if len(matched) == 0 || moreSpecific(rte, matched[0]) {
+			matched = []*route{rte}
+		} else if samePattern(rte, matched[0]) {
+			matched = append(matched, rte)
+		}
+	}
+	if len(matched) == 0 {
+		rt.NotFound(w, r)
+		return
+	}
+	methods := make([]string, 0, len(matched))
+	for _, rte := range matched {
+		if rte.method == r.Method || (r.Method == http.MethodHead && rte.method == http.MethodGet) {
+			params, _ := match(rte.segments, segments)
+			ctx := context.WithValue(r.Context(), paramsContextKey, params)
+			rte.handler(w, r.WithContext(ctx))
+			return
+		}
+		methods = append(methods, rte.method)
+	}
+	sort.Strings(methods)
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	chain(rt.MethodNotAllowed, matched[0].group)(w, r)
+}
+
+// gets the value of a {name} in the pattern of the route that matched
+func Param(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsContextKey).(map[string]string)
+	return params[name]
+}
+
+type Group struct {
+	router     *Router
+	prefix     string
+	middleware []Middleware
+}
+
+// makes a group inside of this one, it keeps the middleware of this group
+func (g *Group) Group(prefix string, mw ...Middleware) *Group {
+	combined := append(append([]Middleware{}, g.middleware...), mw...)
+	return &Group{router: g.router, prefix: cleanPath(g.prefix + "/" + prefix), middleware: combined}
+}
+
+// adds more middleware to the routes registered on the group after this call
+func (g *Group) Use(mw ...Middleware) {
+	g.middleware = append(g.middleware, mw...)
+}
+
+// the group middleware runs before the middleware given here
+func (g *Group) Handle(method string, pattern string, handler http.HandlerFunc, mw ...Middleware) {
+	all := append(append([]Middleware{}, g.middleware...), mw...)
+	g.router.routes = append(g.router.routes, &route{
+		method:   method,
+		segments: splitPath(g.prefix + "/" + pattern),
+		handler:  chain(handler, all),
+		group:    append([]Middleware{}, g.middleware...),
+	})
+}
+
+func (g *Group) GET(pattern string, handler http.HandlerFunc, mw ...Middleware) {
+	g.Handle(http.MethodGet, pattern, handler, mw...)
+}
+
+func (g *Group) POST(pattern string, handler http.HandlerFunc, mw ...Middleware) {
+	g.Handle(http.MethodPost, pattern, handler, mw...)
+}
+
+func (g *Group) PUT(pattern string, handler http.HandlerFunc, mw ...Middleware) {
+	g.Handle(http.MethodPut, pattern, handler, mw...)
+}
+
+func (g *Group) DELETE(pattern string, handler http.HandlerFunc, mw ...Middleware) {
+	g.Handle(http.MethodDelete, pattern, handler, mw...)
+}
+
+// wraps the handler so the first middleware is the outer most
+func chain(handler http.HandlerFunc, mw []Middleware) http.HandlerFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		handler = mw[i](handler)
+	}
+	return handler
+}
+
+func cleanPath(path string) string {
+	return "/" + strings.Join(splitPath(path), "/")
+}
+
+// splits the path into its segments, empty segments (from double or
+// trailing slashes) are dropped
+func splitPath(path string) []string {
+	parts := strings.Split(path, "/")
+	segments := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part != "" {
+			segments = append(segments, part)
+		}
+	}
+	return segments
+}
+
+func isParam(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+func match(pattern []string, path []string) (map[string]string, bool) {
+	if len(pattern) != len(path) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, segment := range pattern {
+		if isParam(segment) {
+			params[segment[1:len(segment)-1]] = path[i]
+		} else if segment != path[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// literal segments win over params, checked from left to right
+// so /auth/sessions beats /auth/{action} (both patterns match the same path)
+func moreSpecific(a *route, b *route) bool {
+	for i := range a.segments {
+		aParam, bParam := isParam(a.segments[i]), isParam(b.segments[i])
+		if aParam != bParam {
+			return !aParam
+		}
+	}
+	return false
+}
+
+func samePattern(a *route, b *route) bool {
+	if len(a.segments) != len(b.segments) {
+		return false
+	}
+	for i := range a.segments {
+		if isParam(a.segments[i]) != isParam(b.segments[i]) {
+			return false
+		}
+		if !isParam(a.segments[i]) && a.segments[i] != b.segments[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouterDispatch(t *testing.T) {
+	rt := New()
+	rt.GET("/posts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("get " + Param(r, "id")))
+	})
+	rt.PUT("/posts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("put " + Param(r, "id")))
+	})
+	rt.POST("/posts", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("create"))
+	})
+	rt.POST("/posts/{id}/like", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("like " + Param(r, "id")))
+	})
+	rt.GET("/auth/sessions", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("sessions"))
+	})
+	rt.GET("/auth/{action}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("action " + Param(r, "action")))
+	})
+	testtable := []struct {
+		method       string
+		path         string
+		expectedCode int
+		expectedBody string
+		expectAllow  string
+	}{
+		{method: "GET", path: "/posts/abc", expectedCode: 200, expectedBody: "get abc"},
+		{method: "PUT", path: "/posts/abc/", expectedCode: 200, expectedBody: "put abc"},
+		{method: "HEAD", path: "/posts/abc", expectedCode: 200},
+		{method: "DELETE", path: "/posts/abc", expectedCode: 405, expectAllow: "GET, PUT"},
+		{method: "POST", path: "/posts/", expectedCode: 200, expectedBody: "create"},
+		{method: "GET", path: "/posts", expectedCode: 405, expectAllow: "POST"},
+		{method: "POST", path: "/posts/abc/like", expectedCode: 200, expectedBody: "like abc"},
+		{method: "POST", path: "/posts/abc/share", expectedCode: 404},
+		{method: "GET", path: "/auth/sessions", expectedCode: 200, expectedBody: "sessions"},
+		{method: "GET", path: "/auth/other", expectedCode: 200, expectedBody: "action other"},
+		{method: "POST", path: "/auth/sessions", expectedCode: 405, expectAllow: "GET"},
+		{method: "GET", path: "/nothing/here", expectedCode: 404},
+	}
+	for _, tt := range testtable {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.expectedCode {
+			t.Errorf("wrong status for %s %s, got=%d, want=%d", tt.method, tt.path, rec.Code, tt.expectedCode)
+		}
+		if tt.expectedBody != "" && rec.Body.String() != tt.expectedBody {
+			t.Errorf("wrong body for %s %s, got=%s, want=%s", tt.method, tt.path, rec.Body.String(), tt.expectedBody)
+		}
+		if allow := rec.Header().Get("Allow"); allow != tt.expectAllow {
+			t.Errorf("wrong Allow header for %s %s, got=%s, want=%s", tt.method, tt.path, allow, tt.expectAllow)
+		}
+	}
+}
+
+func TestRouterGroupMiddleware(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next(w, r)
+			}
+		}
+	}
+	rt := New()
+	rt.Use(mark("global"))
+	api := rt.Group("/v1", mark("group"))
+	users := api.Group("/users", mark("nested"))
+	users.GET("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler "+Param(r, "id"))
+	}, mark("route"))
+	rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/users/42", nil))
+	expected := []string{"global", "group", "nested", "route", "handler 42"}
+	if len(order) != len(expected) {
+		t.Fatalf("wrong number of calls, got=%v, want=%v", order, expected)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Errorf("wrong call order, got=%v, want=%v", order, expected)
+			break
+		}
+	}
+	// the group middleware wraps the 405 of its paths but not the route middleware
+	order = nil
+	rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("OPTIONS", "/v1/users/42", nil))
+	expected = []string{"global", "group", "nested"}
+	if len(order) != len(expected) {
+		t.Fatalf("wrong calls for 405, got=%v, want=%v", order, expected)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Errorf("wrong call order for 405, got=%v, want=%v", order, expected)
+			break
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// keeps the attempts in a map, only works when there is one instance of the api
+type MemoryStore struct {
+	mu       sync.Mutex
+	attempts map[string]Attempt
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{attempts: make(map[string]Attempt)}
+}
+
+func (ms *MemoryStore) Get(ctx context.Context, key string) (Attempt, error) {
+	if err := ctx.Err(); err != nil {
+		return Attempt{}, err
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	attempt, ok := ms.attempts[key]
+	if !ok {
+		return Attempt{Key: key}, nil
+	}
+	return attempt, nil
+}
+
+func (ms *MemoryStore) Fail(ctx context.Context, key string, now time.Time, window time.Duration) (Attempt, error) {
+	if err := ctx.Err(); err != nil {
+		return Attempt{}, err
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	before, ok := ms.attempts[key]
+	if !ok || now.Sub(before.LastFailure) > window {
+		before = Attempt{Key: key}
+	}
+	attempt := before
+	attempt.Failures++
+	attempt.LastFailure = now
+	ms.attempts[key] = attempt
+	ms.prune(now, window)
+	return before, nil
+}
+
+func (ms *MemoryStore) Release(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	attempt, ok := ms.attempts[key]
+	if !ok || attempt.Failures == 0 {
+		return nil
+	}
+	attempt.Failures--
+	ms.attempts[key] = attempt
+	return nil
+}
+
+func (ms *MemoryStore) Lock(ctx context.Context, key string, until time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	attempt := ms.attempts[key]
+	attempt.Key = key
+	attempt.LockedUntil = until
+	ms.attempts[key] = attempt
+	return nil
+}
+
+func (ms *MemoryStore) Reset(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.attempts, key)
+	return nil
+}
+
+// drops the keys that have not failed in a while so the map doesnt grow
+// forever (needs to be called with the lock held)
+func (ms *MemoryStore) prune(now time.Time, window time.Duration) {
+	for key, attempt := range ms.attempts {
+		if now.Sub(attempt.LastFailure) > window && attempt.LockedUntil.Before(now) {
+			delete(ms.attempts, key)
+		}
+	}
+}
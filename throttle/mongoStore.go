@@ -0,0 +1,123 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"social-api/database"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const attemptCollectionName string = "loginAttempts"
+
+// attempts are only counted inside the policy window and locks are shorter
+// than a day, so a day after the last failure the record can go
+func init() {
+	database.RegisterIndexes(attemptCollectionName,
+		database.Index{Name: "last_failure_1", Keys: bson.D{primitive.E{Key: "last_failure", Value: 1}}, TTL: true, ExpireAfter: 24 * time.Hour},
+	)
+}
+
+// keeps the attempts in mongo so every instance of the api sees the same counts
+type MongoStore struct {
+	Collection *mongo.Collection
+	// how long each call can take so a slow database cant hold up the login
+	Timeout time.Duration
+}
+
+type attemptDocument struct {
+	Key         string    `bson:"_id"`
+	Failures    int       `bson:"failures"`
+	LastFailure time.Time `bson:"last_failure"`
+	LockedUntil time.Time `bson:"locked_until"`
+}
+
+func (doc attemptDocument) attempt() Attempt {
+	return Attempt{
+		Key:         doc.Key,
+		Failures:    doc.Failures,
+		LastFailure: doc.LastFailure,
+		LockedUntil: doc.LockedUntil,
+	}
+}
+
+func (ms *MongoStore) Get(ctx context.Context, key string) (Attempt, error) {
+	ctx, cancel := database.WithTimeout(ctx, ms.Timeout)
+	defer cancel()
+	var doc attemptDocument
+	err := ms.Collection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: key}}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Attempt{Key: key}, nil
+	}
+	if err != nil {
+		return Attempt{}, err
+	}
+	return doc.attempt(), nil
+}
+
+func (ms *MongoStore) Fail(ctx context.Context, key string, now time.Time, window time.Duration) (Attempt, error) {
+	ctx, cancel := database.WithTimeout(ctx, ms.Timeout)
+	defer cancel()
+	// start the count over if the last failure was outside of the window
+	filter := bson.D{
+		primitive.E{Key: "_id", Value: key},
+		primitive.E{Key: "last_failure", Value: bson.D{primitive.E{Key: "$lt", Value: now.Add(-window)}}},
+	}
+	if _, err := ms.Collection.DeleteOne(ctx, filter); err != nil {
+		return Attempt{}, err
+	}
+	update := bson.D{
+		primitive.E{Key: "$inc", Value: bson.D{primitive.E{Key: "failures", Value: 1}}},
+		primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "last_failure", Value: now}}},
+	}
+	// the document from before the update, there is none on the first failure
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+	var doc attemptDocument
+	err := ms.Collection.FindOneAndUpdate(ctx, bson.D{primitive.E{Key: "_id", Value: key}}, update, opts).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Attempt{Key: key}, nil
+	}
+	if err != nil {
+		return Attempt{}, err
+	}
+	return doc.attempt(), nil
+}
+
+func (ms *MongoStore) Release(ctx context.Context, key string) error {
+	ctx, cancel := database.WithTimeout(ctx, ms.Timeout)
+	defer cancel()
+	filter := bson.D{
+		primitive.E{Key: "_id", Value: key},
+		primitive.E{Key: "failures", Value: bson.D{primitive.E{Key: "$gt", Value: 0}}},
+	}
+	update := bson.D{primitive.E{Key: "$inc", Value: bson.D{primitive.E{Key: "failures", Value: -1}}}}
+	_, err := ms.Collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (ms *MongoStore) Lock(ctx context.Context, key string, until time.Time) error {
+	ctx, cancel := database.WithTimeout(ctx, ms.Timeout)
+	defer cancel()
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "locked_until", Value: until}}}}
+	_, err := ms.Collection.UpdateOne(ctx, bson.D{primitive.E{Key: "_id", Value: key}}, update)
+	return err
+}
+
+func (ms *MongoStore) Reset(ctx context.Context, key string) error {
+	ctx, cancel := database.WithTimeout(ctx, ms.Timeout)
+	defer cancel()
+	_, err := ms.Collection.DeleteOne(ctx, bson.D{primitive.E{Key: "_id", Value: key}})
+	return err
+}
+
+func NewMongoStore(client *mongo.Database, timeout time.Duration) *MongoStore {
+	c := client.Collection(attemptCollectionName)
+	return &MongoStore{
+		Collection: c,
+		Timeout:    timeout,
+	}
+}
@@ -0,0 +1,165 @@
+package throttle
+
+import (
+	"context"
+	"time"
+)
+
+// the throttler counts failed attempts for a key (a username or a ip) and
+// tells the caller how long they need to wait before trying again, the
+// counts are kept in a Store so more than one instance can share them
+
+type Attempt struct {
+	Key         string
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+type Store interface {
+	// returns the attempt for the key (a zero Attempt if there is none)
+	Get(ctx context.Context, key string) (Attempt, error)
+	// adds one failure to the key in a single step and returns the attempt
+	// as it was before the failure was added, window is how long since the
+	// last failure before the count starts over
+	Fail(ctx context.Context, key string, now time.Time, window time.Duration) (Attempt, error)
+	// takes one failure back off the key (never below 0)
+	Release(ctx context.Context, key string) error
+	// sets the time the key is locked until
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+type Policy struct {
+	// failures allowed before any delay is added
+	FreeAttempts int
+	// delay after the first failure past the free ones, doubles every failure after
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// after this many failures the key is locked for LockoutDuration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// how long without a failure before the count is forgotten
+	Window time.Duration
+}
+
+type Throttler struct {
+	store  Store
+	policy Policy
+	prefix string
+}
+
+// prefix keeps keys from different throttlers apart when they share a store
+func NewThrottler(store Store, policy Policy, prefix string) *Throttler {
+	return &Throttler{
+		store:  store,
+		policy: policy,
+		prefix: prefix,
+	}
+}
+
+// how long the key has to wait before it can try again (0 if it can try now)
+func (t *Throttler) Check(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	attempt, err := t.store.Get(ctx, t.prefix+key)
+	if err != nil {
+		return 0, err
+	}
+	return t.wait(attempt, now), nil
+}
+
+// records a failed attempt for the key, locks the key if it went over the threshold
+func (t *Throttler) Fail(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	_, after, err := t.count(ctx, key, now)
+	if err != nil {
+		return 0, err
+	}
+	return t.wait(after, now), nil
+}
+
+// counts an attempt for the key before it is made and returns how long the
+// key had to wait (0 if the attempt can go ahead). the count and the check
+// are one store call so parallel attempts cant all get past the check before
+// any of them are counted. attempts that get blocked still count, a
+// successful one should be given back with Release or Succeed
+func (t *Throttler) Reserve(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	before, _, err := t.count(ctx, key, now)
+	if err != nil {
+		return 0, err
+	}
+	return t.wait(before, now), nil
+}
+
+// takes back one attempt counted by Reserve
+func (t *Throttler) Release(ctx context.Context, key string) error {
+	return t.store.Release(ctx, t.prefix+key)
+}
+
+// adds a failure to the key and returns the attempt from before and after,
+// locks the key if the failure took it over the threshold
+func (t *Throttler) count(ctx context.Context, key string, now time.Time) (Attempt, Attempt, error) {
+	before, err := t.store.Fail(ctx, t.prefix+key, now, t.policy.Window)
+	if err != nil {
+		return Attempt{}, Attempt{}, err
+	}
+	after := before
+	after.Failures++
+	after.LastFailure = now
+	if t.policy.LockoutThreshold > 0 && after.Failures >= t.policy.LockoutThreshold && after.LockedUntil.Before(now) {
+		after.LockedUntil = now.Add(t.policy.LockoutDuration)
+		if err := t.store.Lock(ctx, t.prefix+key, after.LockedUntil); err != nil {
+			return Attempt{}, Attempt{}, err
+		}
+	}
+	return before, after, nil
+}
+
+// clears the failures for the key after a successful attempt
+func (t *Throttler) Succeed(ctx context.Context, key string) error {
+	return t.store.Reset(ctx, t.prefix+key)
+}
+
+func (t *Throttler) wait(attempt Attempt, now time.Time) time.Duration {
+	if attempt.Failures == 0 || now.Sub(attempt.LastFailure) > t.policy.Window {
+		return 0
+	}
+	if attempt.LockedUntil.After(now) {
+		return attempt.LockedUntil.Sub(now)
+	}
+	over := attempt.Failures - t.policy.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+	delay := t.policy.BaseDelay
+	for i := 1; i < over && delay < t.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.policy.MaxDelay {
+		delay = t.policy.MaxDelay
+	}
+	ready := attempt.LastFailure.Add(delay)
+	if ready.After(now) {
+		return ready.Sub(now)
+	}
+	return 0
+}
+
+// default policy for failed logins on a single account
+var AccountPolicy = Policy{
+	FreeAttempts:     3,
+	BaseDelay:        time.Second,
+	MaxDelay:         5 * time.Minute,
+	LockoutThreshold: 10,
+	LockoutDuration:  15 * time.Minute,
+	Window:           time.Hour,
+}
+
+// default policy for failed logins from a single ip, higher limits because
+// a lot of users can share one ip
+var IPPolicy = Policy{
+	FreeAttempts:     20,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 100,
+	LockoutDuration:  15 * time.Minute,
+	Window:           time.Hour,
+}
@@ -0,0 +1,115 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestThrottlerWait(t *testing.T) {
+	policy := Policy{
+		FreeAttempts:     3,
+		BaseDelay:        time.Second,
+		MaxDelay:         8 * time.Second,
+		LockoutThreshold: 8,
+		LockoutDuration:  time.Minute,
+		Window:           time.Hour,
+	}
+	testtable := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{failures: 1, expected: 0},
+		{failures: 3, expected: 0},
+		{failures: 4, expected: time.Second},
+		{failures: 5, expected: 2 * time.Second},
+		{failures: 6, expected: 4 * time.Second},
+		{failures: 7, expected: 8 * time.Second},
+		{failures: 8, expected: time.Minute},
+	}
+	now := time.Unix(1700000000, 0)
+	for _, tt := range testtable {
+		throttler := NewThrottler(NewMemoryStore(), policy, "account:")
+		var got time.Duration
+		for i := 0; i < tt.failures; i++ {
+			wait, err := throttler.Fail(context.Background(), "bob", now)
+			if err != nil {
+				t.Fatalf("error when failing attempt, :%v", err)
+			}
+			got = wait
+		}
+		if got != tt.expected {
+			t.Errorf("wrong wait after %d failures, got=%s, want=%s", tt.failures, got, tt.expected)
+		}
+		checked, _ := throttler.Check(context.Background(), "bob", now)
+		if checked != tt.expected {
+			t.Errorf("wrong wait from check after %d failures, got=%s, want=%s", tt.failures, checked, tt.expected)
+		}
+		if err := throttler.Succeed(context.Background(), "bob"); err != nil {
+			t.Fatalf("error when resetting, :%v", err)
+		}
+		if wait, _ := throttler.Check(context.Background(), "bob", now); wait != 0 {
+			t.Errorf("wait should be 0 after success, got=%s", wait)
+		}
+	}
+}
+
+func TestThrottlerWindow(t *testing.T) {
+	policy := Policy{FreeAttempts: 0, BaseDelay: time.Second, MaxDelay: time.Minute, Window: time.Minute}
+	throttler := NewThrottler(NewMemoryStore(), policy, "ip:")
+	now := time.Unix(1700000000, 0)
+	throttler.Fail(context.Background(), "1.2.3.4", now)
+	throttler.Fail(context.Background(), "1.2.3.4", now)
+	later := now.Add(2 * time.Minute)
+	if wait, _ := throttler.Check(context.Background(), "1.2.3.4", later); wait != 0 {
+		t.Errorf("failures should be forgotten after the window, got=%s", wait)
+	}
+	if wait, _ := throttler.Fail(context.Background(), "1.2.3.4", later); wait != time.Second {
+		t.Errorf("count should start over after the window, got=%s, want=%s", wait, time.Second)
+	}
+}
+
+func TestThrottlerReserve(t *testing.T) {
+	policy := Policy{FreeAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute, Window: time.Hour}
+	throttler := NewThrottler(NewMemoryStore(), policy, "account:")
+	now := time.Unix(1700000000, 0)
+	// a burst of attempts at the same time, only the free ones and the one
+	// after them get through before the first delay
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		wait, err := throttler.Reserve(context.Background(), "bob", now)
+		if err != nil {
+			t.Fatalf("error when reserving attempt, :%v", err)
+		}
+		if wait == 0 {
+			allowed++
+		}
+	}
+	if allowed != 4 {
+		t.Errorf("wrong number of attempts let through, got=%d, want=%d", allowed, 4)
+	}
+	if err := throttler.Succeed(context.Background(), "bob"); err != nil {
+		t.Fatalf("error when resetting, :%v", err)
+	}
+	for i := 0; i < 4; i++ {
+		throttler.Reserve(context.Background(), "bob", now)
+	}
+	if err := throttler.Release(context.Background(), "bob"); err != nil {
+		t.Fatalf("error when releasing, :%v", err)
+	}
+	if wait, _ := throttler.Reserve(context.Background(), "bob", now); wait != 0 {
+		t.Errorf("released attempt should not count, got=%s", wait)
+	}
+}
+
+func TestMemoryStoreCanceled(t *testing.T) {
+	throttler := NewThrottler(NewMemoryStore(), AccountPolicy, "account:")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := throttler.Fail(ctx, "bob", time.Now()); err == nil {
+		t.Errorf("fail should return the context error after the request is canceled")
+	}
+	if _, err := throttler.Check(ctx, "bob", time.Now()); err == nil {
+		t.Errorf("check should return the context error after the request is canceled")
+	}
+}
@@ -0,0 +1,77 @@
+package types
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// what a api key is allowed to do, a key can only be used on routes
+// that ask for one of its scopes (account settings never accept a key)
+type Scope string
+
+const (
+	ScopePostsWrite    Scope = "posts:write"
+	ScopeTimelineRead  Scope = "timeline:read"
+	ScopeUsersFollow   Scope = "users:follow"
+	ScopeCommentsWrite Scope = "comments:write"
+)
+
+var AllScopes = []Scope{ScopePostsWrite, ScopeTimelineRead, ScopeUsersFollow, ScopeCommentsWrite}
+
+// start of every api key so the auth middleware can tell it from a access token
+const APIKeyPrefix string = "sk_"
+
+// personal api keys for bots and scripts (only the hash of the key is stored)
+type APIKeys struct {
+	KeyID     primitive.ObjectID `bson:"_id"`
+	UserID    primitive.ObjectID `bson:"userId"`
+	Name      string             `bson:"name"`
+	Hint      string             `bson:"hint"` // last few characters so the user can tell keys apart
+	KeyHash   string             `bson:"keyHash"`
+	Scopes    []Scope            `bson:"scopes"`
+	CreatedAt time.Time          `bson:"created_at"`
+	LastUsed  *time.Time         `bson:"last_used"` // nil until the key is used the first time
+}
+
+func NewAPIKey(userId primitive.ObjectID, name string, scopes []Scope) *APIKeys {
+	key := &APIKeys{
+		KeyID:     primitive.NewObjectID(),
+		UserID:    userId,
+		Name:      name,
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	return key
+}
+
+// checks if the given scope is one of the known scopes
+func ValidScope(scope Scope) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// returns true if the key was given the scope
+func HasScope(key *APIKeys, scope Scope) bool {
+	for _, s := range key.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// stuct of the data sent when making a api key
+type APIKeyRequest struct {
+	Name   string  `json:"name" validate:"required,max=64"`
+	Scopes []Scope `json:"scopes" validate:"required"`
+}
+
+// stuct of the data sent when renaming a api key (the scopes cant be changed)
+type RenameAPIKeyRequest struct {
+	Name string `json:"name" validate:"required,max=64"`
+}
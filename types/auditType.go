@@ -0,0 +1,32 @@
+package types
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// every time a permission is checked a entry is saved so admins can see
+// who used (or tried to use) elevated rights
+type AuditEntries struct {
+	AuditID    primitive.ObjectID `bson:"_id"`
+	ActorID    primitive.ObjectID `bson:"actorId"`
+	Permission Permission         `bson:"permission"`
+	Target     string             `bson:"target"` // id of the user or post the action was on
+	Allowed    bool               `bson:"allowed"`
+	Method     string             `bson:"method"`
+	Path       string             `bson:"path"`
+	CreatedAt  time.Time          `bson:"created_at"`
+}
+
+func NewAuditEntry(actorId primitive.ObjectID, perm Permission, target string, allowed bool) *AuditEntries {
+	entry := &AuditEntries{
+		AuditID:    primitive.NewObjectID(),
+		ActorID:    actorId,
+		Permission: perm,
+		Target:     target,
+		Allowed:    allowed,
+		CreatedAt:  time.Now(),
+	}
+	return entry
+}
@@ -1,18 +1,18 @@
 package types
 
 // stuct of the data sent when a new user is create or requested
+// (the rules are for register, login only needs one of username or email)
 type AuthUserRequest struct {
-	UserId      string `json:"userId"`
-	UserName    string `json:"username"`
-	Password    string `json:"password"`
-	Email       string `json:"email"`
-	AdminSecret string `json:"adminSecret"`
+	UserName string `json:"username" validate:"required,username"`
+	Password string `json:"password" validate:"required"`
+	Email    string `json:"email" validate:"required,email,max=254"`
 }
 
-// checks if the given requestUser is valid
-func ValidAuthUser(user *AuthUserRequest) bool {
-	if user.Email == "" || user.Password == "" || user.UserName == "" {
-		return false
-	}
-	return true
+// stuct of the data sent to the 2fa endpoints, code is from the
+// authenticator app and recovery code is one of the backup codes
+type TwoFactorRequest struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recoveryCode"`
+	Password       string `json:"password"`
 }
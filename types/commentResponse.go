@@ -0,0 +1,33 @@
+package types
+
+import (
+	"time"
+)
+
+// what clients get when listing the comments of a post
+type CommentResponse struct {
+	ID        string    `json:"id"`
+	PostID    string    `json:"postId"`
+	UserID    string    `json:"userId"`
+	ParentID  string    `json:"parentId,omitempty"`
+	Depth     int       `json:"depth"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func NewCommentResponse(comment *Comments) CommentResponse {
+	response := CommentResponse{
+		ID:        comment.CommentID.Hex(),
+		PostID:    comment.PostID.Hex(),
+		UserID:    comment.UserID.Hex(),
+		Depth:     comment.Depth(),
+		Text:      comment.Text,
+		CreatedAt: comment.CreatedAt,
+		UpdatedAt: comment.UpdatedAt,
+	}
+	if comment.ParentID != nil {
+		response.ParentID = comment.ParentID.Hex()
+	}
+	return response
+}
@@ -0,0 +1,58 @@
+package types
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// a comment on a post, replies keep the ids of every comment above them in
+// ancestors (the top level comment first) so a whole thread can be found
+// with one query
+type Comments struct {
+	CommentID primitive.ObjectID   `bson:"_id"`
+	PostID    primitive.ObjectID   `bson:"postId"`
+	UserID    primitive.ObjectID   `bson:"userId"`
+	ParentID  *primitive.ObjectID  `bson:"parentId,omitempty"` // nil for a comment on the post itself
+	Ancestors []primitive.ObjectID `bson:"ancestors"`
+	Text      string               `bson:"text"`
+	CreatedAt time.Time            `bson:"created_at"`
+	UpdatedAt time.Time            `bson:"updated_at"`
+}
+
+// how many replies deep the comment is, 0 for a comment on the post
+func (c *Comments) Depth() int {
+	return len(c.Ancestors)
+}
+
+func NewComment(postId primitive.ObjectID, userId primitive.ObjectID) *Comments {
+	now := time.Now()
+	comment := &Comments{
+		CommentID: primitive.NewObjectID(),
+		PostID:    postId,
+		UserID:    userId,
+		Ancestors: []primitive.ObjectID{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return comment
+}
+
+// makes the comment a reply to parent
+func (c *Comments) ReplyTo(parent *Comments) {
+	parentId := parent.CommentID
+	c.ParentID = &parentId
+	c.Ancestors = append(append([]primitive.ObjectID{}, parent.Ancestors...), parent.CommentID)
+}
+
+// stuct of the data sent when making a comment, parentId is the comment
+// being replied to (empty for a comment on the post)
+type CommentRequest struct {
+	Text     string `json:"text" validate:"required,max=1000"`
+	ParentID string `json:"parentId"`
+}
+
+// stuct of the data sent when editing a comment, only the text can change
+type EditCommentRequest struct {
+	Text string `json:"text" validate:"required,max=1000"`
+}
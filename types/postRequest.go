@@ -1,14 +1,14 @@
 package types
 
 // stuct of the data sent when a new user is create or requested
+// (the owner of the post comes from the access token, not the body)
 type RequestPost struct {
-	UserId string `json:"userId"`
-	Desc   string `json:"desc"`
-	Image  string `json:"img"`
+	Desc  string `json:"desc" validate:"max=500"`
+	Image string `json:"img" validate:"required,url,max=2048"`
 }
 
-// checkes if the given post in a request has the required values
-// to create a post (returns true if post is valid)
-func ValidReqestPost(post *RequestPost) bool {
-	return (post.UserId != "" && post.Image != "")
+// stuct of the data sent when a post is changed, fields left out are kept
+type EditPostRequest struct {
+	Desc  string `json:"desc" validate:"max=500"`
+	Image string `json:"img" validate:"url,max=2048"`
 }
@@ -0,0 +1,38 @@
+package types
+
+import (
+	"time"
+)
+
+// what clients get when asking for a post
+type PostResponse struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Image     string    `json:"image"`
+	Desc      string    `json:"desc"`
+	Likes     []string  `json:"likes"`
+	LikeCount int       `json:"likeCount"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func NewPostResponse(post *Posts) PostResponse {
+	likes := hexIDs(post.Likes)
+	return PostResponse{
+		ID:        post.PostID.Hex(),
+		UserID:    post.UserID.Hex(),
+		Image:     post.Image,
+		Desc:      post.Desc,
+		Likes:     likes,
+		LikeCount: len(likes),
+		CreatedAt: post.CreatedAt,
+		UpdatedAt: post.UpdatedAt,
+	}
+}
+
+// what clients get when asking for a single post, the comment count is
+// only worked out here so the timeline doesnt need a count per post
+type PostDetailResponse struct {
+	PostResponse
+	CommentCount int64 `json:"commentCount"`
+}
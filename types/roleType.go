@@ -0,0 +1,54 @@
+package types
+
+// roles replace the old isAdmin flag, each role has a set of named
+// permissions and the handlers only ever check for the permission
+
+type Role string
+
+type Permission string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+const (
+	PermUpdateAnyUser Permission = "users:update:any"
+	PermDeleteAnyUser Permission = "users:delete:any"
+	PermUpdateAnyPost Permission = "posts:update:any"
+	PermDeleteAnyPost Permission = "posts:delete:any"
+	// the owner of a post can always delete the comments on it
+	PermDeleteAnyComment Permission = "comments:delete:any"
+	PermManageRoles      Permission = "roles:manage"
+)
+
+// what each role is allowed to do (roles dont inherit so admin lists everything)
+var RolePermissions = map[Role][]Permission{
+	RoleUser:      {},
+	RoleModerator: {PermUpdateAnyPost, PermDeleteAnyPost, PermDeleteAnyComment},
+	RoleAdmin:     {PermUpdateAnyUser, PermDeleteAnyUser, PermUpdateAnyPost, PermDeleteAnyPost, PermDeleteAnyComment, PermManageRoles},
+}
+
+// checks if the given string is one of the known roles
+func ValidRole(role Role) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// returns true if any of the given roles has the permission
+func HasPermission(roles []Role, perm Permission) bool {
+	for _, role := range roles {
+		for _, p := range RolePermissions[role] {
+			if p == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stuct of the data sent when a admin grants a role to a user
+type RoleRequest struct {
+	Role Role `json:"role" validate:"required"`
+}
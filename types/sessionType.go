@@ -0,0 +1,40 @@
+package types
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// how long a refresh token can be used before the user has to login again
+const SessionDuration time.Duration = 30 * 24 * time.Hour
+
+// a session is made every time a user logs in, it holds the hash of the
+// refresh token so the session can be revoked without changing the password
+type Sessions struct {
+	SessionID primitive.ObjectID `bson:"_id"`
+	UserID    primitive.ObjectID `bson:"userId"`
+	TokenHash string             `bson:"tokenHash"` // sha256 of the refresh token, never the token itself
+	UserAgent string             `bson:"userAgent"`
+	IP        string             `bson:"ip"`
+	CreatedAt time.Time          `bson:"created_at"`
+	LastSeen  time.Time          `bson:"last_seen"` // updated every time the session is refreshed
+	ExpiresAt time.Time          `bson:"expires_at"`
+}
+
+func NewSession(userId primitive.ObjectID) *Sessions {
+	now := time.Now()
+	session := &Sessions{
+		SessionID: primitive.NewObjectID(),
+		UserID:    userId,
+		CreatedAt: now,
+		LastSeen:  now,
+		ExpiresAt: now.Add(SessionDuration),
+	}
+	return session
+}
+
+// stuct of the data sent when the client wants a new access token
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
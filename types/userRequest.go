@@ -6,18 +6,16 @@ import (
 
 type RequestUser struct {
 	UserID       string    `json:"userId"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	Password     string    `json:"password"`
-	ProfilePic   string    `json:"profilePicture"`
-	CoverPic     string    `json:"coverPicture"`
-	Follwers     []string  `json:"follwers"`
-	Follwings    []string  `json:"follwings"`
-	IsAdmin      bool      `json:"isAdmin"`
-	Desc         string    `json:"desc"`
-	City         string    `json:"city"`
-	From         string    `json:"from"`
-	Relationship int       `json:"relationship"`
+	Username     string    `json:"username" validate:"username"`
+	Email        string    `json:"email" validate:"email,max=254"`
+	Password     string    `json:"password"`    // only needed when changing your own account
+	NewPassword  string    `json:"newPassword"` // only set when the user wants to change their password
+	ProfilePic   string    `json:"profilePicture" validate:"url,max=2048"`
+	CoverPic     string    `json:"coverPicture" validate:"url,max=2048"`
+	Desc         string    `json:"desc" validate:"max=500"`
+	City         string    `json:"city" validate:"max=100"`
+	From         string    `json:"from" validate:"max=100"`
+	Relationship int       `json:"relationship" validate:"min=0,max=4"` // one of the Relationship values
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"` // need to update this whenever changing data
 }
@@ -0,0 +1,79 @@
+package types
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// what clients get when asking for a user, secrets like the password
+// hash are never part of it so they cant leak by mistake
+type UserResponse struct {
+	ID             string    `json:"id"`
+	Username       string    `json:"username"`
+	ProfilePic     string    `json:"profilePicture"`
+	CoverPic       string    `json:"coverPicture"`
+	Desc           string    `json:"desc"`
+	City           string    `json:"city"`
+	From           string    `json:"from"`
+	Relationship   int       `json:"relationship"`
+	Followers      []string  `json:"followers"`
+	Followings     []string  `json:"followings"`
+	FollowerCount  int       `json:"followerCount"`
+	FollowingCount int       `json:"followingCount"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// the user as seen by themselves, has the account details other users dont get
+type PrivateUserResponse struct {
+	UserResponse
+	Email            string `json:"email"`
+	Roles            []Role `json:"roles"`
+	EmailVerified    bool   `json:"emailVerified"`
+	TwoFactorEnabled bool   `json:"twoFactorEnabled"`
+}
+
+// ids are sent to clients as hex strings
+func hexIDs(ids []primitive.ObjectID) []string {
+	hex := make([]string, 0, len(ids))
+	for _, id := range ids {
+		hex = append(hex, id.Hex())
+	}
+	return hex
+}
+
+func NewUserResponse(user *Users) UserResponse {
+	followers := hexIDs(user.Followers)
+	followings := hexIDs(user.Followings)
+	return UserResponse{
+		ID:             user.UserID.Hex(),
+		Username:       user.Username,
+		ProfilePic:     user.ProfilePic,
+		CoverPic:       user.CoverPic,
+		Desc:           user.Desc,
+		City:           user.City,
+		From:           user.From,
+		Relationship:   user.Relationship,
+		Followers:      followers,
+		Followings:     followings,
+		FollowerCount:  len(followers),
+		FollowingCount: len(followings),
+		CreatedAt:      user.CreatedAt,
+		UpdatedAt:      user.UpdatedAt,
+	}
+}
+
+func NewPrivateUserResponse(user *Users) PrivateUserResponse {
+	roles := user.Roles
+	if roles == nil {
+		roles = []Role{}
+	}
+	return PrivateUserResponse{
+		UserResponse:     NewUserResponse(user),
+		Email:            user.Email,
+		Roles:            roles,
+		EmailVerified:    user.EmailVerified,
+		TwoFactorEnabled: user.TOTPEnabled,
+	}
+}
@@ -0,0 +1,64 @@
+package types
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// what the token can be used for
+const (
+	ResetPasswordToken string = "reset-password"
+	VerifyEmailToken   string = "verify-email"
+)
+
+// single use tokens that get mailed to the user (only the hash is stored)
+type UserTokens struct {
+	TokenID   primitive.ObjectID `bson:"_id"`
+	UserID    primitive.ObjectID `bson:"userId"`
+	Email     string             `bson:"email"` // the email the token was sent to
+	Purpose   string             `bson:"purpose"`
+	TokenHash string             `bson:"tokenHash"`
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at"` // nil until the token has been used
+}
+
+func NewUserToken(userId primitive.ObjectID, email string, purpose string, duration time.Duration) *UserTokens {
+	now := time.Now()
+	token := &UserTokens{
+		TokenID:   primitive.NewObjectID(),
+		UserID:    userId,
+		Email:     email,
+		Purpose:   purpose,
+		CreatedAt: now,
+		ExpiresAt: now.Add(duration),
+	}
+	return token
+}
+
+// token can only be used once and before it expires
+func ValidUserToken(token *UserTokens) bool {
+	return token.UsedAt == nil && time.Now().Before(token.ExpiresAt)
+}
+
+// stuct of the data sent when a user forgot their password
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// stuct of the data sent with the token from the verification email
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// stuct of the data sent when the user needs a new verification email
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// stuct of the data sent with the token from the reset email
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
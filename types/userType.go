@@ -6,50 +6,59 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// values of the relationship field of a user
+const (
+	RelationshipNotSet int = iota
+	RelationshipSingle
+	RelationshipInRelationship
+	RelationshipMarried
+	RelationshipComplicated
+)
+
 type Users struct {
-	UserID       primitive.ObjectID `bson:"_id"`
-	Username     string             `bson:"username"`
-	Email        string             `bson:"email"`
-	Password     string             `bson:"password"`
-	ProfilePic   string             `bson:"profilePicture"`
-	CoverPic     string             `bson:"coverPicture"`
-	Follwers     []string           `bson:"follwers"`
-	Follwings    []string           `bson:"follwings"`
-	IsAdmin      bool               `bson:"isAdmin"`
-	Desc         string             `bson:"desc"`
-	City         string             `bson:"city"`
-	From         string             `bson:"from"`
-	Relationship int                `bson:"relationship"`
-	CreatedAt    time.Time          `bson:"created_at"`
-	UpdatedAt    time.Time          `bson:"updated_at"` // need to update this whenever changing data
+	UserID     primitive.ObjectID   `bson:"_id"`
+	Username   string               `bson:"username"`
+	Email      string               `bson:"email"`
+	Password   string               `bson:"password"`
+	ProfilePic string               `bson:"profilePicture"`
+	CoverPic   string               `bson:"coverPicture"`
+	Followers  []primitive.ObjectID `bson:"followers"`
+	Followings []primitive.ObjectID `bson:"followings"`
+	Roles      []Role               `bson:"roles"`
+	// 2fa secret is only used once TOTPEnabled is true (it is set during enrollment)
+	TOTPSecret    string   `bson:"totpSecret" json:"-"`
+	TOTPEnabled   bool     `bson:"totpEnabled"`
+	RecoveryCodes []string `bson:"recoveryCodes" json:"-"` // bcrypt hashes like the password
+	TOTPLastStep  int64    `bson:"totpLastStep" json:"-"`  // time step of the last code used, older codes are turned down
+	// false until the user uses the link from the verification email
+	EmailVerified bool      `bson:"emailVerified"`
+	Desc          string    `bson:"desc"`
+	City          string    `bson:"city"`
+	From          string    `bson:"from"`
+	Relationship  int       `bson:"relationship"`
+	CreatedAt     time.Time `bson:"created_at"`
+	UpdatedAt     time.Time `bson:"updated_at"` // need to update this whenever changing data
 }
 
 func NewUser() *Users {
 	user := &Users{
-		UserID:       primitive.NewObjectID(),
-		Username:     "default",
-		Email:        "default@default.com",
-		Password:     "defaultPassword",
-		ProfilePic:   "",
-		CoverPic:     "",
-		Follwers:     []string{},
-		Follwings:    []string{},
-		IsAdmin:      false,
-		Desc:         "",
-		City:         "",
-		From:         "",
-		Relationship: 0,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
+		UserID:        primitive.NewObjectID(),
+		Username:      "default",
+		Email:         "default@default.com",
+		Password:      "defaultPassword",
+		ProfilePic:    "",
+		CoverPic:      "",
+		Followers:     []primitive.ObjectID{},
+		Followings:    []primitive.ObjectID{},
+		Roles:         []Role{RoleUser},
+		RecoveryCodes: []string{},
+		EmailVerified: false,
+		Desc:          "",
+		City:          "",
+		From:          "",
+		Relationship:  0,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
 	}
 	return user
 }
-
-// user is valid if username, password, email is given (other fields have default values)
-// returns true if the data of the user is valid
-func ValidUser(user Users) bool {
-	if user.Username == "default" || user.Email == "default@default.com" || user.Password == "defaultPassword" {
-		return false
-	}
-	return true
-}
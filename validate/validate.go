@@ -0,0 +1,217 @@
+// Package validate checks request structs against the rules in their
+// `validate` struct tags and reports every field that failed at once.
+//
+// Rules are separated by commas, a rule with a argument uses =
+//
+//	Email string `json:"email" validate:"required,email,max=254"`
+//
+// Every rule except required passes for a empty value, so optional fields
+// only get checked when the client sends them.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError is one rule that one field failed, field is the json name
+// so the client can match it to its form
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// FieldErrors is every rule that failed in a struct
+type FieldErrors []FieldError
+
+func (fe FieldErrors) Error() string {
+	messages := make([]string, 0, len(fe))
+	for _, e := range fe {
+		messages = append(messages, e.Field+": "+e.Message)
+	}
+	return strings.Join(messages, ", ")
+}
+
+// checks a value against a rule, arg is the part after the = (empty if none)
+// returns the message for the client if the value is invalid
+type ruleFunc func(v reflect.Value, arg string) (string, bool)
+
+var rules = map[string]ruleFunc{
+	"required": required,
+	"email":    email,
+	"username": username,
+	"url":      validURL,
+	"min":      atLeast,
+	"max":      atMost,
+	"oneof":    oneOf,
+}
+
+// checks every field of the struct (or pointer to struct) that has a
+// validate tag, returns nil if all of them passed
+func Struct(s any) error {
+	v := reflect.ValueOf(s)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return FieldErrors{{Field: "", Rule: "required", Message: "request body is required"}}
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		panic("validate: Struct needs a struct, got " + v.Kind().String())
+	}
+	var errs FieldErrors
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		value := v.Field(i)
+		for _, rule := range strings.Split(tag, ",") {
+			name, arg, _ := strings.Cut(rule, "=")
+			check, ok := rules[name]
+			if !ok {
+				panic("validate: unknown rule " + name + " on field " + field.Name)
+			}
+			if name != "required" && value.IsZero() {
+				continue
+			}
+			if message, valid := check(value, arg); !valid {
+				errs = append(errs, FieldError{Field: jsonName(field), Rule: name, Message: message})
+				// later rules on the same field would only repeat the problem
+				break
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// name the client knows the field by
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// length for strings (in characters) and slices, the value for numbers
+func size(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func isNumber(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return false
+	}
+	return true
+}
+
+func required(v reflect.Value, _ string) (string, bool) {
+	blank := v.Kind() == reflect.String && strings.TrimSpace(v.String()) == ""
+	empty := (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0
+	if v.IsZero() || blank || empty {
+		return "is required", false
+	}
+	return "", true
+}
+
+func email(v reflect.Value, _ string) (string, bool) {
+	address, err := mail.ParseAddress(v.String())
+	// ParseAddress also accepts "name <a@b.com>", only the bare address is allowed
+	if err != nil || address.Address != v.String() || !strings.Contains(address.Address[strings.LastIndex(address.Address, "@"):], ".") {
+		return "must be a valid email address", false
+	}
+	return "", true
+}
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 30
+)
+
+func username(v reflect.Value, _ string) (string, bool) {
+	name := v.String()
+	if len(name) < minUsernameLength || len(name) > maxUsernameLength {
+		return fmt.Sprintf("must be between %d and %d characters", minUsernameLength, maxUsernameLength), false
+	}
+	for _, c := range name {
+		isAlphaNum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlphaNum && c != '_' && c != '.' && c != '-' {
+			return "can only have letters, numbers, _ . and -", false
+		}
+	}
+	return "", true
+}
+
+func validURL(v reflect.Value, _ string) (string, bool) {
+	u, err := url.ParseRequestURI(v.String())
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "must be a http or https url", false
+	}
+	return "", true
+}
+
+func atLeast(v reflect.Value, arg string) (string, bool) {
+	limit, err := strconv.ParseFloat(arg, 64)
+	n, ok := size(v)
+	if err != nil || !ok {
+		panic("validate: bad min rule " + arg)
+	}
+	if n < limit {
+		if isNumber(v) {
+			return "must be at least " + arg, false
+		}
+		return "must have at least " + arg + " characters or items", false
+	}
+	return "", true
+}
+
+func atMost(v reflect.Value, arg string) (string, bool) {
+	limit, err := strconv.ParseFloat(arg, 64)
+	n, ok := size(v)
+	if err != nil || !ok {
+		panic("validate: bad max rule " + arg)
+	}
+	if n > limit {
+		if isNumber(v) {
+			return "must be at most " + arg, false
+		}
+		return "must have at most " + arg + " characters or items", false
+	}
+	return "", true
+}
+
+// values are separated by spaces, oneof=user moderator admin
+func oneOf(v reflect.Value, arg string) (string, bool) {
+	value := fmt.Sprint(v.Interface())
+	for _, option := range strings.Fields(arg) {
+		if value == option {
+			return "", true
+		}
+	}
+	return "must be one of: " + strings.Join(strings.Fields(arg), ", "), false
+}
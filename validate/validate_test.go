@@ -0,0 +1,72 @@
+package validate
+
+import (
+	"errors"
+	"testing"
+)
+
+type testRequest struct {
+	Username     string   `json:"username" validate:"required,username"`
+	Email        string   `json:"email" validate:"required,email"`
+	Image        string   `json:"img" validate:"url"`
+	Desc         string   `json:"desc" validate:"max=10"`
+	Relationship int      `json:"relationship" validate:"min=0,max=4"`
+	Role         string   `json:"role" validate:"oneof=user admin"`
+	Scopes       []string `json:"scopes" validate:"required"`
+}
+
+func valid() testRequest {
+	return testRequest{Username: "gabe_99", Email: "gabe@gmail.com", Scopes: []string{"posts:write"}}
+}
+
+func TestStruct(t *testing.T) {
+	testtable := []struct {
+		name           string
+		change         func(r *testRequest)
+		expectedFields []string
+	}{
+		{name: "valid", change: func(r *testRequest) {}, expectedFields: nil},
+		{name: "optional fields set", change: func(r *testRequest) {
+			r.Image = "https://cdn.example.com/a.png"
+			r.Desc = "hello"
+			r.Relationship = 4
+			r.Role = "admin"
+		}, expectedFields: nil},
+		{name: "missing required", change: func(r *testRequest) {
+			r.Username = ""
+			r.Email = "  "
+			r.Scopes = []string{}
+		}, expectedFields: []string{"username", "email", "scopes"}},
+		{name: "bad formats", change: func(r *testRequest) {
+			r.Username = "no spaces allowed"
+			r.Email = "Gabe <gabe@gmail.com>"
+			r.Image = "javascript:alert(1)"
+		}, expectedFields: []string{"username", "email", "img"}},
+		{name: "out of range", change: func(r *testRequest) {
+			r.Desc = "way more than ten"
+			r.Relationship = 5
+			r.Role = "owner"
+		}, expectedFields: []string{"desc", "relationship", "role"}},
+		{name: "negative relationship", change: func(r *testRequest) { r.Relationship = -1 }, expectedFields: []string{"relationship"}},
+		{name: "short username", change: func(r *testRequest) { r.Username = "ab" }, expectedFields: []string{"username"}},
+		{name: "email without domain dot", change: func(r *testRequest) { r.Email = "gabe@localhost" }, expectedFields: []string{"email"}},
+	}
+	for _, tt := range testtable {
+		request := valid()
+		tt.change(&request)
+		err := Struct(&request)
+		var fieldErrs FieldErrors
+		if err != nil && !errors.As(err, &fieldErrs) {
+			t.Fatalf("%s: error is not FieldErrors, got=%T", tt.name, err)
+		}
+		if len(fieldErrs) != len(tt.expectedFields) {
+			t.Errorf("%s: wrong number of errors, got=%v, want fields=%v", tt.name, fieldErrs, tt.expectedFields)
+			continue
+		}
+		for i, field := range tt.expectedFields {
+			if fieldErrs[i].Field != field {
+				t.Errorf("%s: wrong field, got=%s, want=%s", tt.name, fieldErrs[i].Field, field)
+			}
+		}
+	}
+}